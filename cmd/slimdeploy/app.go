@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mhenrichsen/slimdeploy/internal/api"
+	"github.com/mhenrichsen/slimdeploy/internal/backup"
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/events"
+	gitpkg "github.com/mhenrichsen/slimdeploy/internal/git"
+	"github.com/mhenrichsen/slimdeploy/internal/jobs"
+	"github.com/mhenrichsen/slimdeploy/internal/notify"
+	"github.com/mhenrichsen/slimdeploy/internal/stats"
+)
+
+// app holds the services shared by the server and the local CLI commands
+type app struct {
+	config         *Config
+	database       *db.DB
+	projectRepo    *db.ProjectRepository
+	addonRepo      *db.AddonRepository
+	backupRepo     *db.BackupRepository
+	logRepo        *db.LogRepository
+	shellRepo      *db.ShellRepository
+	dockerClient   *docker.Client
+	composeManager *docker.ComposeManager
+	gitManager     *gitpkg.Manager
+	authManager    *api.AuthManager
+	statsCollector *stats.Collector
+	backupManager  *backup.Manager
+	jobRepo        *db.JobRepository
+	jobRunner      *jobs.Runner
+	notifyRepo     *db.NotificationRepository
+	notifier       *notify.Notifier
+	events         *events.Bus
+}
+
+// newApp opens the database and creates the Docker, Compose, Git, backup
+// storage and notification clients and the event bus. Background services such as the stats collector and
+// the backup and job schedulers are started by serve.
+func newApp(config *Config) (*app, error) {
+	// Initialize database
+	database, err := db.New(config.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+
+	// Initialize Docker client
+	dockerClient, err := docker.NewClient(config.BaseDomain, config.AllowedBindPaths)
+	if err != nil {
+		database.Close()
+		return nil, fmt.Errorf("failed to initialize Docker client: %w", err)
+	}
+
+	// Initialize backup storage
+	var s3 *backup.S3Client
+	if config.BackupS3.Enabled() {
+		s3, err = backup.NewS3Client(config.BackupS3)
+		if err != nil {
+			dockerClient.Close()
+			database.Close()
+			return nil, err
+		}
+	}
+	bus := events.New()
+	projectRepo := db.NewProjectRepository(database, bus)
+	backupRepo := db.NewBackupRepository(database)
+	jobRepo := db.NewJobRepository(database)
+	notifyRepo := db.NewNotificationRepository(database)
+
+	return &app{
+		config:         config,
+		database:       database,
+		projectRepo:    projectRepo,
+		addonRepo:      db.NewAddonRepository(database),
+		backupRepo:     backupRepo,
+		logRepo:        db.NewLogRepository(database),
+		shellRepo:      db.NewShellRepository(database),
+		dockerClient:   dockerClient,
+		composeManager: docker.NewComposeManager(config.BaseDomain, config.DeploymentsDir),
+		gitManager:     gitpkg.NewManager(config.DeploymentsDir, config.SSHKeyPath),
+		authManager:    api.NewAuthManager(database.DB, config.Password),
+		statsCollector: stats.NewCollector(dockerClient, stats.DefaultInterval),
+		backupManager:  backup.NewManager(dockerClient, backupRepo, s3),
+		jobRepo:        jobRepo,
+		jobRunner:      jobs.NewRunner(dockerClient, projectRepo, jobRepo),
+		notifyRepo:     notifyRepo,
+		notifier:       notify.NewNotifier(notifyRepo, config.SMTP),
+		events:         bus,
+	}, nil
+}
+
+// newHandler creates the API handler. Templates may be nil when the handler
+// is only used for its deploy and project operations.
+func (a *app) newHandler(templates api.TemplateExecutor) *api.Handler {
+	return api.NewHandler(
+		templates,
+		a.database,
+		a.projectRepo,
+		a.addonRepo,
+		a.dockerClient,
+		a.composeManager,
+		a.gitManager,
+		a.authManager,
+		a.statsCollector,
+		a.backupRepo,
+		a.backupManager,
+		a.logRepo,
+		a.shellRepo,
+		a.jobRepo,
+		a.jobRunner,
+		a.notifyRepo,
+		a.notifier,
+		a.events,
+		a.config.BaseDomain,
+	)
+}
+
+// Close waits for notifications still being sent and releases the
+// database and Docker client
+func (a *app) Close() {
+	a.notifier.Wait()
+	a.dockerClient.Close()
+	a.database.Close()
+}
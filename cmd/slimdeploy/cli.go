@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// backend is implemented by the local database backend and the remote HTTP
+// API client so that commands work the same against either
+type backend interface {
+	ListProjects() ([]*models.Project, error)
+	GetProject(ref string) (*models.Project, error)
+	CreateProject(project *models.Project) error
+	DeleteProject(ctx context.Context, ref string, removeVolumes bool) error
+	Deploy(ctx context.Context, ref string, wait bool) (*models.Project, error)
+	Logs(ctx context.Context, ref string, tail int, follow bool, w io.Writer) error
+	Backup(path string) error
+	Close()
+}
+
+// remoteFlags holds the flags selecting a remote instance
+type remoteFlags struct {
+	url   string
+	token string
+}
+
+// addRemoteFlags registers -remote and -token on a command's flag set
+func addRemoteFlags(flags *flag.FlagSet) *remoteFlags {
+	rf := &remoteFlags{}
+	flags.StringVar(&rf.url, "remote", os.Getenv("SLIMDEPLOY_URL"), "URL of a remote SlimDeploy instance (env SLIMDEPLOY_URL)")
+	flags.StringVar(&rf.token, "token", os.Getenv("SLIMDEPLOY_TOKEN"), "API token for the remote instance (env SLIMDEPLOY_TOKEN)")
+	return rf
+}
+
+// open returns the remote backend if a URL was given, otherwise the local one
+func (rf *remoteFlags) open() (backend, error) {
+	if rf.url != "" {
+		if rf.token == "" {
+			return nil, fmt.Errorf("-token (or SLIMDEPLOY_TOKEN) is required with -remote")
+		}
+		return newRemoteBackend(rf.url, rf.token), nil
+	}
+	return newLocalBackend()
+}
+
+// parseArgs parses flags that may appear before or after positional
+// arguments, e.g. "logs myapp -f", and returns the positional arguments
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// signalContext returns a context cancelled on SIGINT or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+}
+
+// runProject dispatches the project subcommands
+func runProject(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: slimdeploy project <list|show|create|delete>")
+	}
+
+	switch args[0] {
+	case "list", "ls":
+		return runProjectList(args[1:])
+	case "show":
+		return runProjectShow(args[1:])
+	case "create":
+		return runProjectCreate(args[1:])
+	case "delete", "rm":
+		return runProjectDelete(args[1:])
+	default:
+		return fmt.Errorf("unknown project command %q", args[0])
+	}
+}
+
+func runProjectList(args []string) error {
+	flags := flag.NewFlagSet("project list", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	remote := addRemoteFlags(flags)
+	parseArgs(flags, args)
+
+	b, err := remote.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	projects, err := b.ListProjects()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(projects)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tSTATUS\tSOURCE\tAUTO DEPLOY")
+	for _, p := range projects {
+		source := p.Image
+		if p.GitURL != "" {
+			source = p.GitURL + "@" + p.Branch
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", p.Name, p.DeployType, p.Status, source, p.AutoDeploy)
+	}
+	return tw.Flush()
+}
+
+func runProjectShow(args []string) error {
+	flags := flag.NewFlagSet("project show", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print JSON")
+	remote := addRemoteFlags(flags)
+	positional := parseArgs(flags, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: slimdeploy project show <name>")
+	}
+
+	b, err := remote.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	p, err := b.GetProject(positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(p)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%s\n", p.ID)
+	fmt.Fprintf(tw, "Name:\t%s\n", p.Name)
+	fmt.Fprintf(tw, "Type:\t%s\n", p.DeployType)
+	fmt.Fprintf(tw, "Status:\t%s\n", p.Status)
+	if p.StatusMsg != "" {
+		fmt.Fprintf(tw, "Message:\t%s\n", p.StatusMsg)
+	}
+	if p.Image != "" {
+		fmt.Fprintf(tw, "Image:\t%s\n", p.Image)
+	}
+	if p.GitURL != "" {
+		fmt.Fprintf(tw, "Git URL:\t%s\n", p.GitURL)
+		fmt.Fprintf(tw, "Branch:\t%s\n", p.Branch)
+		if p.TagRule != "" {
+			fmt.Fprintf(tw, "Tag Rule:\t%s\n", p.TagRule)
+			fmt.Fprintf(tw, "Deployed Tag:\t%s\n", p.DeployedTag)
+		}
+		fmt.Fprintf(tw, "Last Commit:\t%s\n", p.LastCommit)
+		if p.PinnedCommit != "" {
+			fmt.Fprintf(tw, "Pinned Commit:\t%s\n", p.PinnedCommit)
+		}
+		if p.Compose.Dir != "" {
+			fmt.Fprintf(tw, "Compose Dir:\t%s\n", p.Compose.Dir)
+		}
+		if p.Compose.File != "" {
+			fmt.Fprintf(tw, "Compose File:\t%s\n", p.Compose.File)
+		}
+		if len(p.Compose.Overrides) > 0 {
+			fmt.Fprintf(tw, "Overrides:\t%s\n", strings.Join(p.Compose.Overrides, ", "))
+		}
+		if len(p.Compose.Profiles) > 0 {
+			fmt.Fprintf(tw, "Profiles:\t%s\n", strings.Join(p.Compose.Profiles, ", "))
+		}
+		for _, service := range p.Compose.Services {
+			fmt.Fprintf(tw, "Routed Service:\t%s\n", service)
+		}
+		if p.Compose.MergeTraefikLabels {
+			fmt.Fprintf(tw, "Traefik Labels:\tmerged\n")
+		}
+		if len(p.PathFilter.Include) > 0 {
+			fmt.Fprintf(tw, "Deploy Paths:\t%s\n", strings.Join(p.PathFilter.Include, ", "))
+		}
+		if len(p.PathFilter.Exclude) > 0 {
+			fmt.Fprintf(tw, "Ignored Paths:\t%s\n", strings.Join(p.PathFilter.Exclude, ", "))
+		}
+	}
+	fmt.Fprintf(tw, "Domain:\t%s\n", p.Domain)
+	fmt.Fprintf(tw, "Subdomain:\t%t\n", p.UseSubdomain)
+	fmt.Fprintf(tw, "Port:\t%d\n", p.Port)
+	fmt.Fprintf(tw, "Auto Deploy:\t%t\n", p.AutoDeploy)
+	fmt.Fprintf(tw, "Created:\t%s\n", p.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(tw, "Updated:\t%s\n", p.UpdatedAt.Format("2006-01-02 15:04:05"))
+
+	keys := make([]string, 0, len(p.EnvVars))
+	for k := range p.EnvVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		fmt.Fprintln(tw, "Environment:")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "  %s=%s\n", k, p.EnvVars[k])
+		}
+	}
+	return tw.Flush()
+}
+
+// envFlag collects repeated -env KEY=VALUE flags
+type envFlag map[string]string
+
+func (e envFlag) String() string {
+	return ""
+}
+
+func (e envFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", value)
+	}
+	e[strings.TrimSpace(parts[0])] = parts[1]
+	return nil
+}
+
+func runProjectCreate(args []string) error {
+	flags := flag.NewFlagSet("project create", flag.ExitOnError)
+	project := &models.Project{}
+	env := envFlag{}
+	deployType := flags.String("type", "image", "deploy type: image or compose")
+	flags.StringVar(&project.Name, "name", "", "project name (required)")
+	flags.StringVar(&project.Image, "image", "", "Docker image for image deployments")
+	flags.StringVar(&project.GitURL, "git-url", "", "Git repository URL")
+	flags.StringVar(&project.Branch, "branch", "", "Git branch (default: auto-detect)")
+	flags.StringVar(&project.Compose.Dir, "compose-dir", "", "repository subdirectory compose runs in")
+	flags.StringVar(&project.Compose.File, "compose-file", "", "compose file relative to -compose-dir (default: auto-detect)")
+	flags.StringVar(&project.TagRule, "tag", "", "deploy an exact tag or the newest tag matching a rule like v1.x")
+	flags.StringVar(&project.Domain, "domain", "", "custom domain")
+	flags.BoolVar(&project.UseSubdomain, "subdomain", true, "serve the project on <name>.<BASE_DOMAIN>")
+	flags.IntVar(&project.Port, "port", 80, "container port")
+	flags.BoolVar(&project.AutoDeploy, "auto-deploy", false, "redeploy when the branch changes")
+	flags.Var(env, "env", "environment variable as KEY=VALUE (repeatable)")
+	asJSON := flags.Bool("json", false, "print the created project as JSON")
+	remote := addRemoteFlags(flags)
+	positional := parseArgs(flags, args)
+
+	if project.Name == "" && len(positional) == 1 {
+		project.Name = positional[0]
+	}
+	if project.Name == "" {
+		return fmt.Errorf("usage: slimdeploy project create -name <name> [flags]")
+	}
+	switch *deployType {
+	case "image":
+		project.DeployType = models.DeployTypeImage
+	case "compose":
+		project.DeployType = models.DeployTypeCompose
+	default:
+		return fmt.Errorf("invalid -type %q: must be image or compose", *deployType)
+	}
+	project.EnvVars = env
+
+	b, err := remote.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	if err := b.CreateProject(project); err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(project)
+	}
+	fmt.Printf("Created project %s (%s)\n", project.Name, project.ID)
+	return nil
+}
+
+func runProjectDelete(args []string) error {
+	flags := flag.NewFlagSet("project delete", flag.ExitOnError)
+	yes := flags.Bool("yes", false, "don't ask for confirmation")
+	volumes := flags.Bool("volumes", false, "also remove the project's volumes")
+	remote := addRemoteFlags(flags)
+	positional := parseArgs(flags, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: slimdeploy project delete <name> [-volumes]")
+	}
+	name := positional[0]
+
+	question := fmt.Sprintf("Delete project %s and remove its containers?", name)
+	if *volumes {
+		question = fmt.Sprintf("Delete project %s and remove its containers and volumes?", name)
+	}
+	if !*yes && !confirm(question) {
+		return fmt.Errorf("aborted")
+	}
+
+	b, err := remote.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	if err := b.DeleteProject(ctx, name, *volumes); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted project %s\n", name)
+	return nil
+}
+
+func runDeploy(args []string) error {
+	flags := flag.NewFlagSet("deploy", flag.ExitOnError)
+	detach := flags.Bool("detach", false, "don't wait for the deployment to finish (remote only)")
+	remote := addRemoteFlags(flags)
+	positional := parseArgs(flags, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: slimdeploy deploy <name>")
+	}
+
+	b, err := remote.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	fmt.Fprintf(os.Stderr, "Deploying %s...\n", positional[0])
+	project, err := b.Deploy(ctx, positional[0], !*detach)
+	if err != nil {
+		return err
+	}
+
+	if project.Status == models.StatusError {
+		return fmt.Errorf("deployment failed: %s", project.StatusMsg)
+	}
+	fmt.Printf("%s: %s\n", project.Name, project.Status)
+	return nil
+}
+
+func runLogs(args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := flags.Bool("f", false, "follow log output")
+	tail := flags.Int("tail", 100, "number of lines to show from the end of the logs")
+	remote := addRemoteFlags(flags)
+	positional := parseArgs(flags, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: slimdeploy logs <name> [-f] [-tail N]")
+	}
+
+	b, err := remote.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	err = b.Logs(ctx, positional[0], *tail, *follow, os.Stdout)
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// runUser dispatches the user subcommands, which always use the local database
+func runUser(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: slimdeploy user <add|list|delete>")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	a, err := newApp(config)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	switch args[0] {
+	case "add":
+		flags := flag.NewFlagSet("user add", flag.ExitOnError)
+		password := flags.String("password", "", "password (read from stdin if empty)")
+		positional := parseArgs(flags, args[1:])
+		if len(positional) != 1 {
+			return fmt.Errorf("usage: slimdeploy user add <username> [-password <password>]")
+		}
+		if *password == "" {
+			*password, err = readSecret("Password: ")
+			if err != nil {
+				return err
+			}
+		}
+		if err := a.authManager.AddUser(positional[0], *password); err != nil {
+			return err
+		}
+		fmt.Printf("Added user %s\n", positional[0])
+		return nil
+
+	case "list", "ls":
+		users, err := a.authManager.ListUsers()
+		if err != nil {
+			return err
+		}
+		for _, u := range users {
+			fmt.Println(u)
+		}
+		return nil
+
+	case "delete", "rm":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: slimdeploy user delete <username>")
+		}
+		if err := a.authManager.DeleteUser(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted user %s\n", args[1])
+		return nil
+
+	default:
+		return fmt.Errorf("unknown user command %q", args[0])
+	}
+}
+
+// runToken dispatches the token subcommands, which always use the local database
+func runToken(args []string) error {
+	if len(args) != 2 || args[0] != "create" {
+		return fmt.Errorf("usage: slimdeploy token create <name>")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	a, err := newApp(config)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	token, err := a.authManager.CreateAPIToken(args[1])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Store this token now, it can't be shown again:")
+	fmt.Println(token)
+	return nil
+}
+
+func runBackup(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	remote := addRemoteFlags(flags)
+	positional := parseArgs(flags, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: slimdeploy backup <file>")
+	}
+
+	b, err := remote.open()
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	if err := b.Backup(positional[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote backup to %s\n", positional[0])
+	return nil
+}
+
+func runRestore(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	yes := flags.Bool("yes", false, "don't ask for confirmation")
+	positional := parseArgs(flags, args)
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: slimdeploy restore <file>")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if !*yes && !confirm(fmt.Sprintf("Replace the database in %s? Stop the server first.", config.DataDir)) {
+		return fmt.Errorf("aborted")
+	}
+
+	if err := db.Restore(config.DataDir, positional[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Restored database from %s\n", positional[0])
+	return nil
+}
+
+// runConfig dispatches the config subcommands
+func runConfig(args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return fmt.Errorf("usage: slimdeploy [-config <file>] config check")
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Print the resolved configuration in config file format
+	enc := yaml.NewEncoder(os.Stdout)
+	enc.SetIndent(2)
+	if err := enc.Encode(config.redacted()); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "Configuration is valid")
+	return nil
+}
+
+// printJSON prints a value as indented JSON
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// confirm asks a yes/no question on the terminal
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// readSecret reads a single line from stdin. On a terminal it prompts and
+// reads without echoing what is typed.
+func readSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read from terminal: %w", err)
+		}
+		return string(secret), nil
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read from stdin: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/backup"
+	"github.com/mhenrichsen/slimdeploy/internal/notify"
+	"gopkg.in/yaml.v3"
+)
+
+// configPath is the YAML config file set with the global -config flag
+var configPath string
+
+const (
+	// EnvDevelopment allows insecure defaults such as the default password
+	EnvDevelopment = "development"
+	// EnvProduction refuses to start with insecure defaults
+	EnvProduction = "production"
+
+	// defaultPassword is the password used when none is configured
+	defaultPassword = "admin"
+
+	// minWatchInterval keeps the watcher from hammering git remotes
+	minWatchInterval = 5 * time.Second
+)
+
+// Config holds application configuration
+type Config struct {
+	Environment    string
+	ListenAddr     string
+	DataDir        string
+	DeploymentsDir string
+	Password       string
+	Domain         string
+	BaseDomain     string
+	SSHKeyPath     string
+	WatchInterval  time.Duration
+	MetricsToken   string
+	// WebhookSecret verifies push webhooks; webhooks are off without one
+	WebhookSecret string
+
+	// AllowedBindPaths are the host directories projects may bind mount
+	AllowedBindPaths []string
+
+	// BackupS3 is the object storage volume backups are uploaded to
+	BackupS3 backup.S3Config
+
+	// SMTP is the mail server email notifications are sent through
+	SMTP notify.SMTPConfig
+}
+
+// fileConfig is the on-disk YAML representation of Config. Every field is a
+// string so that durations and empty values can be validated with the same
+// error messages as environment variables.
+type fileConfig struct {
+	Environment    string `yaml:"environment,omitempty"`
+	ListenAddr     string `yaml:"listen_addr,omitempty"`
+	DataDir        string `yaml:"data_dir,omitempty"`
+	DeploymentsDir string `yaml:"deployments_dir,omitempty"`
+	Password       string `yaml:"password,omitempty"`
+	Domain         string `yaml:"domain,omitempty"`
+	BaseDomain     string `yaml:"base_domain,omitempty"`
+	SSHKeyPath     string `yaml:"ssh_key_path,omitempty"`
+	WatchInterval  string `yaml:"watch_interval,omitempty"`
+	MetricsToken   string `yaml:"metrics_token,omitempty"`
+	WebhookSecret  string `yaml:"webhook_secret,omitempty"`
+
+	// AllowedBindPaths is a comma-separated list of host directories
+	AllowedBindPaths string `yaml:"allowed_bind_paths,omitempty"`
+
+	BackupS3Endpoint  string `yaml:"backup_s3_endpoint,omitempty"`
+	BackupS3Region    string `yaml:"backup_s3_region,omitempty"`
+	BackupS3Bucket    string `yaml:"backup_s3_bucket,omitempty"`
+	BackupS3AccessKey string `yaml:"backup_s3_access_key,omitempty"`
+	BackupS3SecretKey string `yaml:"backup_s3_secret_key,omitempty"`
+	BackupS3Prefix    string `yaml:"backup_s3_prefix,omitempty"`
+
+	SMTPHost     string `yaml:"smtp_host,omitempty"`
+	SMTPPort     string `yaml:"smtp_port,omitempty"`
+	SMTPUsername string `yaml:"smtp_username,omitempty"`
+	SMTPPassword string `yaml:"smtp_password,omitempty"`
+	SMTPFrom     string `yaml:"smtp_from,omitempty"`
+}
+
+// ConfigError lists every problem found while validating the configuration
+type ConfigError struct {
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// loadConfig resolves the configuration from defaults, the optional config
+// file and environment variables (in increasing precedence) and validates it
+func loadConfig() (*Config, error) {
+	values := fileConfig{
+		ListenAddr:     ":8080",
+		DataDir:        "./data",
+		DeploymentsDir: "./deployments",
+		Password:       defaultPassword,
+		Domain:         "localhost",
+		BaseDomain:     "localhost",
+		WatchInterval:  "60s",
+		BackupS3Region: "us-east-1",
+		SMTPPort:       "587",
+	}
+
+	// Apply config file
+	if configPath != "" {
+		if err := readConfigFile(configPath, &values); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apply environment variables
+	values.Environment = getEnv("SLIMDEPLOY_ENV", values.Environment)
+	values.ListenAddr = getEnv("LISTEN_ADDR", values.ListenAddr)
+	values.DataDir = getEnv("DATA_DIR", values.DataDir)
+	values.DeploymentsDir = getEnv("DEPLOYMENTS_DIR", values.DeploymentsDir)
+	values.Password = getEnv("SLIMDEPLOY_PASSWORD", values.Password)
+	values.Domain = getEnv("DOMAIN", values.Domain)
+	values.BaseDomain = getEnv("BASE_DOMAIN", values.BaseDomain)
+	values.SSHKeyPath = getEnv("SSH_KEY_PATH", values.SSHKeyPath)
+	values.WatchInterval = getEnv("WATCH_INTERVAL", values.WatchInterval)
+	values.MetricsToken = getEnv("METRICS_TOKEN", values.MetricsToken)
+	values.WebhookSecret = getEnv("WEBHOOK_SECRET", values.WebhookSecret)
+	values.AllowedBindPaths = getEnv("ALLOWED_BIND_PATHS", values.AllowedBindPaths)
+	values.BackupS3Endpoint = getEnv("BACKUP_S3_ENDPOINT", values.BackupS3Endpoint)
+	values.BackupS3Region = getEnv("BACKUP_S3_REGION", values.BackupS3Region)
+	values.BackupS3Bucket = getEnv("BACKUP_S3_BUCKET", values.BackupS3Bucket)
+	values.BackupS3AccessKey = getEnv("BACKUP_S3_ACCESS_KEY", values.BackupS3AccessKey)
+	values.BackupS3SecretKey = getEnv("BACKUP_S3_SECRET_KEY", values.BackupS3SecretKey)
+	values.BackupS3Prefix = getEnv("BACKUP_S3_PREFIX", values.BackupS3Prefix)
+	values.SMTPHost = getEnv("SMTP_HOST", values.SMTPHost)
+	values.SMTPPort = getEnv("SMTP_PORT", values.SMTPPort)
+	values.SMTPUsername = getEnv("SMTP_USERNAME", values.SMTPUsername)
+	values.SMTPPassword = getEnv("SMTP_PASSWORD", values.SMTPPassword)
+	values.SMTPFrom = getEnv("SMTP_FROM", values.SMTPFrom)
+
+	config := &Config{
+		Environment:    strings.ToLower(strings.TrimSpace(values.Environment)),
+		ListenAddr:     values.ListenAddr,
+		DataDir:        values.DataDir,
+		DeploymentsDir: values.DeploymentsDir,
+		Password:       values.Password,
+		Domain:         strings.ToLower(values.Domain),
+		BaseDomain:     strings.ToLower(values.BaseDomain),
+		SSHKeyPath:     values.SSHKeyPath,
+		MetricsToken:   values.MetricsToken,
+		WebhookSecret:  values.WebhookSecret,
+		BackupS3: backup.S3Config{
+			Endpoint:  strings.TrimSpace(values.BackupS3Endpoint),
+			Region:    values.BackupS3Region,
+			Bucket:    values.BackupS3Bucket,
+			AccessKey: values.BackupS3AccessKey,
+			SecretKey: values.BackupS3SecretKey,
+			Prefix:    values.BackupS3Prefix,
+		},
+		SMTP: notify.SMTPConfig{
+			Host:     strings.TrimSpace(values.SMTPHost),
+			Username: values.SMTPUsername,
+			Password: values.SMTPPassword,
+			From:     strings.TrimSpace(values.SMTPFrom),
+		},
+	}
+
+	for _, p := range strings.Split(values.AllowedBindPaths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			config.AllowedBindPaths = append(config.AllowedBindPaths, p)
+		}
+	}
+
+	// Local setups default to development, everything else to production
+	if config.Environment == "" {
+		config.Environment = EnvProduction
+		if isLocalDomain(config.BaseDomain) {
+			config.Environment = EnvDevelopment
+		}
+	}
+
+	var problems []string
+	interval, err := time.ParseDuration(values.WatchInterval)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("watch_interval (WATCH_INTERVAL): %q is not a valid duration, e.g. 60s or 5m", values.WatchInterval))
+	} else {
+		config.WatchInterval = interval
+	}
+	smtpPort, portErr := strconv.Atoi(strings.TrimSpace(values.SMTPPort))
+	if portErr != nil || smtpPort < 1 || smtpPort > 65535 {
+		problems = append(problems, fmt.Sprintf("smtp_port (SMTP_PORT): %q is not a valid port", values.SMTPPort))
+	} else {
+		config.SMTP.Port = smtpPort
+	}
+
+	problems = append(problems, config.validate(err == nil)...)
+	if len(problems) > 0 {
+		return nil, &ConfigError{Problems: problems}
+	}
+
+	return config, nil
+}
+
+// readConfigFile decodes a YAML config file over the given values
+func readConfigFile(path string, values *fileConfig) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(values); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks the resolved configuration and returns every problem found
+func (c *Config) validate(checkInterval bool) []string {
+	var problems []string
+
+	if c.Environment != EnvDevelopment && c.Environment != EnvProduction {
+		problems = append(problems, fmt.Sprintf("environment (SLIMDEPLOY_ENV): %q must be %q or %q", c.Environment, EnvDevelopment, EnvProduction))
+	}
+
+	if _, port, err := net.SplitHostPort(c.ListenAddr); err != nil || port == "" {
+		problems = append(problems, fmt.Sprintf("listen_addr (LISTEN_ADDR): %q must be host:port, e.g. :8080", c.ListenAddr))
+	}
+
+	if strings.TrimSpace(c.DataDir) == "" {
+		problems = append(problems, "data_dir (DATA_DIR): must not be empty")
+	}
+	if strings.TrimSpace(c.DeploymentsDir) == "" {
+		problems = append(problems, "deployments_dir (DEPLOYMENTS_DIR): must not be empty")
+	}
+
+	if !isValidHostname(c.Domain) {
+		problems = append(problems, fmt.Sprintf("domain (DOMAIN): %q is not a valid hostname", c.Domain))
+	}
+	if !isValidHostname(c.BaseDomain) {
+		problems = append(problems, fmt.Sprintf("base_domain (BASE_DOMAIN): %q is not a valid hostname", c.BaseDomain))
+	}
+
+	if checkInterval && c.WatchInterval < minWatchInterval {
+		problems = append(problems, fmt.Sprintf("watch_interval (WATCH_INTERVAL): %s is below the minimum of %s", c.WatchInterval, minWatchInterval))
+	}
+
+	if c.Password == "" {
+		problems = append(problems, "password (SLIMDEPLOY_PASSWORD): must not be empty")
+	} else if c.Password == defaultPassword && c.Environment == EnvProduction {
+		problems = append(problems, "password (SLIMDEPLOY_PASSWORD): the default password cannot be used in production; set a password or SLIMDEPLOY_ENV=development")
+	}
+
+	for _, p := range c.AllowedBindPaths {
+		if !filepath.IsAbs(p) {
+			problems = append(problems, fmt.Sprintf("allowed_bind_paths (ALLOWED_BIND_PATHS): %q must be an absolute path", p))
+		}
+	}
+
+	if c.BackupS3.Enabled() {
+		if u, err := url.Parse(c.BackupS3.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("backup_s3_endpoint (BACKUP_S3_ENDPOINT): %q must be an http(s) URL", c.BackupS3.Endpoint))
+		}
+		if c.BackupS3.Bucket == "" {
+			problems = append(problems, "backup_s3_bucket (BACKUP_S3_BUCKET): must be set when backups are enabled")
+		}
+		if c.BackupS3.AccessKey == "" || c.BackupS3.SecretKey == "" {
+			problems = append(problems, "backup_s3_access_key and backup_s3_secret_key (BACKUP_S3_ACCESS_KEY, BACKUP_S3_SECRET_KEY): must be set when backups are enabled")
+		}
+	}
+
+	if c.SMTP.Enabled() {
+		if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+			problems = append(problems, fmt.Sprintf("smtp_from (SMTP_FROM): %q must be an email address when email is enabled", c.SMTP.From))
+		}
+	}
+
+	// A missing key is allowed (public repos only), a directory is a mistake
+	if c.SSHKeyPath != "" {
+		if info, err := os.Stat(c.SSHKeyPath); err == nil && info.IsDir() {
+			problems = append(problems, fmt.Sprintf("ssh_key_path (SSH_KEY_PATH): %s is a directory, not a key file", c.SSHKeyPath))
+		}
+	}
+
+	return problems
+}
+
+// redacted returns the configuration as it would appear in a config file,
+// with secrets masked
+func (c *Config) redacted() fileConfig {
+	password := "********"
+	if c.Password == defaultPassword {
+		password = "(default)"
+	}
+	metricsToken := ""
+	if c.MetricsToken != "" {
+		metricsToken = "********"
+	}
+	webhookSecret := ""
+	if c.WebhookSecret != "" {
+		webhookSecret = "********"
+	}
+	backupSecretKey := ""
+	if c.BackupS3.SecretKey != "" {
+		backupSecretKey = "********"
+	}
+	smtpPassword := ""
+	if c.SMTP.Password != "" {
+		smtpPassword = "********"
+	}
+	return fileConfig{
+		Environment:    c.Environment,
+		ListenAddr:     c.ListenAddr,
+		DataDir:        c.DataDir,
+		DeploymentsDir: c.DeploymentsDir,
+		Password:       password,
+		Domain:         c.Domain,
+		BaseDomain:     c.BaseDomain,
+		SSHKeyPath:     c.SSHKeyPath,
+		WatchInterval:  c.WatchInterval.String(),
+		MetricsToken:   metricsToken,
+		WebhookSecret:  webhookSecret,
+
+		AllowedBindPaths: strings.Join(c.AllowedBindPaths, ","),
+
+		BackupS3Endpoint:  c.BackupS3.Endpoint,
+		BackupS3Region:    c.BackupS3.Region,
+		BackupS3Bucket:    c.BackupS3.Bucket,
+		BackupS3AccessKey: c.BackupS3.AccessKey,
+		BackupS3SecretKey: backupSecretKey,
+		BackupS3Prefix:    c.BackupS3.Prefix,
+
+		SMTPHost:     c.SMTP.Host,
+		SMTPPort:     strconv.Itoa(c.SMTP.Port),
+		SMTPUsername: c.SMTP.Username,
+		SMTPPassword: smtpPassword,
+		SMTPFrom:     c.SMTP.From,
+	}
+}
+
+// isLocalDomain reports whether a domain only resolves on the local machine
+func isLocalDomain(domain string) bool {
+	return domain == "localhost" || strings.HasSuffix(domain, ".localhost")
+}
+
+// isValidHostname checks a domain name against RFC 1123 label rules
+func isValidHostname(host string) bool {
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// logConfig logs the configuration (without password)
+func logConfig(config *Config) {
+	log.Printf("Configuration:")
+	if configPath != "" {
+		log.Printf("  Config File: %s", configPath)
+	}
+	log.Printf("  Environment: %s", config.Environment)
+	log.Printf("  Listen Address: %s", config.ListenAddr)
+	log.Printf("  Data Directory: %s", config.DataDir)
+	log.Printf("  Deployments Directory: %s", config.DeploymentsDir)
+	log.Printf("  Domain: %s", config.Domain)
+	log.Printf("  Base Domain: %s", config.BaseDomain)
+	log.Printf("  Watch Interval: %s", config.WatchInterval)
+	log.Printf("  Metrics Protected: %t", config.MetricsToken != "")
+	log.Printf("  Webhooks Enabled: %t", config.WebhookSecret != "")
+	if len(config.AllowedBindPaths) > 0 {
+		log.Printf("  Allowed Bind Paths: %s", strings.Join(config.AllowedBindPaths, ", "))
+	}
+	if config.BackupS3.Enabled() {
+		log.Printf("  Backup Storage: %s/%s", config.BackupS3.Endpoint, config.BackupS3.Bucket)
+	}
+	if config.SMTP.Enabled() {
+		log.Printf("  SMTP Server: %s:%d", config.SMTP.Host, config.SMTP.Port)
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
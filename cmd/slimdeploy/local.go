@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/mhenrichsen/slimdeploy/internal/api"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// localBackend operates directly on the local database and Docker daemon
+type localBackend struct {
+	app     *app
+	handler *api.Handler
+}
+
+func newLocalBackend() (*localBackend, error) {
+	config, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	a, err := newApp(config)
+	if err != nil {
+		return nil, err
+	}
+	return &localBackend{app: a, handler: a.newHandler(nil)}, nil
+}
+
+// project looks up a project by name or ID
+func (b *localBackend) project(ref string) (*models.Project, error) {
+	project, err := b.app.projectRepo.GetByName(ref)
+	if err == nil && project == nil {
+		project, err = b.app.projectRepo.GetByID(ref)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if project == nil {
+		return nil, fmt.Errorf("project %s not found", ref)
+	}
+	return project, nil
+}
+
+func (b *localBackend) ListProjects() ([]*models.Project, error) {
+	return b.app.projectRepo.List()
+}
+
+func (b *localBackend) GetProject(ref string) (*models.Project, error) {
+	return b.project(ref)
+}
+
+func (b *localBackend) CreateProject(project *models.Project) error {
+	return b.handler.SaveNewProject(project)
+}
+
+func (b *localBackend) DeleteProject(ctx context.Context, ref string, removeVolumes bool) error {
+	project, err := b.project(ref)
+	if err != nil {
+		return err
+	}
+	return b.handler.RemoveProject(ctx, project, removeVolumes)
+}
+
+// Deploy deploys synchronously; a local deploy can't outlive the process
+func (b *localBackend) Deploy(ctx context.Context, ref string, wait bool) (*models.Project, error) {
+	project, err := b.project(ref)
+	if err != nil {
+		return nil, err
+	}
+	if project.Status == models.StatusDeploying {
+		return nil, fmt.Errorf("project %s is already deploying", project.Name)
+	}
+
+	b.app.projectRepo.UpdateStatus(project.ID, models.StatusDeploying, "Starting deployment...")
+	if err := b.handler.DeployProject(ctx, project, models.TriggerCLI); err != nil {
+		log.Printf("Deployment failed for %s: %v", project.Name, err)
+		b.app.projectRepo.UpdateStatus(project.ID, models.StatusError, err.Error())
+	}
+
+	return b.app.projectRepo.GetByID(project.ID)
+}
+
+func (b *localBackend) Logs(ctx context.Context, ref string, tail int, follow bool, w io.Writer) error {
+	project, err := b.project(ref)
+	if err != nil {
+		return err
+	}
+	return b.app.dockerClient.StreamProjectLogs(ctx, project.ID, tail, follow, w)
+}
+
+func (b *localBackend) Backup(path string) error {
+	return b.app.database.Backup(path)
+}
+
+func (b *localBackend) Close() {
+	b.app.Close()
+}
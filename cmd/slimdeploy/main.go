@@ -1,196 +1,94 @@
 package main
 
 import (
-	"context"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"io/fs"
-	"log"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
-	"github.com/mhenrichsen/slimdeploy/internal/api"
-	"github.com/mhenrichsen/slimdeploy/internal/db"
-	"github.com/mhenrichsen/slimdeploy/internal/docker"
-	gitpkg "github.com/mhenrichsen/slimdeploy/internal/git"
-	"github.com/mhenrichsen/slimdeploy/internal/watcher"
+	"github.com/mhenrichsen/slimdeploy/internal/stats"
 	"github.com/mhenrichsen/slimdeploy/web"
 )
 
 func main() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-	log.Println("Starting SlimDeploy...")
-
-	// Load configuration from environment
-	config := loadConfig()
-
-	// Initialize database
-	database, err := db.New(config.DataDir)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Global flags come before the command, e.g. "slimdeploy -config x.yml serve"
+	globalFlags := flag.NewFlagSet("slimdeploy", flag.ExitOnError)
+	globalFlags.StringVar(&configPath, "config", os.Getenv("SLIMDEPLOY_CONFIG"), "path to a YAML config file (env SLIMDEPLOY_CONFIG)")
+	globalFlags.Usage = printUsage
+	globalFlags.Parse(os.Args[1:])
+
+	args := globalFlags.Args()
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	switch command {
+	case "serve":
+		err = runServe(args)
+	case "project", "projects":
+		err = runProject(args)
+	case "deploy":
+		err = runDeploy(args)
+	case "logs":
+		err = runLogs(args)
+	case "user", "users":
+		err = runUser(args)
+	case "token":
+		err = runToken(args)
+	case "backup":
+		err = runBackup(args)
+	case "restore":
+		err = runRestore(args)
+	case "config":
+		err = runConfig(args)
+	case "help", "-h", "-help", "--help":
+		printUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		printUsage()
+		os.Exit(2)
 	}
-	defer database.Close()
 
-	// Initialize repositories
-	projectRepo := db.NewProjectRepository(database)
-
-	// Initialize Docker client
-	dockerClient, err := docker.NewClient(config.BaseDomain)
 	if err != nil {
-		log.Fatalf("Failed to initialize Docker client: %v", err)
-	}
-	defer dockerClient.Close()
-
-	// Ensure Docker network exists
-	ctx := context.Background()
-	if err := dockerClient.EnsureNetwork(ctx); err != nil {
-		log.Printf("Warning: Failed to ensure Docker network: %v", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-
-	// Initialize Compose manager
-	composeManager := docker.NewComposeManager(config.BaseDomain, config.DeploymentsDir)
-
-	// Initialize Git manager
-	gitManager := gitpkg.NewManager(config.DeploymentsDir, config.SSHKeyPath)
-
-	// Initialize auth manager
-	authManager := api.NewAuthManager(database.DB, config.Password)
-
-	// Clean up expired sessions periodically
-	go func() {
-		ticker := time.NewTicker(1 * time.Hour)
-		defer ticker.Stop()
-		for range ticker.C {
-			if err := authManager.CleanupExpiredSessions(); err != nil {
-				log.Printf("Failed to cleanup sessions: %v", err)
-			}
-		}
-	}()
-
-	// Parse templates
-	templates, err := parseTemplates()
-	if err != nil {
-		log.Fatalf("Failed to parse templates: %v", err)
-	}
-
-	// Create handler
-	handler := api.NewHandler(
-		templates,
-		projectRepo,
-		dockerClient,
-		composeManager,
-		gitManager,
-		authManager,
-		config.BaseDomain,
-	)
-
-	// Initialize watcher
-	watcherService := watcher.New(
-		projectRepo,
-		gitManager,
-		handler.DeployProject,
-		config.WatchInterval,
-	)
-	watcherService.Start()
-	defer watcherService.Stop()
-
-	// Create static file server
-	staticSubFS, err := fs.Sub(web.StaticFS, "static")
-	if err != nil {
-		log.Fatalf("Failed to create static FS: %v", err)
-	}
-
-	// Create router
-	router := api.NewRouter(handler, authManager, http.FS(staticSubFS))
-
-	// Create server
-	server := &http.Server{
-		Addr:         config.ListenAddr,
-		Handler:      router,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		IdleTimeout:  60 * time.Second,
-	}
-
-	// Start server in goroutine
-	go func() {
-		log.Printf("Server listening on %s", config.ListenAddr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start server: %v", err)
-		}
-	}()
-
-	// Wait for shutdown signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Println("Shutting down server...")
-
-	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
-	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
-	}
-
-	log.Println("Server stopped")
-}
-
-// Config holds application configuration
-type Config struct {
-	ListenAddr     string
-	DataDir        string
-	DeploymentsDir string
-	Password       string
-	Domain         string
-	BaseDomain     string
-	SSHKeyPath     string
-	WatchInterval  time.Duration
-}
-
-func loadConfig() *Config {
-	config := &Config{
-		ListenAddr:     getEnv("LISTEN_ADDR", ":8080"),
-		DataDir:        getEnv("DATA_DIR", "./data"),
-		DeploymentsDir: getEnv("DEPLOYMENTS_DIR", "./deployments"),
-		Password:       getEnv("SLIMDEPLOY_PASSWORD", "admin"),
-		Domain:         getEnv("DOMAIN", "localhost"),
-		BaseDomain:     getEnv("BASE_DOMAIN", "localhost"),
-		SSHKeyPath:     getEnv("SSH_KEY_PATH", ""),
-	}
-
-	// Parse watch interval
-	intervalStr := getEnv("WATCH_INTERVAL", "60s")
-	interval, err := time.ParseDuration(intervalStr)
-	if err != nil {
-		log.Printf("Invalid WATCH_INTERVAL, using default 60s")
-		interval = 60 * time.Second
-	}
-	config.WatchInterval = interval
-
-	// Log configuration (without password)
-	log.Printf("Configuration:")
-	log.Printf("  Listen Address: %s", config.ListenAddr)
-	log.Printf("  Data Directory: %s", config.DataDir)
-	log.Printf("  Deployments Directory: %s", config.DeploymentsDir)
-	log.Printf("  Domain: %s", config.Domain)
-	log.Printf("  Base Domain: %s", config.BaseDomain)
-	log.Printf("  Watch Interval: %s", config.WatchInterval)
-
-	return config
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+// printUsage prints the top-level command overview
+func printUsage() {
+	fmt.Fprint(os.Stderr, `Usage: slimdeploy [-config <file>] <command> [arguments]
+
+Commands:
+  serve                       Start the web server (default)
+  project list                List projects
+  project show <name>         Show a project
+  project create [flags]      Create a project
+  project delete <name>       Delete a project and its containers
+  deploy <name>               Deploy a project
+  logs <name> [-f]            Show or follow a project's logs
+  user add <username>         Add a login user
+  user list                   List login users
+  user delete <username>      Delete a login user
+  token create <name>         Create an API token for remote use
+  backup <file>               Write a database backup
+  restore <file>              Restore the database from a backup
+  config check                Validate and print the resolved configuration
+
+Project, deploy, logs and backup commands work against the local database by
+default. Pass -remote <url> and -token <token> (or set SLIMDEPLOY_URL and
+SLIMDEPLOY_TOKEN) to operate a remote instance over its HTTP API.
+
+Configuration is read from the optional YAML file given with -config (or
+SLIMDEPLOY_CONFIG), then overridden by environment variables.
+
+Run "slimdeploy <command> -h" for command flags.
+`)
 }
 
 // Templates holds parsed templates for each page
@@ -214,6 +112,7 @@ func parseTemplates() (*Templates, error) {
 		"formatTime": func(t time.Time) string {
 			return t.Format("Jan 02, 2006 15:04")
 		},
+		"formatBytes": stats.FormatBytes,
 	}
 
 	templatesSubFS, err := fs.Sub(web.TemplatesFS, "templates")
@@ -233,7 +132,7 @@ func parseTemplates() (*Templates, error) {
 	}
 
 	// Parse each page template with its own isolated template set
-	pageTemplates := []string{"login.html", "dashboard.html", "project.html", "project_detail.html"}
+	pageTemplates := []string{"login.html", "dashboard.html", "project.html", "project_detail.html", "addons.html", "notifications.html"}
 
 	for _, pageName := range pageTemplates {
 		content, err := fs.ReadFile(templatesSubFS, pageName)
@@ -260,6 +159,14 @@ func parseTemplates() (*Templates, error) {
 	// Also create a special template for project_card partial (used by HTMX)
 	// Reuse the dashboard template which already has the project_card define
 	templates.templates["project_card"] = templates.templates["dashboard.html"]
+	templates.templates["host_stats"] = templates.templates["dashboard.html"]
+	templates.templates["project_stats"] = templates.templates["project_detail.html"]
+	templates.templates["project_backups"] = templates.templates["project_detail.html"]
+	templates.templates["project_jobs"] = templates.templates["project_detail.html"]
+	templates.templates["project_labels"] = templates.templates["project_detail.html"]
+	templates.templates["project_compose"] = templates.templates["project_detail.html"]
+	templates.templates["log_search"] = templates.templates["project_detail.html"]
+	templates.templates["notification_test"] = templates.templates["notifications.html"]
 
 	return templates, nil
 }
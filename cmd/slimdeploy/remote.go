@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// deployPollInterval is how often a remote deploy is polled while waiting
+const deployPollInterval = 2 * time.Second
+
+// remoteBackend talks to a SlimDeploy instance over its JSON API
+type remoteBackend struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func newRemoteBackend(baseURL, token string) *remoteBackend {
+	return &remoteBackend{
+		baseURL: strings.TrimRight(baseURL, "/") + "/api/v1",
+		token:   token,
+		client:  &http.Client{},
+	}
+}
+
+// do sends a request and returns the response, turning API errors into Go errors
+func (b *remoteBackend) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
+			return nil, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+		}
+		return nil, fmt.Errorf("%s", apiErr.Error)
+	}
+
+	return resp, nil
+}
+
+// doJSON sends a request and decodes the JSON response into out
+func (b *remoteBackend) doJSON(ctx context.Context, method, path string, body, out interface{}) error {
+	resp, err := b.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// projectPath returns the API path of a project
+func projectPath(ref string) string {
+	return "/projects/" + url.PathEscape(ref)
+}
+
+func (b *remoteBackend) ListProjects() ([]*models.Project, error) {
+	var projects []*models.Project
+	err := b.doJSON(context.Background(), http.MethodGet, "/projects", nil, &projects)
+	return projects, err
+}
+
+func (b *remoteBackend) GetProject(ref string) (*models.Project, error) {
+	var project models.Project
+	if err := b.doJSON(context.Background(), http.MethodGet, projectPath(ref), nil, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (b *remoteBackend) CreateProject(project *models.Project) error {
+	return b.doJSON(context.Background(), http.MethodPost, "/projects", project, project)
+}
+
+func (b *remoteBackend) DeleteProject(ctx context.Context, ref string, removeVolumes bool) error {
+	path := projectPath(ref)
+	if removeVolumes {
+		path += "?volumes=true"
+	}
+	return b.doJSON(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// Deploy starts a deployment and, if wait is set, polls until it finishes
+func (b *remoteBackend) Deploy(ctx context.Context, ref string, wait bool) (*models.Project, error) {
+	var project models.Project
+	if err := b.doJSON(ctx, http.MethodPost, projectPath(ref)+"/deploy", nil, &project); err != nil {
+		return nil, err
+	}
+
+	for wait && project.Status == models.StatusDeploying {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(deployPollInterval):
+		}
+		if err := b.doJSON(ctx, http.MethodGet, projectPath(project.ID), nil, &project); err != nil {
+			return nil, err
+		}
+	}
+
+	return &project, nil
+}
+
+func (b *remoteBackend) Logs(ctx context.Context, ref string, tail int, follow bool, w io.Writer) error {
+	path := fmt.Sprintf("%s/logs?tail=%d&follow=%t", projectPath(ref), tail, follow)
+	resp, err := b.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Backup downloads a database snapshot from the remote instance
+func (b *remoteBackend) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file %s already exists", path)
+	}
+
+	resp, err := b.do(context.Background(), http.MethodGet, "/backup", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to download backup: %w", err)
+	}
+	return f.Close()
+}
+
+func (b *remoteBackend) Close() {}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/api"
+	"github.com/mhenrichsen/slimdeploy/internal/backup"
+	"github.com/mhenrichsen/slimdeploy/internal/jobs"
+	"github.com/mhenrichsen/slimdeploy/internal/logs"
+	"github.com/mhenrichsen/slimdeploy/internal/monitor"
+	"github.com/mhenrichsen/slimdeploy/internal/watcher"
+	"github.com/mhenrichsen/slimdeploy/web"
+)
+
+// runServe starts the web server and the auto-deploy watcher
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: slimdeploy serve")
+		fmt.Fprintln(os.Stderr, "\nStarts the web UI, JSON API and auto-deploy watcher.")
+	}
+	flags.Parse(args)
+
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	log.Println("Starting SlimDeploy...")
+
+	// Load configuration from config file and environment
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	logConfig(config)
+
+	a, err := newApp(config)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	// Ensure Docker network exists
+	ctx := context.Background()
+	if err := a.dockerClient.EnsureNetwork(ctx); err != nil {
+		log.Printf("Warning: Failed to ensure Docker network: %v", err)
+	}
+
+	// Shells open when the previous process stopped were closed with it
+	if err := a.shellRepo.EndOpenSessions(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// Clean up expired sessions periodically
+	go func() {
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := a.authManager.CleanupExpiredSessions(); err != nil {
+				log.Printf("Failed to cleanup sessions: %v", err)
+			}
+		}
+	}()
+
+	// Parse templates
+	templates, err := parseTemplates()
+	if err != nil {
+		return fmt.Errorf("failed to parse templates: %w", err)
+	}
+
+	// Create handler
+	handler := a.newHandler(templates)
+
+	// Initialize watcher
+	watcherService := watcher.New(
+		a.projectRepo,
+		a.gitManager,
+		handler.DeployProject,
+		handler.SyncPreviews,
+		a.events,
+		config.WatchInterval,
+	)
+	watcherService.Start()
+	defer watcherService.Stop()
+
+	// Start collecting container resource usage
+	a.statsCollector.Start()
+	defer a.statsCollector.Stop()
+
+	// Detect crashing containers
+	crashMonitor := monitor.New(a.dockerClient, a.projectRepo, a.notifier)
+	crashMonitor.Start()
+	defer crashMonitor.Stop()
+
+	// Keep container logs after the containers are removed
+	logCollector := logs.NewCollector(a.dockerClient, a.projectRepo, a.logRepo, logs.DefaultInterval)
+	logCollector.Start()
+	defer logCollector.Stop()
+
+	// Run scheduled volume backups
+	if a.backupManager.Enabled() {
+		scheduler := backup.NewScheduler(a.backupManager, a.projectRepo, a.backupRepo, backup.CheckInterval)
+		scheduler.Start()
+		defer scheduler.Stop()
+	}
+
+	// Run scheduled jobs
+	defer a.jobRunner.Stop()
+	jobScheduler := jobs.NewScheduler(a.jobRunner, a.jobRepo, jobs.CheckInterval)
+	jobScheduler.Start()
+	defer jobScheduler.Stop()
+
+	// Create static file server
+	staticSubFS, err := fs.Sub(web.StaticFS, "static")
+	if err != nil {
+		return fmt.Errorf("failed to create static FS: %w", err)
+	}
+
+	// Create router
+	router := api.NewRouter(handler, a.authManager, http.FS(staticSubFS), config.MetricsToken, config.WebhookSecret)
+
+	// Create server
+	server := &http.Server{
+		Addr:         config.ListenAddr,
+		Handler:      router,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 30 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+	// Shutdown waits for open requests, so end the event streams
+	server.RegisterOnShutdown(a.events.Close)
+
+	// Start server in goroutine
+	go func() {
+		log.Printf("Server listening on %s", config.ListenAddr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for shutdown signal
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server...")
+
+	// Graceful shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+
+	log.Println("Server stopped")
+	return nil
+}
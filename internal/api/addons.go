@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// AddonView wraps an add-on with the names of its linked projects
+type AddonView struct {
+	*models.Addon
+	Projects []string
+}
+
+// AddonsData is the data for the add-ons template
+type AddonsData struct {
+	TemplateData
+	Addons []AddonView
+	Specs  []models.AddonSpec
+	Form   *models.Addon
+	// Deploying is set while any add-on is starting, to keep polling
+	Deploying bool
+}
+
+// Addons lists the managed add-ons
+func (h *Handler) Addons(w http.ResponseWriter, r *http.Request) {
+	h.renderAddons(w, "", &models.Addon{Type: models.AddonPostgres})
+}
+
+// renderAddons renders the add-ons page with the create form filled from form
+func (h *Handler) renderAddons(w http.ResponseWriter, errMsg string, form *models.Addon) {
+	addons, err := h.addonRepo.List()
+	if err != nil {
+		log.Printf("Failed to list add-ons: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	deploying := false
+	views := make([]AddonView, len(addons))
+	for i, addon := range addons {
+		views[i] = AddonView{Addon: addon}
+		if addon.Status == models.StatusDeploying {
+			deploying = true
+		}
+		links, err := h.addonRepo.ListLinksByAddon(addon.ID)
+		if err != nil {
+			log.Printf("Failed to list links of add-on %s: %v", addon.Name, err)
+			continue
+		}
+		for _, link := range links {
+			if project, err := h.projectRepo.GetByID(link.ProjectID); err == nil && project != nil {
+				views[i].Projects = append(views[i].Projects, project.Name)
+			}
+		}
+	}
+
+	h.render(w, "addons.html", AddonsData{
+		TemplateData: TemplateData{
+			Title:      "Add-ons",
+			Error:      errMsg,
+			BaseDomain: h.baseDomain,
+		},
+		Addons:    views,
+		Specs:     models.AddonSpecs,
+		Form:      form,
+		Deploying: deploying,
+	})
+}
+
+// CreateAddon creates an add-on with generated credentials and starts it
+func (h *Handler) CreateAddon(w http.ResponseWriter, r *http.Request) {
+	addon := &models.Addon{
+		ID:      uuid.New().String(),
+		Name:    strings.ToLower(strings.TrimSpace(r.FormValue("name"))),
+		Type:    models.AddonType(r.FormValue("type")),
+		Version: strings.TrimSpace(r.FormValue("version")),
+		Status:  models.StatusPending,
+	}
+	if spec, ok := models.GetAddonSpec(addon.Type); ok && addon.Version == "" {
+		addon.Version = spec.DefaultVersion
+	}
+
+	// Validate
+	if err := addon.Validate(); err != nil {
+		h.renderAddons(w, err.Error(), addon)
+		return
+	}
+	existing, err := h.addonRepo.GetByName(addon.Name)
+	if err != nil {
+		log.Printf("Failed to check for duplicate add-on: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if existing != nil {
+		h.renderAddons(w, "An add-on with this name already exists", addon)
+		return
+	}
+
+	// Generate credentials
+	password, err := generateToken()
+	if err != nil {
+		log.Printf("Failed to generate add-on password: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	addon.Password = password
+	if addon.Type != models.AddonRedis {
+		addon.Username = "slimdeploy"
+		addon.Database = strings.ReplaceAll(addon.Name, "-", "_")
+	}
+
+	if err := h.addonRepo.Create(addon); err != nil {
+		log.Printf("Failed to create add-on: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.startAddon(addon)
+
+	http.Redirect(w, r, "/addons", http.StatusSeeOther)
+}
+
+// RestartAddon recreates an add-on's container, keeping its data
+func (h *Handler) RestartAddon(w http.ResponseWriter, r *http.Request) {
+	addon, err := h.addonRepo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Failed to get add-on: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if addon == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.startAddon(addon)
+
+	http.Redirect(w, r, "/addons", http.StatusSeeOther)
+}
+
+// startAddon marks an add-on as deploying and runs it in the background
+func (h *Handler) startAddon(addon *models.Addon) {
+	h.addonRepo.UpdateStatus(addon.ID, models.StatusDeploying, "Starting...", addon.ContainerID)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+
+		containerID, err := h.dockerClient.RunAddon(ctx, addon)
+		if err != nil {
+			log.Printf("Failed to start add-on %s: %v", addon.Name, err)
+			h.addonRepo.UpdateStatus(addon.ID, models.StatusError, err.Error(), "")
+			return
+		}
+		h.addonRepo.UpdateStatus(addon.ID, models.StatusRunning, "", containerID)
+	}()
+}
+
+// DeleteAddon removes an add-on's container and unlinks it from projects.
+// Its data volume is kept unless remove_volume is set.
+func (h *Handler) DeleteAddon(w http.ResponseWriter, r *http.Request) {
+	addon, err := h.addonRepo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Failed to get add-on: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if addon == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	removeVolume := r.FormValue("remove_volume") == "on"
+	if err := h.dockerClient.RemoveAddon(r.Context(), addon, removeVolume); err != nil {
+		log.Printf("Failed to remove add-on %s: %v", addon.Name, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.addonRepo.Delete(addon.ID); err != nil {
+		log.Printf("Failed to delete add-on: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/addons")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Redirect(w, r, "/addons", http.StatusSeeOther)
+}
+
+// addonLinkForm returns all add-ons and the env var each is linked to the
+// project with
+func (h *Handler) addonLinkForm(projectID string) ([]*models.Addon, map[string]string) {
+	addons, err := h.addonRepo.List()
+	if err != nil {
+		log.Printf("Failed to list add-ons: %v", err)
+	}
+
+	linked := make(map[string]string)
+	links, err := h.addonRepo.ListLinks(projectID)
+	if err != nil {
+		log.Printf("Failed to list add-on links: %v", err)
+	}
+	for _, link := range links {
+		linked[link.AddonID] = link.EnvVar
+	}
+	return addons, linked
+}
+
+// parseAddonLinks parses the add-on checkboxes of the project form. Linked
+// add-ons without an env var name use their type's default.
+func parseAddonLinks(r *http.Request, projectID string, addons []*models.Addon) ([]models.AddonLink, error) {
+	var links []models.AddonLink
+	for _, addon := range addons {
+		if r.FormValue("addon_"+addon.ID) != "on" {
+			continue
+		}
+		envVar := strings.TrimSpace(r.FormValue("addon_env_" + addon.ID))
+		if envVar == "" {
+			envVar = addon.Spec().EnvVar
+		}
+		links = append(links, models.AddonLink{ProjectID: projectID, AddonID: addon.ID, EnvVar: envVar})
+	}
+	return links, models.ValidateAddonLinks(links)
+}
+
+// injectAddonEnv adds the connection URLs of a project's linked add-ons to
+// its environment for this deploy. Variables set on the project win.
+func (h *Handler) injectAddonEnv(project *models.Project) error {
+	// Previews use the add-ons of the project they preview
+	owner := project.ID
+	if project.IsPreview() {
+		owner = project.PreviewOf
+	}
+	links, err := h.addonRepo.ListLinks(owner)
+	if err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+
+	env := make(map[string]string, len(project.EnvVars)+len(links))
+	for _, link := range links {
+		addon, err := h.addonRepo.GetByID(link.AddonID)
+		if err != nil {
+			return err
+		}
+		if addon == nil {
+			continue
+		}
+		if addon.Status != models.StatusRunning {
+			log.Printf("Warning: add-on %s linked to %s is %s", addon.Name, project.Name, addon.Status)
+		}
+		env[link.EnvVar] = addon.ConnectionURL()
+	}
+	for k, v := range project.EnvVars {
+		env[k] = v
+	}
+	project.EnvVars = env
+	return nil
+}
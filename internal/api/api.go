@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// ValidationError is returned when user-supplied project data is invalid
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// SaveNewProject fills in defaults for a new project, validates it and
+// stores it. Invalid input is reported as a *ValidationError.
+func (h *Handler) SaveNewProject(project *models.Project) error {
+	project.Name = strings.TrimSpace(project.Name)
+	if project.ID == "" {
+		project.ID = uuid.New().String()
+	}
+	if project.DeployType != models.DeployTypeCompose {
+		project.DeployType = models.DeployTypeImage
+	}
+	if project.Port <= 0 {
+		project.Port = 80
+	}
+	if project.EnvVars == nil {
+		project.EnvVars = make(map[string]string)
+	}
+	project.Status = models.StatusPending
+	h.resolveBranch(project)
+
+	msg, err := h.validateNewProject(project)
+	if err != nil {
+		return fmt.Errorf("failed to validate project: %w", err)
+	}
+	if msg != "" {
+		return &ValidationError{Message: msg}
+	}
+
+	return h.projectRepo.Create(project)
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Failed to encode JSON response: %v", err)
+	}
+}
+
+// writeJSONError writes a JSON error response
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// apiProject looks up the project referenced by the {ref} URL parameter,
+// which may be either a project ID or a project name
+func (h *Handler) apiProject(w http.ResponseWriter, r *http.Request) *models.Project {
+	ref := chi.URLParam(r, "ref")
+
+	project, err := h.projectRepo.GetByID(ref)
+	if err == nil && project == nil {
+		project, err = h.projectRepo.GetByName(ref)
+	}
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get project")
+		return nil
+	}
+	if project == nil {
+		writeJSONError(w, http.StatusNotFound, "project not found")
+		return nil
+	}
+	return project
+}
+
+// APIListProjects returns all projects
+func (h *Handler) APIListProjects(w http.ResponseWriter, r *http.Request) {
+	projects, err := h.projectRepo.List()
+	if err != nil {
+		log.Printf("Failed to list projects: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to list projects")
+		return
+	}
+	if projects == nil {
+		projects = []*models.Project{}
+	}
+	writeJSON(w, http.StatusOK, projects)
+}
+
+// APIGetProject returns a single project
+func (h *Handler) APIGetProject(w http.ResponseWriter, r *http.Request) {
+	project := h.apiProject(w, r)
+	if project == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, project)
+}
+
+// APICreateProject creates a project from a JSON body
+func (h *Handler) APICreateProject(w http.ResponseWriter, r *http.Request) {
+	var project models.Project
+	if err := json.NewDecoder(r.Body).Decode(&project); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	project.ID = ""
+	project.PreviewOf = ""
+	project.PreviewExpiresAt = time.Time{}
+
+	if err := h.SaveNewProject(&project); err != nil {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			writeJSONError(w, http.StatusBadRequest, verr.Message)
+			return
+		}
+		log.Printf("Failed to create project: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to create project")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, project)
+}
+
+// APIDeleteProject deletes a project and its containers. Pass volumes=true
+// to also remove its volumes.
+func (h *Handler) APIDeleteProject(w http.ResponseWriter, r *http.Request) {
+	project := h.apiProject(w, r)
+	if project == nil {
+		return
+	}
+
+	removeVolumes := r.URL.Query().Get("volumes") == "true"
+	if err := h.RemoveProject(r.Context(), project, removeVolumes); err != nil {
+		log.Printf("Failed to delete project: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to delete project")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIDeploy starts a deployment in the background
+func (h *Handler) APIDeploy(w http.ResponseWriter, r *http.Request) {
+	project := h.apiProject(w, r)
+	if project == nil {
+		return
+	}
+
+	if project.Status == models.StatusDeploying {
+		writeJSONError(w, http.StatusConflict, "project is already deploying")
+		return
+	}
+
+	h.startDeploy(project, models.TriggerAPI)
+
+	project, _ = h.projectRepo.GetByID(project.ID)
+	writeJSON(w, http.StatusAccepted, project)
+}
+
+// APILogs streams a project's container logs as plain text
+func (h *Handler) APILogs(w http.ResponseWriter, r *http.Request) {
+	project := h.apiProject(w, r)
+	if project == nil {
+		return
+	}
+
+	follow := r.URL.Query().Get("follow") == "true"
+	tail := 100
+	if t, err := strconv.Atoi(r.URL.Query().Get("tail")); err == nil && t >= 0 {
+		tail = t
+	}
+
+	// Following logs outlives the server's write timeout
+	if follow {
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := h.dockerClient.StreamProjectLogs(r.Context(), project.ID, tail, follow, w); err != nil {
+		log.Printf("Failed to stream logs for %s: %v", project.Name, err)
+		fmt.Fprintf(w, "error: %v\n", err)
+	}
+}
+
+// APIBackup streams a consistent snapshot of the SQLite database
+func (h *Handler) APIBackup(w http.ResponseWriter, r *http.Request) {
+	tmpDir, err := os.MkdirTemp("", "slimdeploy-backup-")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to create temp dir")
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "slimdeploy.db")
+	if err := h.database.Backup(path); err != nil {
+		log.Printf("Failed to back up database: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to back up database")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="slimdeploy.db"`)
+	http.ServeFile(w, r, path)
+}
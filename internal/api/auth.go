@@ -2,12 +2,17 @@ package api
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -34,20 +39,102 @@ func (am *AuthManager) ValidatePassword(password string) bool {
 	return subtle.ConstantTimeCompare([]byte(am.password), []byte(password)) == 1
 }
 
+// ValidateCredentials checks a username and password. An empty username
+// validates against the shared instance password.
+func (am *AuthManager) ValidateCredentials(username, password string) bool {
+	if username == "" {
+		return am.ValidatePassword(password)
+	}
+
+	var hash string
+	err := am.db.QueryRow("SELECT password_hash FROM users WHERE username = ?", username).Scan(&hash)
+	if err != nil {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// AddUser creates a user with the given password
+func (am *AuthManager) AddUser(username, password string) error {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if password == "" {
+		return fmt.Errorf("password is required")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	_, err = am.db.Exec(
+		"INSERT INTO users (username, password_hash, created_at) VALUES (?, ?, ?)",
+		username, string(hash), time.Now(),
+	)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return fmt.Errorf("user %s already exists", username)
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUser removes a user and their sessions
+func (am *AuthManager) DeleteUser(username string) error {
+	result, err := am.db.Exec("DELETE FROM users WHERE username = ?", username)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user not found")
+	}
+
+	_, err = am.db.Exec("DELETE FROM sessions WHERE username = ?", username)
+	return err
+}
+
+// ListUsers returns all usernames
+func (am *AuthManager) ListUsers() ([]string, error) {
+	rows, err := am.db.Query("SELECT username FROM users ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, username)
+	}
+	return users, nil
+}
+
 // CreateSession creates a new session and returns the token
-func (am *AuthManager) CreateSession() (string, error) {
+func (am *AuthManager) CreateSession(username string) (string, error) {
 	// Generate random token
-	tokenBytes := make([]byte, 32)
-	if _, err := rand.Read(tokenBytes); err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+	token, err := generateToken()
+	if err != nil {
+		return "", err
 	}
-	token := hex.EncodeToString(tokenBytes)
 
 	// Store session
 	expiresAt := time.Now().Add(sessionDuration)
-	_, err := am.db.Exec(
-		"INSERT INTO sessions (token, expires_at) VALUES (?, ?)",
-		token, expiresAt,
+	_, err = am.db.Exec(
+		"INSERT INTO sessions (token, username, expires_at) VALUES (?, ?, ?)",
+		token, username, expiresAt,
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
@@ -136,3 +223,92 @@ func (am *AuthManager) IsAuthenticated(r *http.Request) bool {
 	token := am.GetSessionFromRequest(r)
 	return am.ValidateSession(token)
 }
+
+// SessionUser returns the username of the request's session. Sessions created
+// with the shared password have no username and return an empty string.
+func (am *AuthManager) SessionUser(r *http.Request) string {
+	token := am.GetSessionFromRequest(r)
+	if token == "" {
+		return ""
+	}
+
+	var username string
+	if err := am.db.QueryRow("SELECT username FROM sessions WHERE token = ?", token).Scan(&username); err != nil {
+		return ""
+	}
+	return username
+}
+
+// CreateAPIToken creates a named API token and returns its plaintext value.
+// Only a hash of the token is stored.
+func (am *AuthManager) CreateAPIToken(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("token name is required")
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = am.db.Exec(
+		"INSERT INTO api_tokens (id, name, token_hash, created_at) VALUES (?, ?, ?, ?)",
+		uuid.New().String(), name, hashToken(token), time.Now(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	return token, nil
+}
+
+// ValidateAPIToken checks if an API token is valid and records its use
+func (am *AuthManager) ValidateAPIToken(token string) bool {
+	if token == "" {
+		return false
+	}
+
+	result, err := am.db.Exec(
+		"UPDATE api_tokens SET last_used_at = ? WHERE token_hash = ?",
+		time.Now(), hashToken(token),
+	)
+	if err != nil {
+		return false
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return err == nil && rowsAffected == 1
+}
+
+// GetAPITokenFromRequest gets the bearer token from the Authorization header
+func (am *AuthManager) GetAPITokenFromRequest(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// IsAPIAuthenticated checks if the request carries a valid API token or session
+func (am *AuthManager) IsAPIAuthenticated(r *http.Request) bool {
+	if token := am.GetAPITokenFromRequest(r); token != "" {
+		return am.ValidateAPIToken(token)
+	}
+	return am.IsAuthenticated(r)
+}
+
+// generateToken generates a random hex-encoded token
+func generateToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+// hashToken returns the hex-encoded SHA-256 hash of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
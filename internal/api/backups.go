@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// ProjectBackupsData is the data for the project_backups partial
+type ProjectBackupsData struct {
+	Project *models.Project
+	Backups []*models.Backup
+	Enabled bool
+	Busy    bool
+	Error   string
+	Success string
+}
+
+// renderBackups renders the backups partial of a project
+func (h *Handler) renderBackups(w http.ResponseWriter, project *models.Project, data ProjectBackupsData) {
+	backups, err := h.backupRepo.ListByProject(project.ID)
+	if err != nil {
+		log.Printf("Failed to list backups: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data.Project = project
+	data.Backups = backups
+	data.Enabled = h.backups.Enabled()
+	data.Busy = data.Busy || h.backups.IsBusy(project.ID)
+	h.renderPartial(w, "project_backups", data)
+}
+
+// ProjectBackups lists a project's volume backups (for polling)
+func (h *Handler) ProjectBackups(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.renderBackups(w, project, ProjectBackupsData{})
+}
+
+// BackupProject starts a backup of a project's volumes
+func (h *Handler) BackupProject(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	var data ProjectBackupsData
+	switch {
+	case !h.backups.Enabled():
+		data.Error = "Backup storage is not configured"
+	case len(project.VolumeNames()) == 0:
+		data.Error = "This project has no volumes to back up"
+	case h.backups.IsBusy(project.ID):
+		data.Error = "A backup or restore is already running"
+	default:
+		go func() {
+			if err := h.backups.BackupProject(context.Background(), project); err != nil {
+				log.Printf("Backup of %s failed: %v", project.Name, err)
+			}
+		}()
+		data.Success = "Backup started"
+		data.Busy = true
+	}
+
+	h.renderBackups(w, project, data)
+}
+
+// RestoreBackup restores a volume backup into a project. The project's
+// containers are stopped during the restore and the project is redeployed
+// afterwards.
+func (h *Handler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	b, err := h.backupRepo.GetByID(chi.URLParam(r, "backupID"))
+	if err != nil {
+		log.Printf("Failed to get backup: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if b == nil || b.ProjectID != project.ID {
+		http.NotFound(w, r)
+		return
+	}
+
+	var data ProjectBackupsData
+	switch {
+	case !h.backups.Enabled():
+		data.Error = "Backup storage is not configured"
+	case b.Status != models.BackupSuccess:
+		data.Error = "Only successful backups can be restored"
+	case h.backups.IsBusy(project.ID) || project.Status == models.StatusDeploying:
+		data.Error = "The project is busy; try again when the current operation has finished"
+	default:
+		h.projectRepo.UpdateStatus(project.ID, models.StatusDeploying, "Restoring volume "+b.Volume+" from backup...")
+		go h.restoreBackup(project, b)
+
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Refresh", "true")
+		}
+		data.Success = "Restore started"
+	}
+
+	h.renderBackups(w, project, data)
+}
+
+// restoreBackup stops a project, restores a volume and redeploys the project
+func (h *Handler) restoreBackup(project *models.Project, b *models.Backup) {
+	ctx := context.Background()
+
+	if err := h.dockerClient.StopProjectContainers(ctx, project.ID); err != nil {
+		log.Printf("Failed to stop containers of %s for restore: %v", project.Name, err)
+		h.projectRepo.UpdateStatus(project.ID, models.StatusError, "Restore failed: "+err.Error())
+		return
+	}
+
+	if err := h.backups.Restore(ctx, project, b); err != nil {
+		log.Printf("Restore of %s failed: %v", project.Name, err)
+		h.projectRepo.UpdateStatus(project.ID, models.StatusError, "Restore failed: "+err.Error())
+		return
+	}
+
+	if err := h.DeployProject(ctx, project, models.TriggerManual); err != nil {
+		log.Printf("Deployment after restore failed for %s: %v", project.Name, err)
+		h.projectRepo.UpdateStatus(project.ID, models.StatusError, err.Error())
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// ProjectLabelsData is the data for the project_labels partial
+type ProjectLabelsData struct {
+	Project *models.Project
+	// Labels are the labels of each compose service, keyed by service name
+	Labels map[string]map[string]string
+	// Invalid explains why the Traefik labels would fail the deploy
+	Invalid string
+	Error   string
+}
+
+// ProjectLabels renders the labels a compose project's services would be
+// deployed with, from its current checkout
+func (h *Handler) ProjectLabels(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := ProjectLabelsData{Project: project}
+	switch {
+	case project.DeployType != models.DeployTypeCompose:
+		data.Error = "Only compose projects have generated labels"
+	case !h.gitManager.Exists(project.Name):
+		data.Error = "Deploy the project once to fetch its compose file"
+	default:
+		data.Labels, err = h.composeManager.RenderLabels(r.Context(), project)
+		if err != nil {
+			data.Error = err.Error()
+		} else if project.Compose.MergeTraefikLabels {
+			if err := docker.ValidateTraefikLabels(data.Labels); err != nil {
+				data.Invalid = err.Error()
+			}
+		}
+	}
+
+	h.renderPartial(w, "project_labels", data)
+}
+
+// ProjectComposeData is the data for the project_compose partial
+type ProjectComposeData struct {
+	Project *models.Project
+	Preview *docker.ComposePreview
+	Error   string
+}
+
+// dryRunCompose generates the compose file a deploy of the project would
+// run, with the environment a deploy gets
+func (h *Handler) dryRunCompose(ctx context.Context, project *models.Project) (*docker.ComposePreview, error) {
+	if err := h.injectAddonEnv(project); err != nil {
+		return nil, fmt.Errorf("failed to resolve add-ons: %w", err)
+	}
+	return h.composeManager.DryRun(ctx, project)
+}
+
+// ProjectCompose renders the compose file a deploy of a compose project
+// would run, from its current checkout, and its diff against the deployed one
+func (h *Handler) ProjectCompose(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	data := ProjectComposeData{Project: project}
+	switch {
+	case project.DeployType != models.DeployTypeCompose:
+		data.Error = "Only compose projects have a generated compose file"
+	case !h.gitManager.Exists(project.Name):
+		data.Error = "Deploy the project once to fetch its compose file"
+	default:
+		data.Preview, err = h.dryRunCompose(r.Context(), project)
+		if err != nil {
+			data.Error = err.Error()
+		}
+	}
+
+	h.renderPartial(w, "project_compose", data)
+}
+
+// APIComposePreview returns the compose file a deploy of a compose project
+// would run, its diff against the deployed one and whether it is valid
+func (h *Handler) APIComposePreview(w http.ResponseWriter, r *http.Request) {
+	project := h.apiProject(w, r)
+	if project == nil {
+		return
+	}
+
+	if project.DeployType != models.DeployTypeCompose {
+		writeJSONError(w, http.StatusBadRequest, "only compose projects have a generated compose file")
+		return
+	}
+	if !h.gitManager.Exists(project.Name) {
+		writeJSONError(w, http.StatusConflict, "project has not been checked out yet, deploy it once")
+		return
+	}
+
+	preview, err := h.dryRunCompose(r.Context(), project)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, preview)
+}
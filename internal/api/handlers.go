@@ -1,22 +1,29 @@
 package api
 
 import (
-	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/mhenrichsen/slimdeploy/internal/backup"
 	"github.com/mhenrichsen/slimdeploy/internal/db"
 	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/events"
 	gitpkg "github.com/mhenrichsen/slimdeploy/internal/git"
+	"github.com/mhenrichsen/slimdeploy/internal/jobs"
+	"github.com/mhenrichsen/slimdeploy/internal/metrics"
 	"github.com/mhenrichsen/slimdeploy/internal/models"
+	"github.com/mhenrichsen/slimdeploy/internal/notify"
+	"github.com/mhenrichsen/slimdeploy/internal/stats"
 )
 
 // TemplateExecutor is an interface for executing templates
@@ -27,31 +34,67 @@ type TemplateExecutor interface {
 // Handler handles HTTP requests
 type Handler struct {
 	templates      TemplateExecutor
+	database       *db.DB
 	projectRepo    *db.ProjectRepository
+	addonRepo      *db.AddonRepository
 	dockerClient   *docker.Client
 	composeManager *docker.ComposeManager
 	gitManager     *gitpkg.Manager
 	auth           *AuthManager
+	stats          *stats.Collector
+	backupRepo     *db.BackupRepository
+	backups        *backup.Manager
+	logRepo        *db.LogRepository
+	shellRepo      *db.ShellRepository
+	jobRepo        *db.JobRepository
+	jobs           *jobs.Runner
+	notifyRepo     *db.NotificationRepository
+	notifier       *notify.Notifier
+	events         *events.Bus
 	baseDomain     string
 }
 
 // NewHandler creates a new handler
 func NewHandler(
 	templates TemplateExecutor,
+	database *db.DB,
 	projectRepo *db.ProjectRepository,
+	addonRepo *db.AddonRepository,
 	dockerClient *docker.Client,
 	composeManager *docker.ComposeManager,
 	gitManager *gitpkg.Manager,
 	auth *AuthManager,
+	statsCollector *stats.Collector,
+	backupRepo *db.BackupRepository,
+	backupManager *backup.Manager,
+	logRepo *db.LogRepository,
+	shellRepo *db.ShellRepository,
+	jobRepo *db.JobRepository,
+	jobRunner *jobs.Runner,
+	notifyRepo *db.NotificationRepository,
+	notifier *notify.Notifier,
+	bus *events.Bus,
 	baseDomain string,
 ) *Handler {
 	return &Handler{
 		templates:      templates,
+		database:       database,
 		projectRepo:    projectRepo,
+		addonRepo:      addonRepo,
 		dockerClient:   dockerClient,
 		composeManager: composeManager,
 		gitManager:     gitManager,
 		auth:           auth,
+		stats:          statsCollector,
+		backupRepo:     backupRepo,
+		backups:        backupManager,
+		logRepo:        logRepo,
+		shellRepo:      shellRepo,
+		jobRepo:        jobRepo,
+		jobs:           jobRunner,
+		notifyRepo:     notifyRepo,
+		notifier:       notifier,
+		events:         bus,
 		baseDomain:     baseDomain,
 	}
 }
@@ -73,15 +116,35 @@ type ProjectCardData struct {
 // DashboardData is the data for the dashboard template
 type DashboardData struct {
 	TemplateData
-	Projects    []*models.Project
+	Projects     []*models.Project
 	ProjectCards []ProjectCardData
 }
 
 // ProjectData is the data for the project template
 type ProjectData struct {
 	TemplateData
-	Project *models.Project
-	IsNew   bool
+	Project    *models.Project
+	IsNew      bool
+	HasVolumes bool
+	// Services are the project's container services, for the log filter
+	// and the terminal
+	Services []string
+	// ShellSessions are the most recent terminal sessions, newest first
+	ShellSessions []*models.ShellSession
+	// Previews are the project's branch previews, and PreviewOf the project
+	// a preview belongs to
+	Previews  []*models.Project
+	PreviewOf *models.Project
+
+	// Addons and AddonLinks (add-on ID to env var) fill the add-ons section
+	Addons     []*models.Addon
+	AddonLinks map[string]string
+
+	// Channels and Subscriptions (channel ID to subscribed events) fill the
+	// notifications section
+	Channels      []*models.NotificationChannel
+	Subscriptions map[string]map[models.EventType]bool
+	EventTypes    []models.EventTypeOption
 }
 
 // render renders a template
@@ -114,18 +177,19 @@ func (h *Handler) LoginPage(w http.ResponseWriter, r *http.Request) {
 
 // Login handles login form submission
 func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	username := strings.TrimSpace(r.FormValue("username"))
 	password := r.FormValue("password")
 
-	if !h.auth.ValidatePassword(password) {
+	if !h.auth.ValidateCredentials(username, password) {
 		h.render(w, "login.html", TemplateData{
 			Title: "Login",
-			Error: "Invalid password",
+			Error: "Invalid username or password",
 		})
 		return
 	}
 
 	// Create session
-	token, err := h.auth.CreateSession()
+	token, err := h.auth.CreateSession(username)
 	if err != nil {
 		log.Printf("Failed to create session: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -201,6 +265,7 @@ func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 		Name:         strings.TrimSpace(r.FormValue("name")),
 		GitURL:       strings.TrimSpace(r.FormValue("git_url")),
 		Branch:       strings.TrimSpace(r.FormValue("branch")),
+		TagRule:      strings.TrimSpace(r.FormValue("tag_rule")),
 		Image:        strings.TrimSpace(r.FormValue("image")),
 		Domain:       strings.TrimSpace(r.FormValue("domain")),
 		UseSubdomain: r.FormValue("use_subdomain") == "on",
@@ -224,48 +289,26 @@ func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Auto-detect default branch if not specified and git URL is provided
-	if project.Branch == "" && project.GitURL != "" {
-		detectedBranch, err := h.gitManager.GetDefaultBranch(project.GitURL)
-		if err != nil {
-			log.Printf("Failed to detect default branch for %s: %v, using 'main'", project.GitURL, err)
-			project.Branch = "main"
-		} else {
-			log.Printf("Detected default branch for %s: %s", project.GitURL, detectedBranch)
-			project.Branch = detectedBranch
-		}
-	} else if project.Branch == "" {
-		project.Branch = "main"
-	}
+	h.resolveBranch(project)
 
 	// Parse environment variables
 	project.EnvVars = parseEnvVars(r.FormValue("env_vars"))
 
-	// Validate
-	if project.Name == "" {
-		h.render(w, "project.html", ProjectData{
-			TemplateData: TemplateData{
-				Title:      "New Project",
-				Error:      "Project name is required",
-				BaseDomain: h.baseDomain,
-			},
-			Project: project,
-			IsNew:   true,
-		})
-		return
-	}
+	// Parse compose file locations
+	project.Compose = parseComposeConfig(r)
 
-	// Check for duplicate name
-	existing, err := h.projectRepo.GetByName(project.Name)
+	// Validate
+	msg, err := h.validateNewProject(project)
 	if err != nil {
 		log.Printf("Failed to check for duplicate: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	if existing != nil {
+	if msg != "" {
 		h.render(w, "project.html", ProjectData{
 			TemplateData: TemplateData{
 				Title:      "New Project",
-				Error:      "A project with this name already exists",
+				Error:      msg,
 				BaseDomain: h.baseDomain,
 			},
 			Project: project,
@@ -285,6 +328,106 @@ func (h *Handler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("/projects/%s", project.ID), http.StatusSeeOther)
 }
 
+// resolveBranch fills in the project's branch, detecting the remote's
+// default branch when a git URL is set and no branch was given
+func (h *Handler) resolveBranch(project *models.Project) {
+	if project.Branch == "" && project.GitURL != "" {
+		detectedBranch, err := h.gitManager.GetDefaultBranch(project.GitURL)
+		if err != nil {
+			log.Printf("Failed to detect default branch for %s: %v, using 'main'", project.GitURL, err)
+			project.Branch = "main"
+		} else {
+			log.Printf("Detected default branch for %s: %s", project.GitURL, detectedBranch)
+			project.Branch = detectedBranch
+		}
+	} else if project.Branch == "" {
+		project.Branch = "main"
+	}
+}
+
+// validateNewProject returns a user-facing message if the project can't be
+// created, or an error if validation itself failed
+func (h *Handler) validateNewProject(project *models.Project) (string, error) {
+	if project.Name == "" {
+		return "Project name is required", nil
+	}
+	if msg := h.validateProjectSettings(project); msg != "" {
+		return msg, nil
+	}
+
+	// Check for duplicate name
+	existing, err := h.projectRepo.GetByName(project.Name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		return "A project with this name already exists", nil
+	}
+
+	return "", nil
+}
+
+// validateProjectSettings checks the settings shared by new and edited
+// projects and returns a user-facing message if they are invalid
+func (h *Handler) validateProjectSettings(project *models.Project) string {
+	if project.TagRule != "" && project.GitURL == "" {
+		return "Deploying tags needs a git repository"
+	}
+	if err := gitpkg.ValidateTagRule(project.TagRule); err != nil {
+		return err.Error()
+	}
+	if project.PinnedCommit != "" {
+		if project.GitURL == "" {
+			return "Pinning a commit needs a git repository"
+		}
+		if project.TagRule != "" {
+			return "A project can't be pinned to a commit and track tags at once"
+		}
+		if !gitpkg.IsCommitHash(project.PinnedCommit) {
+			return "Pinned commit must be a commit hash of at least 7 characters"
+		}
+	}
+	if err := project.Resources.Validate(); err != nil {
+		return err.Error()
+	}
+	if len(project.Mounts) > 0 && project.DeployType == models.DeployTypeCompose {
+		return "Volumes are only supported for image projects; declare them in the compose file instead"
+	}
+	if err := h.dockerClient.ValidateMounts(project.Mounts); err != nil {
+		return err.Error()
+	}
+	if err := project.Backup.Validate(); err != nil {
+		return err.Error()
+	}
+	if err := project.Previews.Validate(); err != nil {
+		return err.Error()
+	}
+	if err := project.Compose.Validate(); err != nil {
+		return err.Error()
+	}
+	if err := project.PathFilter.Validate(); err != nil {
+		return err.Error()
+	}
+	if len(project.Compose.Services) > 0 && project.DeployType != models.DeployTypeCompose {
+		return "Routed services are only supported for compose projects"
+	}
+	if project.PathFilter.Enabled() && project.GitURL == "" {
+		return "Path filters need a git repository"
+	}
+	if project.Previews.Enabled && (project.GitURL == "" || project.DeployType != models.DeployTypeCompose) {
+		return "Previews need a compose project with a git repository"
+	}
+	if project.Backup.Enabled {
+		if !h.backups.Enabled() {
+			return "Backups need backup storage; set BACKUP_S3_ENDPOINT and restart SlimDeploy"
+		}
+		if len(project.VolumeNames()) == 0 {
+			return "Backups need at least one named volume"
+		}
+	}
+	return ""
+}
+
 // ProjectDetail shows project details
 func (h *Handler) ProjectDetail(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "id")
@@ -300,12 +443,44 @@ func (h *Handler) ProjectDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Volumes may outlive their mount configuration, so ask Docker
+	volumes, err := h.dockerClient.ListProjectVolumes(r.Context(), project.ID)
+	if err != nil {
+		log.Printf("Failed to list volumes for %s: %v", project.Name, err)
+	}
+
+	services, err := h.dockerClient.ProjectLogServices(r.Context(), project.ID)
+	if err != nil {
+		log.Printf("Failed to list services for %s: %v", project.Name, err)
+	}
+
+	shells, err := h.shellRepo.ListByProject(project.ID, 10)
+	if err != nil {
+		log.Printf("Failed to list shell sessions for %s: %v", project.Name, err)
+	}
+
+	previews, err := h.projectRepo.ListPreviews(project.ID)
+	if err != nil {
+		log.Printf("Failed to list previews for %s: %v", project.Name, err)
+	}
+	var previewOf *models.Project
+	if project.IsPreview() {
+		if previewOf, err = h.projectRepo.GetByID(project.PreviewOf); err != nil {
+			log.Printf("Failed to get previewed project of %s: %v", project.Name, err)
+		}
+	}
+
 	h.render(w, "project_detail.html", ProjectData{
 		TemplateData: TemplateData{
 			Title:      project.Name,
 			BaseDomain: h.baseDomain,
 		},
-		Project: project,
+		Project:       project,
+		HasVolumes:    len(volumes) > 0,
+		Services:      services,
+		ShellSessions: shells,
+		Previews:      previews,
+		PreviewOf:     previewOf,
 	})
 }
 
@@ -324,13 +499,21 @@ func (h *Handler) EditProjectForm(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	addons, addonLinks := h.addonLinkForm(project.ID)
+	channels, subscriptions := h.subscriptionForm(project.ID)
+
 	h.render(w, "project.html", ProjectData{
 		TemplateData: TemplateData{
 			Title:      "Edit " + project.Name,
 			BaseDomain: h.baseDomain,
 		},
-		Project: project,
-		IsNew:   false,
+		Project:       project,
+		IsNew:         false,
+		Addons:        addons,
+		AddonLinks:    addonLinks,
+		Channels:      channels,
+		Subscriptions: subscriptions,
+		EventTypes:    models.EventTypes,
 	})
 }
 
@@ -353,6 +536,9 @@ func (h *Handler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 	project.Name = strings.TrimSpace(r.FormValue("name"))
 	project.GitURL = strings.TrimSpace(r.FormValue("git_url"))
 	project.Branch = strings.TrimSpace(r.FormValue("branch"))
+	project.TagRule = strings.TrimSpace(r.FormValue("tag_rule"))
+	pinnedCommit := project.PinnedCommit
+	project.PinnedCommit = strings.ToLower(strings.TrimSpace(r.FormValue("pinned_commit")))
 	project.Image = strings.TrimSpace(r.FormValue("image"))
 	project.Domain = strings.TrimSpace(r.FormValue("domain"))
 	project.UseSubdomain = r.FormValue("use_subdomain") == "on"
@@ -372,32 +558,109 @@ func (h *Handler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Auto-detect default branch if not specified and git URL is provided
-	if project.Branch == "" && project.GitURL != "" {
-		detectedBranch, err := h.gitManager.GetDefaultBranch(project.GitURL)
-		if err != nil {
-			log.Printf("Failed to detect default branch for %s: %v, using 'main'", project.GitURL, err)
-			project.Branch = "main"
-		} else {
-			log.Printf("Detected default branch for %s: %s", project.GitURL, detectedBranch)
-			project.Branch = detectedBranch
-		}
-	} else if project.Branch == "" {
-		project.Branch = "main"
-	}
+	h.resolveBranch(project)
 
 	// Parse environment variables
 	project.EnvVars = parseEnvVars(r.FormValue("env_vars"))
 
+	// Parse resource limits
+	limits, limitsErr := parseResourceLimits(r)
+	project.Resources = limits
+
+	// Parse mounts
+	mounts, mountsErr := parseMounts(r.FormValue("mounts"))
+	project.Mounts = mounts
+
+	// Parse backup policy
+	policy, policyErr := parseBackupPolicy(r)
+	project.Backup = policy
+
+	// Parse release commands
+	release, releaseErr := parseReleaseConfig(r)
+	project.Release = release
+
+	// Parse log retention
+	retention, retentionErr := parseLogRetention(r)
+	project.LogRetention = retention
+
+	// Parse preview settings
+	hadPreviews := project.Previews.Enabled
+	knownBranches := project.Previews.KnownBranches
+	previewConfig, previewsErr := parsePreviewConfig(r)
+	project.Previews = previewConfig
+	if hadPreviews && project.Previews.Enabled {
+		project.Previews.KnownBranches = knownBranches
+	}
+
+	// Parse compose file locations and routed services
+	project.Compose = parseComposeConfig(r)
+	services, servicesErr := parseRoutedServices(r.FormValue("compose_services"))
+	project.Compose.Services = services
+
+	// Parse auto-deploy path filters
+	project.PathFilter = models.PathFilter{
+		Include: parseLines(r.FormValue("deploy_paths")),
+		Exclude: parseLines(r.FormValue("ignore_paths")),
+	}
+
+	// Parse add-on links
+	addons, _ := h.addonLinkForm(project.ID)
+	links, linksErr := parseAddonLinks(r, project.ID, addons)
+
+	// Parse notification subscriptions
+	channels, _ := h.subscriptionForm(project.ID)
+	subs := parseSubscriptions(r, project.ID, channels)
+
 	// Validate
+	errMsg := ""
 	if project.Name == "" {
+		errMsg = "Project name is required"
+	} else if limitsErr != nil {
+		errMsg = limitsErr.Error()
+	} else if mountsErr != nil {
+		errMsg = mountsErr.Error()
+	} else if policyErr != nil {
+		errMsg = policyErr.Error()
+	} else if releaseErr != nil {
+		errMsg = releaseErr.Error()
+	} else if retentionErr != nil {
+		errMsg = retentionErr.Error()
+	} else if previewsErr != nil {
+		errMsg = previewsErr.Error()
+	} else if servicesErr != nil {
+		errMsg = servicesErr.Error()
+	} else if linksErr != nil {
+		errMsg = linksErr.Error()
+	} else {
+		errMsg = h.validateProjectSettings(project)
+	}
+	if errMsg == "" && project.Previews.Enabled && !hadPreviews {
+		// Existing branches get a preview on their next push, rather than
+		// all being deployed as soon as previews are turned on
+		branches, err := h.gitManager.ListRemoteBranches(project.GitURL)
+		if err != nil {
+			errMsg = "Failed to list branches: " + err.Error()
+		}
+		project.Previews.KnownBranches = branches
+	}
+	if errMsg != "" {
+		addonLinks := make(map[string]string)
+		for _, link := range links {
+			addonLinks[link.AddonID] = link.EnvVar
+		}
 		h.render(w, "project.html", ProjectData{
 			TemplateData: TemplateData{
 				Title:      "Edit Project",
-				Error:      "Project name is required",
+				Error:      errMsg,
 				BaseDomain: h.baseDomain,
 			},
-			Project: project,
-			IsNew:   false,
+			Project:       project,
+			IsNew:         false,
+			Addons:        addons,
+			AddonLinks:    addonLinks,
+			Channels:      channels,
+			Subscriptions: subscribedEvents(subs),
+			EventTypes:    models.EventTypes,
 		})
 		return
 	}
@@ -408,6 +671,28 @@ func (h *Handler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	if err := h.addonRepo.SetLinks(project.ID, links); err != nil {
+		log.Printf("Failed to update add-on links: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := h.notifyRepo.SetSubscriptions(project.ID, subs); err != nil {
+		log.Printf("Failed to update notification subscriptions: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// The watcher counts the commits ahead of a new pin on its next check
+	if project.PinnedCommit != pinnedCommit {
+		if err := h.projectRepo.UpdateCommitsAhead(project.ID, -1); err != nil {
+			log.Printf("Failed to reset commits ahead of %s: %v", project.Name, err)
+		}
+	}
+
+	// Tear down previews once they are turned off
+	if hadPreviews && !project.Previews.Enabled {
+		go h.removePreviews(context.Background(), project)
+	}
 
 	http.Redirect(w, r, fmt.Sprintf("/projects/%s", project.ID), http.StatusSeeOther)
 }
@@ -427,20 +712,8 @@ func (h *Handler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-
-	// Stop and remove containers
-	if project.DeployType == models.DeployTypeCompose {
-		h.composeManager.Down(ctx, project)
-	} else {
-		h.dockerClient.RemoveProjectContainers(ctx, project.ID)
-	}
-
-	// Remove git repository
-	h.gitManager.Remove(project.Name)
-
-	// Delete from database
-	if err := h.projectRepo.Delete(projectID); err != nil {
+	removeVolumes := r.FormValue("remove_volumes") == "on"
+	if err := h.RemoveProject(r.Context(), project, removeVolumes); err != nil {
 		log.Printf("Failed to delete project: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -456,6 +729,73 @@ func (h *Handler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
+// removeContainers stops and removes a project's containers
+func (h *Handler) removeContainers(ctx context.Context, project *models.Project) error {
+	if project.DeployType == models.DeployTypeCompose {
+		return h.composeManager.Down(ctx, project)
+	}
+	return h.dockerClient.RemoveProjectContainers(ctx, project.ID)
+}
+
+// RemoveProject stops and removes a project's containers, deletes its
+// repository checkout and removes it from the database. Volumes are kept
+// unless removeVolumes is set.
+func (h *Handler) RemoveProject(ctx context.Context, project *models.Project, removeVolumes bool) error {
+	// Previews go with the project they preview
+	h.removePreviews(ctx, project)
+
+	// Stop and remove containers
+	h.removeContainers(ctx, project)
+
+	// Remove volumes
+	if removeVolumes {
+		if err := h.dockerClient.RemoveProjectVolumes(ctx, project.ID); err != nil {
+			return err
+		}
+	}
+
+	// Unlink add-ons
+	if err := h.addonRepo.SetLinks(project.ID, nil); err != nil {
+		log.Printf("Failed to unlink add-ons of %s: %v", project.Name, err)
+	}
+
+	// Unsubscribe notification channels
+	if err := h.notifyRepo.SetSubscriptions(project.ID, nil); err != nil {
+		log.Printf("Failed to delete notification subscriptions of %s: %v", project.Name, err)
+	}
+
+	// Delete scheduled jobs and their history
+	if err := h.jobRepo.DeleteByProject(project.ID); err != nil {
+		log.Printf("Failed to delete jobs of %s: %v", project.Name, err)
+	}
+
+	// Forget backup records; the archives are kept in the bucket
+	if err := h.backupRepo.DeleteByProject(project.ID); err != nil {
+		log.Printf("Failed to delete backup records of %s: %v", project.Name, err)
+	}
+
+	// Delete collected logs
+	if err := h.logRepo.DeleteByProject(project.ID); err != nil {
+		log.Printf("Failed to delete logs of %s: %v", project.Name, err)
+	}
+
+	// Delete shell session records
+	if err := h.shellRepo.DeleteByProject(project.ID); err != nil {
+		log.Printf("Failed to delete shell sessions of %s: %v", project.Name, err)
+	}
+
+	// Remove git repository
+	h.gitManager.Remove(project.Name)
+
+	// Drop resource usage history
+	if h.stats != nil {
+		h.stats.Forget(project.ID)
+	}
+
+	// Delete from database
+	return h.projectRepo.Delete(project.ID)
+}
+
 // Deploy triggers a deployment
 func (h *Handler) Deploy(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "id")
@@ -471,18 +811,7 @@ func (h *Handler) Deploy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-
-	// Update status to deploying
-	h.projectRepo.UpdateStatus(project.ID, models.StatusDeploying, "Starting deployment...")
-
-	// Deploy asynchronously
-	go func() {
-		if err := h.deployProject(context.Background(), project); err != nil {
-			log.Printf("Deployment failed for %s: %v", project.Name, err)
-			h.projectRepo.UpdateStatus(project.ID, models.StatusError, err.Error())
-		}
-	}()
+	h.startDeploy(project, models.TriggerManual)
 
 	// Return updated project card for HTMX
 	if r.Header.Get("HX-Request") == "true" {
@@ -493,20 +822,55 @@ func (h *Handler) Deploy(w http.ResponseWriter, r *http.Request) {
 	}
 
 	http.Redirect(w, r, fmt.Sprintf("/projects/%s", project.ID), http.StatusSeeOther)
-	_ = ctx
+}
+
+// startDeploy marks a project as deploying and deploys it in the background
+func (h *Handler) startDeploy(project *models.Project, trigger models.DeployTrigger) {
+	// Update status to deploying
+	h.projectRepo.UpdateStatus(project.ID, models.StatusDeploying, "Starting deployment...")
+
+	// Deploy asynchronously
+	go func() {
+		if err := h.DeployProject(context.Background(), project, trigger); err != nil {
+			log.Printf("Deployment failed for %s: %v", project.Name, err)
+			h.projectRepo.UpdateStatus(project.ID, models.StatusError, err.Error())
+		}
+	}()
 }
 
 // deployProject performs the actual deployment
 func (h *Handler) deployProject(ctx context.Context, project *models.Project) error {
+	// Inject connection URLs of linked add-ons
+	if err := h.injectAddonEnv(project); err != nil {
+		return fmt.Errorf("failed to resolve add-ons: %w", err)
+	}
+
 	// Clone or pull git repo if configured
 	if project.GitURL != "" {
-		if h.gitManager.Exists(project.Name) {
-			if err := h.gitManager.Pull(project.GitURL, project.Branch, project.Name); err != nil {
-				return fmt.Errorf("failed to pull repository: %w", err)
+		if project.TagRule != "" {
+			if err := h.checkoutTag(project); err != nil {
+				return err
+			}
+		} else if project.PinnedCommit != "" {
+			if err := h.checkoutPin(project); err != nil {
+				return err
 			}
 		} else {
-			if err := h.gitManager.Clone(project.GitURL, project.Branch, project.Name); err != nil {
-				return fmt.Errorf("failed to clone repository: %w", err)
+			// A tag or commit checkout can't be pulled, so start over with
+			// the branch
+			if project.DeployedTag != "" || h.gitManager.IsDetached(project.Name) {
+				h.gitManager.Remove(project.Name)
+				h.projectRepo.UpdateDeployedTag(project.ID, "")
+				project.DeployedTag = ""
+			}
+			if h.gitManager.Exists(project.Name) {
+				if err := h.gitManager.Pull(project.GitURL, project.Branch, project.Name); err != nil {
+					return fmt.Errorf("failed to pull repository: %w", err)
+				}
+			} else {
+				if err := h.gitManager.Clone(project.GitURL, project.Branch, project.Name); err != nil {
+					return fmt.Errorf("failed to clone repository: %w", err)
+				}
 			}
 		}
 
@@ -514,6 +878,7 @@ func (h *Handler) deployProject(ctx context.Context, project *models.Project) er
 		commit, err := h.gitManager.GetLatestCommit(project.Name)
 		if err == nil {
 			h.projectRepo.UpdateLastCommit(project.ID, commit)
+			project.LastCommit = commit
 		}
 	}
 
@@ -521,6 +886,9 @@ func (h *Handler) deployProject(ctx context.Context, project *models.Project) er
 
 	if project.DeployType == models.DeployTypeCompose {
 		// Docker Compose deployment
+		if err := h.runReleaseCommand(ctx, project, project.Release.Command); err != nil {
+			return fmt.Errorf("release command failed: %w", err)
+		}
 		if err := h.composeManager.Up(ctx, project); err != nil {
 			return fmt.Errorf("docker compose up failed: %w", err)
 		}
@@ -533,6 +901,11 @@ func (h *Handler) deployProject(ctx context.Context, project *models.Project) er
 			}
 		}
 
+		// Run the release command before replacing the container
+		if err := h.runReleaseCommand(ctx, project, project.Release.Command); err != nil {
+			return fmt.Errorf("release command failed: %w", err)
+		}
+
 		// Run container
 		containerID, err := h.dockerClient.RunContainer(ctx, project)
 		if err != nil {
@@ -546,16 +919,119 @@ func (h *Handler) deployProject(ctx context.Context, project *models.Project) er
 		}
 	}
 
-	// Update project status
+	// Run the post-deploy hook; the new containers stay up if it fails
+	statusMsg := ""
+	if err := h.runReleaseCommand(ctx, project, project.Release.PostDeployCommand); err != nil {
+		log.Printf("Post-deploy command failed for %s: %v", project.Name, err)
+		statusMsg = "Post-deploy command failed: " + err.Error()
+	}
+
+	// Update project status; crashes of the previous containers no longer
+	// count
 	h.projectRepo.UpdateContainerIDs(project.ID, containerIDs)
-	h.projectRepo.UpdateStatus(project.ID, models.StatusRunning, "")
+	h.projectRepo.ResetCrashes(project.ID)
+	h.projectRepo.UpdateStatus(project.ID, models.StatusRunning, statusMsg)
+
+	return nil
+}
+
+// checkoutTag checks out the newest tag matching the project's tag rule,
+// cloning it again only when the tag changed
+func (h *Handler) checkoutTag(project *models.Project) error {
+	tags, err := h.gitManager.ListRemoteTags(project.GitURL)
+	if err != nil {
+		return fmt.Errorf("failed to list tags: %w", err)
+	}
+	tag, ok := gitpkg.LatestTag(project.TagRule, tags)
+	if !ok {
+		return fmt.Errorf("no tag matches %s", project.TagRule)
+	}
 
+	if tag != project.DeployedTag || !h.gitManager.Exists(project.Name) {
+		if err := h.gitManager.CloneTag(project.GitURL, tag, project.Name); err != nil {
+			return err
+		}
+	}
+	h.projectRepo.UpdateDeployedTag(project.ID, tag)
+	project.DeployedTag = tag
 	return nil
 }
 
-// DeployProject is a public wrapper for deployProject (for watcher)
-func (h *Handler) DeployProject(ctx context.Context, project *models.Project) error {
-	return h.deployProject(ctx, project)
+// checkoutPin checks out the commit a project is pinned to, cloning its
+// branch again only when another commit is checked out
+func (h *Handler) checkoutPin(project *models.Project) error {
+	current, err := h.gitManager.GetLatestCommit(project.Name)
+	if err != nil || !strings.HasPrefix(current, project.PinnedCommit) {
+		if err := h.gitManager.CloneCommit(project.GitURL, project.Branch, project.PinnedCommit, project.Name); err != nil {
+			return err
+		}
+	}
+	if project.DeployedTag != "" {
+		h.projectRepo.UpdateDeployedTag(project.ID, "")
+		project.DeployedTag = ""
+	}
+	return nil
+}
+
+// releaseOutputLimit is how much of a failed release command's output is
+// kept in the project's status message
+const releaseOutputLimit = 4096
+
+// runReleaseCommand runs a release command of a project in a one-off
+// container from its new image. An empty command does nothing. The error of
+// a failed command includes the end of its output.
+func (h *Handler) runReleaseCommand(ctx context.Context, project *models.Project, command string) error {
+	if command == "" {
+		return nil
+	}
+	h.projectRepo.UpdateStatus(project.ID, models.StatusDeploying, "Running "+command+"...")
+
+	var exitCode int
+	var output string
+	var err error
+	if project.DeployType == models.DeployTypeCompose {
+		exitCode, output, err = h.composeManager.RunRelease(ctx, project, project.Release.Service, command)
+	} else {
+		exitCode, output, err = h.dockerClient.RunRelease(ctx, project, command)
+	}
+
+	if len(output) > releaseOutputLimit {
+		output = "..." + output[len(output)-releaseOutputLimit:]
+	}
+	output = strings.TrimSpace(output)
+	switch {
+	case err != nil && output != "":
+		return fmt.Errorf("%w\n%s", err, output)
+	case err != nil:
+		return err
+	case exitCode != 0 && output != "":
+		return fmt.Errorf("exited with code %d\n%s", exitCode, output)
+	case exitCode != 0:
+		return fmt.Errorf("exited with code %d", exitCode)
+	}
+	return nil
+}
+
+// DeployProject deploys a project, records deploy metrics and notifies
+// subscribed channels (for watcher)
+func (h *Handler) DeployProject(ctx context.Context, project *models.Project, trigger models.DeployTrigger) error {
+	h.notify(project, models.EventDeployStarted, trigger, "")
+	h.events.Publish(events.Event{Type: events.TypeDeploy, ProjectID: project.ID, Status: models.StatusDeploying, Message: "Deploy started", Trigger: trigger})
+
+	start := time.Now()
+	err := h.deployProject(ctx, project)
+	duration := time.Since(start)
+	metrics.ObserveDeploy(project.Name, string(trigger), err, duration)
+
+	status, message := models.StatusRunning, fmt.Sprintf("Finished in %s", duration.Round(time.Second))
+	if err != nil {
+		status, message = models.StatusError, err.Error()
+		h.notify(project, models.EventDeployFailed, trigger, message)
+	} else {
+		h.notify(project, models.EventDeploySucceeded, trigger, message)
+	}
+	h.events.Publish(events.Event{Type: events.TypeDeploy, ProjectID: project.ID, Status: status, Message: message, Trigger: trigger, Commit: project.LastCommit})
+	return err
 }
 
 // Stop stops a project
@@ -640,7 +1116,8 @@ func (h *Handler) Restart(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, fmt.Sprintf("/projects/%s", project.ID), http.StatusSeeOther)
 }
 
-// Logs streams container logs
+// Logs returns the log lines of a project's containers as JSON, or streams
+// them as Server-Sent Events with follow=true
 func (h *Handler) Logs(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "id")
 
@@ -655,77 +1132,100 @@ func (h *Handler) Logs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-	follow := r.URL.Query().Get("follow") == "true"
-	tail := 100
+	opts, err := parseLogOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if project.DeployType == models.DeployTypeCompose {
-		logs, err := h.composeManager.Logs(ctx, project, follow, tail)
+	if !opts.Follow {
+		lines := []docker.LogLine{}
+		err := h.dockerClient.ProjectLogs(r.Context(), project.ID, opts, func(line docker.LogLine) error {
+			lines = append(lines, line)
+			return nil
+		})
 		if err != nil {
+			log.Printf("Failed to get logs for %s: %v", project.Name, err)
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.Write([]byte(logs))
+		writeJSON(w, http.StatusOK, lines)
 		return
 	}
 
-	// For single container deployments
-	if len(project.ContainerIDs) == 0 {
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.Write([]byte("No containers running"))
-		return
-	}
+	// Stream logs with SSE, outliving the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
 
-	// Get logs from first container
-	reader, err := h.dockerClient.GetContainerLogs(ctx, project.ContainerIDs[0], tail, follow)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	rc.Flush()
+
+	err = h.dockerClient.ProjectLogs(r.Context(), project.ID, opts, func(line docker.LogLine) error {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	})
+	if err != nil && r.Context().Err() == nil {
+		log.Printf("Failed to stream logs for %s: %v", project.Name, err)
+		fmt.Fprintf(w, "event: error\ndata: %s\n\n", strings.ReplaceAll(err.Error(), "\n", " "))
+		rc.Flush()
 	}
-	defer reader.Close()
+}
 
-	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+// parseLogOptions parses the service, tail, since, until and follow
+// parameters of a logs request
+func parseLogOptions(query url.Values) (docker.LogOptions, error) {
+	opts := docker.LogOptions{
+		Services: query["service"],
+		Tail:     100,
+		Follow:   query.Get("follow") == "true",
+	}
+	if t, err := strconv.Atoi(query.Get("tail")); err == nil && t >= 0 {
+		opts.Tail = t
+	}
 
-	if follow {
-		// Stream logs with SSE
-		w.Header().Set("Content-Type", "text/event-stream")
-		w.Header().Set("Cache-Control", "no-cache")
-		w.Header().Set("Connection", "keep-alive")
+	var err error
+	opts.Since, opts.Until, err = parseLogRange(query)
+	return opts, err
+}
 
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
-			return
+// parseLogRange parses the since and until parameters of a logs request,
+// which are RFC 3339 times or durations before now, such as 15m
+func parseLogRange(query url.Values) (since, until time.Time, err error) {
+	now := time.Now()
+	parse := func(name string) (time.Time, error) {
+		value := query.Get(name)
+		if value == "" {
+			return time.Time{}, nil
 		}
-
-		scanner := bufio.NewScanner(reader)
-		for scanner.Scan() {
-			line := scanner.Text()
-			// Skip the Docker log header (first 8 bytes)
-			if len(line) > 8 {
-				line = line[8:]
-			}
-			fmt.Fprintf(w, "data: %s\n\n", line)
-			flusher.Flush()
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return now.Add(-d), nil
 		}
-	} else {
-		// Return all logs at once
-		data, err := io.ReadAll(reader)
+		t, err := time.Parse(time.RFC3339, value)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return time.Time{}, fmt.Errorf("invalid %s %q: use an RFC 3339 time or a duration such as 15m", name, value)
 		}
-		// Process logs to remove Docker headers
-		lines := strings.Split(string(data), "\n")
-		var cleanLines []string
-		for _, line := range lines {
-			if len(line) > 8 {
-				cleanLines = append(cleanLines, line[8:])
-			}
-		}
-		w.Write([]byte(strings.Join(cleanLines, "\n")))
+		return t, nil
 	}
+
+	if since, err = parse("since"); err != nil {
+		return since, until, err
+	}
+	if until, err = parse("until"); err != nil {
+		return since, until, err
+	}
+	if !since.IsZero() && !until.IsZero() && !until.After(since) {
+		return since, until, fmt.Errorf("until must be after since")
+	}
+	return since, until, nil
 }
 
 // Health returns health status
@@ -743,7 +1243,32 @@ func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
-// ProjectStatus returns the current status of a project (for polling)
+// Metrics serves SlimDeploy metrics in the Prometheus text format
+func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
+	// Refresh project status gauges
+	projects, err := h.projectRepo.List()
+	if err != nil {
+		log.Printf("Failed to list projects for metrics: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	counts := make(map[models.ProjectStatus]int)
+	for _, project := range projects {
+		counts[project.Status]++
+	}
+	metrics.Projects.Reset()
+	for _, status := range models.ProjectStatuses {
+		metrics.Projects.Set(float64(counts[status]), string(status))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := metrics.Default.Write(w); err != nil {
+		log.Printf("Failed to write metrics: %v", err)
+	}
+}
+
+// ProjectStatus returns the project card with the current status, fetched by
+// the dashboard when a status event arrives
 func (h *Handler) ProjectStatus(w http.ResponseWriter, r *http.Request) {
 	projectID := chi.URLParam(r, "id")
 
@@ -781,3 +1306,175 @@ func parseEnvVars(text string) map[string]string {
 	}
 	return envVars
 }
+
+// parseResourceLimits parses the resource limit fields of the project form.
+// Empty fields mean no limit.
+func parseResourceLimits(r *http.Request) (models.ResourceLimits, error) {
+	var limits models.ResourceLimits
+
+	ints := []struct {
+		field string
+		label string
+		dest  *int64
+	}{
+		{"memory_mb", "Memory limit", &limits.MemoryMB},
+		{"memory_reservation_mb", "Memory reservation", &limits.MemoryReservationMB},
+		{"cpu_shares", "CPU shares", &limits.CPUShares},
+		{"pids_limit", "PIDs limit", &limits.PidsLimit},
+	}
+	for _, f := range ints {
+		value := strings.TrimSpace(r.FormValue(f.field))
+		if value == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return limits, fmt.Errorf("%s must be a whole number", f.label)
+		}
+		*f.dest = n
+	}
+
+	if value := strings.TrimSpace(r.FormValue("cpus")); value != "" {
+		cpus, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return limits, fmt.Errorf("CPUs must be a number")
+		}
+		limits.CPUs = cpus
+	}
+
+	return limits, limits.Validate()
+}
+
+// parseBackupPolicy parses the backup fields of the project form
+func parseBackupPolicy(r *http.Request) (models.BackupPolicy, error) {
+	policy := models.BackupPolicy{Enabled: r.FormValue("backup_enabled") == "on"}
+
+	if value := strings.TrimSpace(r.FormValue("backup_interval_hours")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return policy, fmt.Errorf("Backup interval must be a whole number of hours")
+		}
+		policy.IntervalHours = n
+	}
+	if value := strings.TrimSpace(r.FormValue("backup_keep")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return policy, fmt.Errorf("Backups to keep must be a whole number")
+		}
+		policy.Keep = n
+	}
+
+	return policy, policy.Validate()
+}
+
+// parseReleaseConfig parses the release command fields of the project form
+func parseReleaseConfig(r *http.Request) (models.ReleaseConfig, error) {
+	release := models.ReleaseConfig{
+		Command:           strings.TrimSpace(r.FormValue("release_command")),
+		PostDeployCommand: strings.TrimSpace(r.FormValue("post_deploy_command")),
+		Service:           strings.TrimSpace(r.FormValue("release_service")),
+	}
+
+	if value := strings.TrimSpace(r.FormValue("release_timeout_seconds")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return release, fmt.Errorf("Release timeout must be a whole number of seconds")
+		}
+		release.TimeoutSeconds = n
+	}
+
+	return release, release.Validate()
+}
+
+// parseLogRetention parses the log retention fields of the project form
+func parseLogRetention(r *http.Request) (models.LogRetention, error) {
+	var retention models.LogRetention
+
+	if value := strings.TrimSpace(r.FormValue("log_retention_days")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return retention, fmt.Errorf("Log retention must be a whole number of days")
+		}
+		retention.Days = n
+	}
+	if value := strings.TrimSpace(r.FormValue("log_max_lines")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return retention, fmt.Errorf("Log lines to keep must be a whole number")
+		}
+		retention.MaxLines = n
+	}
+
+	return retention, retention.Validate()
+}
+
+// parsePreviewConfig parses the preview fields of the project form
+func parsePreviewConfig(r *http.Request) (models.PreviewConfig, error) {
+	config := models.PreviewConfig{Enabled: r.FormValue("previews_enabled") == "on"}
+
+	if value := strings.TrimSpace(r.FormValue("preview_ttl_hours")); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return config, fmt.Errorf("Preview TTL must be a whole number of hours")
+		}
+		config.TTLHours = n
+	}
+
+	return config, config.Validate()
+}
+
+// parseComposeConfig parses the compose file locations, profiles and label
+// mode from a form. Profiles are separated by commas or spaces.
+func parseComposeConfig(r *http.Request) models.ComposeConfig {
+	return models.ComposeConfig{
+		Dir:                strings.Trim(strings.TrimSpace(r.FormValue("compose_dir")), "/"),
+		File:               strings.TrimSpace(r.FormValue("compose_file")),
+		Overrides:          parseLines(r.FormValue("compose_overrides")),
+		Profiles:           strings.Fields(strings.ReplaceAll(r.FormValue("compose_profiles"), ",", " ")),
+		MergeTraefikLabels: r.FormValue("merge_traefik_labels") == "on",
+	}
+}
+
+// parseRoutedServices parses routed compose services, one per line as
+// "name [host][:port][/path]"
+func parseRoutedServices(text string) ([]models.RoutedService, error) {
+	var services []models.RoutedService
+	for _, line := range parseLines(text) {
+		s, err := models.ParseRoutedService(line)
+		if err != nil {
+			return services, err
+		}
+		services = append(services, s)
+	}
+	return services, nil
+}
+
+// parseLines returns the non-empty lines of text that aren't comments
+func parseLines(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// parseMounts parses mounts from text format (source:target[:ro] per line)
+func parseMounts(text string) ([]models.Mount, error) {
+	mounts := []models.Mount{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m, err := models.ParseMount(line)
+		if err != nil {
+			return mounts, err
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/mhenrichsen/slimdeploy/internal/jobs"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// jobRunsShown is how many recent runs the project page lists
+const jobRunsShown = 20
+
+// JobView is a job with its scheduling state
+type JobView struct {
+	*models.Job
+	Running bool
+	NextRun time.Time
+}
+
+// JobRunView is a job run with the name of its job
+type JobRunView struct {
+	*models.JobRun
+	JobName string
+}
+
+// ProjectJobsData is the data for the project_jobs partial
+type ProjectJobsData struct {
+	Project *models.Project
+	Jobs    []JobView
+	Runs    []JobRunView
+	// Form holds the values of the new job form
+	Form    *models.Job
+	Running bool
+	Error   string
+	Success string
+}
+
+// renderJobs renders the jobs partial of a project
+func (h *Handler) renderJobs(w http.ResponseWriter, project *models.Project, data ProjectJobsData) {
+	projectJobs, err := h.jobRepo.ListByProject(project.ID)
+	if err != nil {
+		log.Printf("Failed to list jobs: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	runs, err := h.jobRepo.ListRunsByProject(project.ID, jobRunsShown)
+	if err != nil {
+		log.Printf("Failed to list job runs: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	names := make(map[string]string)
+	for _, job := range projectJobs {
+		view := JobView{Job: job, Running: h.jobs.IsRunning(job.ID), NextRun: job.NextRun(now)}
+		data.Running = data.Running || view.Running
+		data.Jobs = append(data.Jobs, view)
+		names[job.ID] = job.Name
+	}
+	for _, run := range runs {
+		data.Runs = append(data.Runs, JobRunView{JobRun: run, JobName: names[run.JobID]})
+	}
+
+	data.Project = project
+	if data.Form == nil {
+		data.Form = &models.Job{}
+	}
+	h.renderPartial(w, "project_jobs", data)
+}
+
+// ProjectJobs lists a project's scheduled jobs and their recent runs (for polling)
+func (h *Handler) ProjectJobs(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	h.renderJobs(w, project, ProjectJobsData{})
+}
+
+// CreateJob adds a scheduled job to a project
+func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	job := &models.Job{
+		ID:        uuid.New().String(),
+		ProjectID: project.ID,
+		Name:      strings.TrimSpace(r.FormValue("name")),
+		Schedule:  strings.TrimSpace(r.FormValue("schedule")),
+		Command:   strings.TrimSpace(r.FormValue("command")),
+		Service:   strings.TrimSpace(r.FormValue("service")),
+	}
+	var errMsg string
+	if timeout := strings.TrimSpace(r.FormValue("timeout_seconds")); timeout != "" {
+		n, err := strconv.Atoi(timeout)
+		if err != nil {
+			errMsg = "Timeout must be a whole number of seconds"
+		}
+		job.TimeoutSeconds = n
+	}
+	if err := job.Validate(); err != nil && errMsg == "" {
+		errMsg = err.Error()
+	}
+	if errMsg != "" {
+		h.renderJobs(w, project, ProjectJobsData{Form: job, Error: errMsg})
+		return
+	}
+
+	if err := h.jobRepo.Create(job); err != nil {
+		log.Printf("Failed to create job: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderJobs(w, project, ProjectJobsData{Success: "Job " + job.Name + " added"})
+}
+
+// RunJob starts a run of a job now
+func (h *Handler) RunJob(w http.ResponseWriter, r *http.Request) {
+	project, job, ok := h.projectJob(w, r)
+	if !ok {
+		return
+	}
+
+	var data ProjectJobsData
+	if _, err := h.jobs.Start(job, models.TriggerManual); err == jobs.ErrBusy {
+		data.Error = "Job " + job.Name + " is already running"
+	} else if err != nil {
+		log.Printf("Failed to start job: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	} else {
+		data.Success = "Job " + job.Name + " started"
+	}
+
+	h.renderJobs(w, project, data)
+}
+
+// DeleteJob removes a job and its run history
+func (h *Handler) DeleteJob(w http.ResponseWriter, r *http.Request) {
+	project, job, ok := h.projectJob(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.jobRepo.Delete(job.ID); err != nil {
+		log.Printf("Failed to delete job: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderJobs(w, project, ProjectJobsData{Success: "Job " + job.Name + " deleted"})
+}
+
+// projectJob loads the project and job of a job route, writing an error
+// response if either is missing
+func (h *Handler) projectJob(w http.ResponseWriter, r *http.Request) (*models.Project, *models.Job, bool) {
+	project, err := h.projectRepo.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return nil, nil, false
+	}
+
+	job, err := h.jobRepo.GetByID(chi.URLParam(r, "jobID"))
+	if err != nil {
+		log.Printf("Failed to get job: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if job == nil || job.ProjectID != project.ID {
+		http.NotFound(w, r)
+		return nil, nil, false
+	}
+
+	return project, job, true
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// maxLogSearchLimit caps the number of lines a search returns
+const maxLogSearchLimit = 5000
+
+// LogSearchData is the data for the log_search partial
+type LogSearchData struct {
+	Project *models.Project
+	// Query holds the submitted search form
+	Query    url.Values
+	Services []string
+	// Records are the matching lines, oldest first
+	Records []*models.LogRecord
+	Limit   int
+	// Count is the number of stored lines and Oldest the time of the first
+	Count  int
+	Oldest time.Time
+	Error  string
+}
+
+// Truncated reports whether more lines matched than were returned
+func (d LogSearchData) Truncated() bool {
+	return len(d.Records) >= d.Limit
+}
+
+// SearchLogs renders the collected log lines of a project matching the
+// search form
+func (h *Handler) SearchLogs(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	data := LogSearchData{Project: project, Query: query}
+
+	data.Services, err = h.logRepo.Services(project.ID)
+	if err != nil {
+		log.Printf("Failed to list log services of %s: %v", project.Name, err)
+	}
+	data.Count, data.Oldest, err = h.logRepo.Stats(project.ID)
+	if err != nil {
+		log.Printf("Failed to get log stats of %s: %v", project.Name, err)
+	}
+
+	q, err := parseLogQuery(project.ID, query)
+	if err != nil {
+		data.Error = err.Error()
+		h.renderPartial(w, "log_search", data)
+		return
+	}
+	data.Limit = q.Limit
+
+	records, err := h.logRepo.Search(q)
+	if errors.Is(err, db.ErrInvalidLogQuery) {
+		data.Error = "Invalid search query; check quotes and that OR and NOT join two terms"
+	} else if err != nil {
+		log.Printf("Failed to search logs of %s: %v", project.Name, err)
+		data.Error = "Failed to search logs"
+	}
+
+	// Show the newest matches in reading order
+	for i := len(records) - 1; i >= 0; i-- {
+		data.Records = append(data.Records, records[i])
+	}
+	h.renderPartial(w, "log_search", data)
+}
+
+// APISearchLogs returns the collected log lines of a project matching the
+// query, newest first
+func (h *Handler) APISearchLogs(w http.ResponseWriter, r *http.Request) {
+	project := h.apiProject(w, r)
+	if project == nil {
+		return
+	}
+
+	q, err := parseLogQuery(project.ID, r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	records, err := h.logRepo.Search(q)
+	if errors.Is(err, db.ErrInvalidLogQuery) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to search logs of %s: %v", project.Name, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to search logs")
+		return
+	}
+	if records == nil {
+		records = []*models.LogRecord{}
+	}
+	writeJSON(w, http.StatusOK, records)
+}
+
+// parseLogQuery parses the q, service, stream, since, until and limit
+// parameters of a log search
+func parseLogQuery(projectID string, query url.Values) (models.LogQuery, error) {
+	q := models.LogQuery{
+		ProjectID: projectID,
+		Text:      strings.TrimSpace(query.Get("q")),
+		Stream:    query.Get("stream"),
+		Limit:     db.DefaultLogSearchLimit,
+	}
+	for _, service := range query["service"] {
+		if service != "" {
+			q.Services = append(q.Services, service)
+		}
+	}
+
+	switch docker.LogStream(q.Stream) {
+	case "", docker.StreamStdout, docker.StreamStderr:
+	default:
+		return q, fmt.Errorf("invalid stream %q: use stdout or stderr", q.Stream)
+	}
+
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxLogSearchLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxLogSearchLimit)
+		}
+		q.Limit = n
+	}
+
+	var err error
+	q.Since, q.Until, err = parseLogRange(query)
+	return q, err
+}
@@ -1,9 +1,22 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mhenrichsen/slimdeploy/internal/metrics"
 )
 
 // LoggingMiddleware logs HTTP requests
@@ -24,6 +37,13 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 			rw.statusCode,
 			duration,
 		)
+
+		// Record latency by route pattern to keep label cardinality bounded
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		metrics.ObserveHTTPRequest(r.Method, route, rw.statusCode, duration)
 	})
 }
 
@@ -47,6 +67,82 @@ func AuthMiddleware(auth *AuthManager) func(http.Handler) http.Handler {
 	}
 }
 
+// APIAuthMiddleware requires an API token or session for JSON API routes
+func APIAuthMiddleware(auth *AuthManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !auth.IsAPIAuthenticated(r) {
+				writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MetricsAuthMiddleware requires a bearer token for /metrics when one is set
+func MetricsAuthMiddleware(auth *AuthManager, token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token != "" && subtle.ConstantTimeCompare([]byte(auth.GetAPITokenFromRequest(r)), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// maxWebhookBody caps the size of webhook payloads
+const maxWebhookBody = 5 << 20
+
+// WebhookAuthMiddleware verifies push webhooks against secret, either signed
+// with HMAC-SHA256 in X-Hub-Signature-256 (GitHub, Gitea) or sent as
+// X-Gitlab-Token. Webhooks are disabled when no secret is set.
+func WebhookAuthMiddleware(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if secret == "" {
+				writeJSONError(w, http.StatusNotFound, "webhooks are disabled; set WEBHOOK_SECRET")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, "failed to read body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if !validWebhookSignature(secret, body, r) {
+				writeJSONError(w, http.StatusUnauthorized, "invalid webhook signature")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validWebhookSignature checks a webhook's signature or token
+func validWebhookSignature(secret string, body []byte, r *http.Request) bool {
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1
+	}
+
+	signature, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
 // RecoveryMiddleware recovers from panics
 func RecoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -71,6 +167,28 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Flush implements http.Flusher so streaming responses work through the wrapper
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker so WebSocket connections work through the
+// wrapper
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // NoCacheMiddleware adds no-cache headers
 func NoCacheMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+	"github.com/mhenrichsen/slimdeploy/internal/notify"
+)
+
+// ChannelView wraps a notification channel with the names of the projects
+// subscribed to it
+type ChannelView struct {
+	*models.NotificationChannel
+	Projects []string
+}
+
+// NotificationsData is the data for the notifications template
+type NotificationsData struct {
+	TemplateData
+	Channels     []ChannelView
+	ChannelTypes []models.ChannelTypeOption
+	Form         *models.NotificationChannel
+	EmailEnabled bool
+}
+
+// NotificationTestData is the data for the notification_test partial
+type NotificationTestData struct {
+	Error   string
+	Success string
+}
+
+// Notifications lists the notification channels
+func (h *Handler) Notifications(w http.ResponseWriter, r *http.Request) {
+	h.renderNotifications(w, "", &models.NotificationChannel{Type: models.ChannelSlack})
+}
+
+// renderNotifications renders the notifications page with the create form
+// filled from form
+func (h *Handler) renderNotifications(w http.ResponseWriter, errMsg string, form *models.NotificationChannel) {
+	channels, err := h.notifyRepo.ListChannels()
+	if err != nil {
+		log.Printf("Failed to list notification channels: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]ChannelView, len(channels))
+	for i, channel := range channels {
+		views[i] = ChannelView{NotificationChannel: channel}
+		subs, err := h.notifyRepo.ListSubscriptionsByChannel(channel.ID)
+		if err != nil {
+			log.Printf("Failed to list subscriptions of channel %s: %v", channel.Name, err)
+			continue
+		}
+		for _, sub := range subs {
+			if project, err := h.projectRepo.GetByID(sub.ProjectID); err == nil && project != nil {
+				views[i].Projects = append(views[i].Projects, project.Name)
+			}
+		}
+	}
+
+	h.render(w, "notifications.html", NotificationsData{
+		TemplateData: TemplateData{
+			Title:      "Notifications",
+			Error:      errMsg,
+			BaseDomain: h.baseDomain,
+		},
+		Channels:     views,
+		ChannelTypes: models.ChannelTypes,
+		Form:         form,
+		EmailEnabled: h.notifier.EmailEnabled(),
+	})
+}
+
+// CreateNotificationChannel creates a notification channel
+func (h *Handler) CreateNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel := &models.NotificationChannel{
+		ID:   uuid.New().String(),
+		Name: strings.TrimSpace(r.FormValue("name")),
+		Type: models.ChannelType(r.FormValue("type")),
+	}
+	if channel.Type == models.ChannelEmail {
+		channel.EmailTo = strings.TrimSpace(r.FormValue("email_to"))
+	} else {
+		channel.URL = strings.TrimSpace(r.FormValue("url"))
+	}
+	if channel.Type == models.ChannelWebhook {
+		channel.Secret = strings.TrimSpace(r.FormValue("secret"))
+	}
+
+	if err := channel.Validate(); err != nil {
+		h.renderNotifications(w, err.Error(), channel)
+		return
+	}
+
+	if err := h.notifyRepo.CreateChannel(channel); err != nil {
+		log.Printf("Failed to create notification channel: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// TestNotificationChannel sends a test notification to a channel and
+// reports the outcome (for HTMX)
+func (h *Handler) TestNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, err := h.notifyRepo.GetChannel(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Failed to get notification channel: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if channel == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), notify.SendTimeout)
+	defer cancel()
+
+	event := models.Event{
+		Type:    models.EventTest,
+		Message: fmt.Sprintf("Channel %s is set up correctly.", channel.Name),
+	}
+	var data NotificationTestData
+	if err := h.notifier.Send(ctx, channel, event); err != nil {
+		data.Error = "Failed: " + err.Error()
+	} else {
+		data.Success = "Sent"
+	}
+
+	h.renderPartial(w, "notification_test", data)
+}
+
+// DeleteNotificationChannel deletes a notification channel and its project
+// subscriptions
+func (h *Handler) DeleteNotificationChannel(w http.ResponseWriter, r *http.Request) {
+	channel, err := h.notifyRepo.GetChannel(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Failed to get notification channel: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if channel == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := h.notifyRepo.DeleteChannel(channel.ID); err != nil {
+		log.Printf("Failed to delete notification channel: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/notifications")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+// subscriptionForm returns all notification channels and the events each is
+// subscribed to for the project
+func (h *Handler) subscriptionForm(projectID string) ([]*models.NotificationChannel, map[string]map[models.EventType]bool) {
+	channels, err := h.notifyRepo.ListChannels()
+	if err != nil {
+		log.Printf("Failed to list notification channels: %v", err)
+	}
+
+	subs, err := h.notifyRepo.ListSubscriptions(projectID)
+	if err != nil {
+		log.Printf("Failed to list notification subscriptions: %v", err)
+	}
+	return channels, subscribedEvents(subs)
+}
+
+// subscribedEvents maps channel IDs to the set of events subscribed to
+func subscribedEvents(subs []models.NotificationSubscription) map[string]map[models.EventType]bool {
+	subscribed := make(map[string]map[models.EventType]bool)
+	for _, sub := range subs {
+		subscribed[sub.ChannelID] = make(map[models.EventType]bool)
+		for _, e := range sub.Events {
+			subscribed[sub.ChannelID][e] = true
+		}
+	}
+	return subscribed
+}
+
+// parseSubscriptions parses the notification checkboxes of the project
+// form, one notify_<channel ID> field per checked event
+func parseSubscriptions(r *http.Request, projectID string, channels []*models.NotificationChannel) []models.NotificationSubscription {
+	var subs []models.NotificationSubscription
+	for _, channel := range channels {
+		var events []models.EventType
+		for _, value := range r.Form["notify_"+channel.ID] {
+			if t := models.EventType(value); models.ValidEventType(t) {
+				events = append(events, t)
+			}
+		}
+		if len(events) > 0 {
+			subs = append(subs, models.NotificationSubscription{ProjectID: projectID, ChannelID: channel.ID, Events: events})
+		}
+	}
+	return subs
+}
+
+// notify sends a project event to its subscribed channels in the background
+func (h *Handler) notify(project *models.Project, eventType models.EventType, trigger models.DeployTrigger, message string) {
+	// Before a deploy the last commit is still the previous deploy's
+	commit := project.LastCommit
+	if eventType == models.EventDeployStarted {
+		commit = ""
+	}
+	h.notifier.Notify(models.Event{
+		Type:        eventType,
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		Message:     message,
+		Trigger:     trigger,
+		Commit:      commit,
+		Time:        time.Now(),
+	})
+}
@@ -0,0 +1,333 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	gitpkg "github.com/mhenrichsen/slimdeploy/internal/git"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// SyncPreviews brings a project's branch previews in line with its remote:
+// branches with new commits are deployed, previews of deleted branches are
+// removed and previews past their TTL are stopped until the next push (for
+// watcher). Branches that haven't moved since previews were enabled are
+// left alone.
+func (h *Handler) SyncPreviews(ctx context.Context, project *models.Project) error {
+	branches, err := h.gitManager.ListRemoteBranches(project.GitURL)
+	if err != nil {
+		return err
+	}
+
+	previews, err := h.projectRepo.ListPreviews(project.ID)
+	if err != nil {
+		return err
+	}
+
+	deployed := make(map[string]*models.Project)
+	for _, preview := range previews {
+		if _, ok := branches[preview.Branch]; !ok {
+			log.Printf("Previews: branch %s of %s was deleted, removing %s", preview.Branch, project.Name, preview.Name)
+			if err := h.RemoveProject(ctx, preview, true); err != nil {
+				log.Printf("Previews: failed to remove %s: %v", preview.Name, err)
+			}
+			continue
+		}
+		if preview.PreviewExpiresAt.IsZero() || time.Now().Before(preview.PreviewExpiresAt) {
+			deployed[preview.Branch] = preview
+			continue
+		}
+		if preview.Status != models.StatusStopped {
+			log.Printf("Previews: %s expired, stopping it", preview.Name)
+			h.expirePreview(ctx, preview)
+		}
+		deployed[preview.Branch] = preview
+	}
+
+	// Deploy in a stable order so logs are easy to follow
+	names := make([]string, 0, len(branches))
+	for branch := range branches {
+		names = append(names, branch)
+	}
+	sort.Strings(names)
+
+	for _, branch := range names {
+		if branch == project.Branch {
+			continue
+		}
+		preview := deployed[branch]
+		if preview == nil && project.Previews.KnownBranches[branch] == branches[branch] {
+			continue
+		}
+		if preview != nil && (preview.LastCommit == branches[branch] || preview.Status == models.StatusDeploying) {
+			continue
+		}
+
+		preview, err := h.savePreview(project, branch)
+		if err != nil {
+			log.Printf("Previews: failed to create preview of %s@%s: %v", project.Name, branch, err)
+			continue
+		}
+		h.projectRepo.UpdateStatus(preview.ID, models.StatusDeploying, "Starting deployment...")
+		if err := h.DeployProject(ctx, preview, models.TriggerWatcher); err != nil {
+			log.Printf("Previews: failed to deploy %s: %v", preview.Name, err)
+			h.projectRepo.UpdateStatus(preview.ID, models.StatusError, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// savePreview creates or refreshes the preview of a project's branch with
+// the project's current settings. The preview's TTL starts over.
+func (h *Handler) savePreview(project *models.Project, branch string) (*models.Project, error) {
+	preview, err := h.findPreview(project, branch)
+	if err != nil {
+		return nil, err
+	}
+
+	isNew := preview == nil
+	if isNew {
+		name := project.PreviewName(branch)
+		existing, err := h.projectRepo.GetByName(name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil && existing.PreviewOf == project.ID {
+			return nil, fmt.Errorf("preview name %s is already used by branch %s", name, existing.Branch)
+		}
+		if existing != nil {
+			return nil, fmt.Errorf("a project named %s already exists", name)
+		}
+		preview = &models.Project{
+			ID:        uuid.New().String(),
+			Name:      name,
+			PreviewOf: project.ID,
+			Branch:    branch,
+			Status:    models.StatusPending,
+		}
+	}
+
+	env := make(map[string]string, len(project.EnvVars))
+	for k, v := range project.EnvVars {
+		env[k] = v
+	}
+	preview.GitURL = project.GitURL
+	preview.DeployType = project.DeployType
+	preview.Image = project.Image
+	preview.Domain = project.PreviewDomain(branch, h.baseDomain)
+	preview.Port = project.Port
+	preview.EnvVars = env
+	preview.Resources = project.Resources
+	preview.Release = project.Release
+	preview.LogRetention = project.LogRetention
+	preview.Compose = project.Compose
+	preview.Compose.Services = project.PreviewServices(branch, h.baseDomain)
+	preview.PreviewExpiresAt = time.Time{}
+	if ttl := project.Previews.TTL(); ttl > 0 {
+		preview.PreviewExpiresAt = time.Now().Add(ttl)
+	}
+
+	if isNew {
+		err = h.projectRepo.Create(preview)
+	} else {
+		err = h.projectRepo.Update(preview)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// findPreview returns the preview of a project's branch, or nil if it has
+// none. Previews are looked up by branch rather than name, since previews
+// created before names were made unique may share one.
+func (h *Handler) findPreview(project *models.Project, branch string) (*models.Project, error) {
+	previews, err := h.projectRepo.ListPreviews(project.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, preview := range previews {
+		if preview.Branch == branch {
+			return preview, nil
+		}
+	}
+	return nil, nil
+}
+
+// expirePreview stops a preview and deletes its checkout. It is deployed
+// again on the next push to its branch.
+func (h *Handler) expirePreview(ctx context.Context, preview *models.Project) {
+	if err := h.removeContainers(ctx, preview); err != nil {
+		log.Printf("Previews: failed to stop %s: %v", preview.Name, err)
+	}
+	h.gitManager.Remove(preview.Name)
+	h.projectRepo.UpdateContainerIDs(preview.ID, nil)
+	h.projectRepo.UpdateStatus(preview.ID, models.StatusStopped, "Preview expired")
+}
+
+// removePreviews removes every preview of a project with its volumes
+func (h *Handler) removePreviews(ctx context.Context, project *models.Project) {
+	previews, err := h.projectRepo.ListPreviews(project.ID)
+	if err != nil {
+		log.Printf("Failed to list previews of %s: %v", project.Name, err)
+		return
+	}
+	for _, preview := range previews {
+		if err := h.RemoveProject(ctx, preview, true); err != nil {
+			log.Printf("Failed to remove preview %s: %v", preview.Name, err)
+		}
+	}
+}
+
+// pushEvent is the part of a GitHub, GitLab or Gitea push payload that
+// SlimDeploy reads
+type pushEvent struct {
+	Ref     string `json:"ref"`
+	After   string `json:"after"`
+	Deleted bool   `json:"deleted"`
+}
+
+// pushSkipReason returns why a push to a project's branch shouldn't deploy
+// it, or "" to deploy it. The branch is fetched and compared with the
+// deployed commit like the watcher does, rather than trusting the payload's
+// file lists, which are truncated on long pushes.
+func (h *Handler) pushSkipReason(project *models.Project) string {
+	if !h.gitManager.Exists(project.Name) {
+		return ""
+	}
+
+	commit, err := h.gitManager.GetRemoteLatestCommit(project.GitURL, project.Branch, project.Name)
+	if err != nil {
+		log.Printf("Webhook: failed to fetch %s, deploying anyway: %v", project.Name, err)
+		return ""
+	}
+	if message, err := h.gitManager.CommitMessage(project.Name, commit); err == nil && models.SkipsDeploy(message) {
+		return "commit message contains " + models.SkipDeployMarker
+	}
+	if !project.PathFilter.Enabled() || project.LastCommit == "" {
+		return ""
+	}
+
+	files, err := h.gitManager.ChangedFiles(project.Name, project.LastCommit, commit)
+	if err != nil {
+		log.Printf("Webhook: failed to diff %s, deploying anyway: %v", project.Name, err)
+		return ""
+	}
+	if !project.PathFilter.Matches(files) {
+		return "no changes in watched paths"
+	}
+	return ""
+}
+
+// PushWebhook handles a push webhook for a project. Pushes to the project's
+// branch, or of tags matching its tag rule, deploy it if auto-deploy is
+// enabled, it isn't pinned and the push passes its path filter; pushes to
+// other branches deploy or remove their preview if
+// previews are enabled.
+func (h *Handler) PushWebhook(w http.ResponseWriter, r *http.Request) {
+	project := h.apiProject(w, r)
+	if project == nil {
+		return
+	}
+	if project.IsPreview() {
+		writeJSONError(w, http.StatusBadRequest, "webhooks go to the previewed project")
+		return
+	}
+
+	var event pushEvent
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	// GitLab marks deleted refs with an all-zero commit
+	deleted := event.Deleted || strings.Trim(event.After, "0") == ""
+
+	if tag, ok := strings.CutPrefix(event.Ref, "refs/tags/"); ok {
+		if _, matches := gitpkg.LatestTag(project.TagRule, []string{tag}); project.TagRule == "" || !matches || deleted || !project.AutoDeploy {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+			return
+		}
+		h.webhookDeploy(w, project)
+		return
+	}
+
+	// Pings carry no branch
+	if !strings.HasPrefix(event.Ref, "refs/heads/") {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+		return
+	}
+	branch := strings.TrimPrefix(event.Ref, "refs/heads/")
+
+	if branch == project.Branch {
+		if deleted || !project.AutoDeploy || project.TagRule != "" || project.PinnedCommit != "" {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+			return
+		}
+		if reason := h.pushSkipReason(project); reason != "" {
+			writeJSON(w, http.StatusOK, map[string]string{"status": "skipped", "reason": reason})
+			return
+		}
+		h.webhookDeploy(w, project)
+		return
+	}
+
+	if !project.Previews.Enabled {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ignored"})
+		return
+	}
+
+	preview, err := h.findPreview(project, branch)
+	if err != nil {
+		log.Printf("Failed to get preview: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to get preview")
+		return
+	}
+
+	if deleted {
+		if preview != nil {
+			if err := h.RemoveProject(r.Context(), preview, true); err != nil {
+				log.Printf("Failed to remove preview %s: %v", preview.Name, err)
+				writeJSONError(w, http.StatusInternalServerError, "failed to remove preview")
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if preview != nil && preview.Status == models.StatusDeploying {
+		writeJSONError(w, http.StatusConflict, "preview is already deploying")
+		return
+	}
+	preview, err = h.savePreview(project, branch)
+	if err != nil {
+		log.Printf("Failed to save preview of %s@%s: %v", project.Name, branch, err)
+		writeJSONError(w, http.StatusInternalServerError, "failed to save preview")
+		return
+	}
+	h.startDeploy(preview, models.TriggerWebhook)
+
+	preview, _ = h.projectRepo.GetByID(preview.ID)
+	writeJSON(w, http.StatusAccepted, preview)
+}
+
+// webhookDeploy starts a deployment of a project for a push webhook
+func (h *Handler) webhookDeploy(w http.ResponseWriter, project *models.Project) {
+	if project.Status == models.StatusDeploying {
+		writeJSONError(w, http.StatusConflict, "project is already deploying")
+		return
+	}
+	h.startDeploy(project, models.TriggerWebhook)
+
+	project, _ = h.projectRepo.GetByID(project.ID)
+	writeJSON(w, http.StatusAccepted, project)
+}
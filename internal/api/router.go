@@ -7,7 +7,7 @@ import (
 )
 
 // NewRouter creates a new HTTP router
-func NewRouter(h *Handler, auth *AuthManager, staticFS http.FileSystem) *chi.Mux {
+func NewRouter(h *Handler, auth *AuthManager, staticFS http.FileSystem, metricsToken, webhookSecret string) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Global middleware
@@ -20,11 +20,32 @@ func NewRouter(h *Handler, auth *AuthManager, staticFS http.FileSystem) *chi.Mux
 	// Health check (no auth required)
 	r.Get("/health", h.Health)
 
+	// Prometheus metrics (bearer token required if METRICS_TOKEN is set)
+	r.With(MetricsAuthMiddleware(auth, metricsToken)).Get("/metrics", h.Metrics)
+
+	// Push webhooks (signed with WEBHOOK_SECRET)
+	r.With(WebhookAuthMiddleware(webhookSecret)).Post("/webhooks/{ref}", h.PushWebhook)
+
 	// Auth routes (no auth required)
 	r.Get("/login", h.LoginPage)
 	r.Post("/login", h.Login)
 	r.Post("/logout", h.Logout)
 
+	// JSON API (API token or session required)
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(APIAuthMiddleware(auth))
+
+		r.Get("/projects", h.APIListProjects)
+		r.Post("/projects", h.APICreateProject)
+		r.Get("/projects/{ref}", h.APIGetProject)
+		r.Delete("/projects/{ref}", h.APIDeleteProject)
+		r.Post("/projects/{ref}/deploy", h.APIDeploy)
+		r.Get("/projects/{ref}/logs", h.APILogs)
+		r.Get("/projects/{ref}/logs/search", h.APISearchLogs)
+		r.Get("/projects/{ref}/compose", h.APIComposePreview)
+		r.Get("/backup", h.APIBackup)
+	})
+
 	// Protected routes
 	r.Group(func(r chi.Router) {
 		r.Use(AuthMiddleware(auth))
@@ -32,6 +53,8 @@ func NewRouter(h *Handler, auth *AuthManager, staticFS http.FileSystem) *chi.Mux
 
 		// Dashboard
 		r.Get("/", h.Dashboard)
+		r.Get("/stats", h.HostStats)
+		r.Get("/events", h.Events)
 
 		// Project routes
 		r.Get("/projects/new", h.NewProjectForm)
@@ -47,7 +70,33 @@ func NewRouter(h *Handler, auth *AuthManager, staticFS http.FileSystem) *chi.Mux
 		r.Post("/projects/{id}/stop", h.Stop)
 		r.Post("/projects/{id}/restart", h.Restart)
 		r.Get("/projects/{id}/logs", h.Logs)
+		r.Get("/projects/{id}/logs/search", h.SearchLogs)
+		r.Get("/projects/{id}/terminal", h.Terminal)
 		r.Get("/projects/{id}/status", h.ProjectStatus)
+		r.Get("/projects/{id}/stats", h.ProjectStats)
+		r.Get("/projects/{id}/labels", h.ProjectLabels)
+		r.Get("/projects/{id}/compose", h.ProjectCompose)
+		r.Get("/projects/{id}/backups", h.ProjectBackups)
+		r.Post("/projects/{id}/backups", h.BackupProject)
+		r.Post("/projects/{id}/backups/{backupID}/restore", h.RestoreBackup)
+
+		// Scheduled jobs
+		r.Get("/projects/{id}/jobs", h.ProjectJobs)
+		r.Post("/projects/{id}/jobs", h.CreateJob)
+		r.Post("/projects/{id}/jobs/{jobID}/run", h.RunJob)
+		r.Delete("/projects/{id}/jobs/{jobID}", h.DeleteJob)
+
+		// Add-ons
+		r.Get("/addons", h.Addons)
+		r.Post("/addons", h.CreateAddon)
+		r.Post("/addons/{id}/restart", h.RestartAddon)
+		r.Delete("/addons/{id}", h.DeleteAddon)
+
+		// Notifications
+		r.Get("/notifications", h.Notifications)
+		r.Post("/notifications", h.CreateNotificationChannel)
+		r.Post("/notifications/{id}/test", h.TestNotificationChannel)
+		r.Delete("/notifications/{id}", h.DeleteNotificationChannel)
 	})
 
 	return r
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/stats"
+)
+
+// Chart dimensions in SVG viewBox units
+const (
+	chartWidth  = 100.0
+	chartHeight = 32.0
+)
+
+// Chart is a small line chart rendered as an inline SVG polyline
+type Chart struct {
+	Label   string
+	Current string
+	Points  string
+}
+
+// ProjectStatsData is the data for the project_stats partial
+type ProjectStatsData struct {
+	ProjectID  string
+	Charts     []Chart
+	Containers []docker.ContainerStats
+	Error      string
+}
+
+// HostStatsData is the data for the host_stats partial
+type HostStatsData struct {
+	Host       *docker.HostInfo
+	Latest     stats.Sample
+	CPUPercent float64
+	MemPercent float64
+	Charts     []Chart
+	Error      string
+}
+
+// newChart scales values into chart coordinates. A max of 0 scales to the
+// largest value.
+func newChart(label, current string, values []float64, max float64) Chart {
+	chart := Chart{Label: label, Current: current}
+	if len(values) < 2 {
+		return chart
+	}
+
+	if max <= 0 {
+		for _, v := range values {
+			if v > max {
+				max = v
+			}
+		}
+	}
+	if max <= 0 {
+		max = 1
+	}
+
+	points := make([]string, len(values))
+	for i, v := range values {
+		x := float64(i) * chartWidth / float64(len(values)-1)
+		y := chartHeight - (v/max)*(chartHeight-2) - 1
+		if y < 1 {
+			y = 1
+		}
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	chart.Points = strings.Join(points, " ")
+	return chart
+}
+
+// rates converts cumulative byte counters into per-second rates between
+// samples. Counter resets (container restarts) count as zero.
+func rates(samples []stats.Sample, value func(stats.Sample) uint64) []float64 {
+	var out []float64
+	for i := 1; i < len(samples); i++ {
+		prev, cur := value(samples[i-1]), value(samples[i])
+		seconds := samples[i].Time.Sub(samples[i-1].Time).Seconds()
+		if cur < prev || seconds <= 0 {
+			out = append(out, 0)
+			continue
+		}
+		out = append(out, float64(cur-prev)/seconds)
+	}
+	return out
+}
+
+// formatRate formats a bytes-per-second rate
+func formatRate(values []float64) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return stats.FormatBytes(uint64(values[len(values)-1])) + "/s"
+}
+
+// resourceCharts builds the CPU, memory, network and block I/O charts for a
+// series of samples. cpuMax and memMax fix the chart scale when non-zero.
+func resourceCharts(samples []stats.Sample, cpuMax, memMax float64) []Chart {
+	cpu := make([]float64, len(samples))
+	mem := make([]float64, len(samples))
+	for i, s := range samples {
+		cpu[i] = s.CPUPercent
+		mem[i] = float64(s.MemoryUsage)
+	}
+
+	network := rates(samples, func(s stats.Sample) uint64 { return s.NetworkRx + s.NetworkTx })
+	block := rates(samples, func(s stats.Sample) uint64 { return s.BlockRead + s.BlockWrite })
+
+	cpuCurrent, memCurrent := "-", "-"
+	if len(samples) > 0 {
+		latest := samples[len(samples)-1]
+		cpuCurrent = fmt.Sprintf("%.1f%%", latest.CPUPercent)
+		memCurrent = stats.FormatBytes(latest.MemoryUsage)
+	}
+
+	return []Chart{
+		newChart("CPU", cpuCurrent, cpu, cpuMax),
+		newChart("Memory", memCurrent, mem, memMax),
+		newChart("Network I/O", formatRate(network), network, 0),
+		newChart("Block I/O", formatRate(block), block, 0),
+	}
+}
+
+// ProjectStats renders resource usage charts for a project (for polling)
+func (h *Handler) ProjectStats(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	data := ProjectStatsData{ProjectID: projectID}
+	if h.stats == nil {
+		data.Error = "Stats collection is not running"
+		h.renderPartial(w, "project_stats", data)
+		return
+	}
+
+	samples, containers := h.stats.Project(projectID)
+	data.Charts = resourceCharts(samples, 0, 0)
+	data.Containers = containers
+	data.Error = h.stats.Err()
+
+	h.renderPartial(w, "project_stats", data)
+}
+
+// HostStats renders the host resource overview for the dashboard (for polling)
+func (h *Handler) HostStats(w http.ResponseWriter, r *http.Request) {
+	var data HostStatsData
+	if h.stats == nil {
+		data.Error = "Stats collection is not running"
+		h.renderPartial(w, "host_stats", data)
+		return
+	}
+
+	host, samples := h.stats.Host()
+	data.Host = host
+	data.Error = h.stats.Err()
+	if len(samples) > 0 {
+		data.Latest = samples[len(samples)-1]
+	}
+
+	// Scale to the host's capacity so the charts show how full the host is
+	var cpuMax, memMax float64
+	if host != nil {
+		cpuMax = float64(host.CPUs) * 100
+		memMax = float64(host.MemoryTotal)
+		if cpuMax > 0 {
+			data.CPUPercent = data.Latest.CPUPercent / cpuMax * 100
+		}
+		if memMax > 0 {
+			data.MemPercent = float64(data.Latest.MemoryUsage) / memMax * 100
+		}
+	}
+	data.Charts = resourceCharts(samples, cpuMax, memMax)
+
+	h.renderPartial(w, "host_stats", data)
+}
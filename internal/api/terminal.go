@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+	"golang.org/x/net/websocket"
+)
+
+// terminalMessage is a message from the browser terminal: keyboard input or
+// a new terminal size
+type terminalMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data,omitempty"`
+	Cols uint   `json:"cols,omitempty"`
+	Rows uint   `json:"rows,omitempty"`
+}
+
+// Terminal opens an interactive shell in a running container of a project
+// over a WebSocket. The service parameter selects the compose service, and
+// cols and rows set the initial terminal size. Terminal output is sent as
+// binary messages; the browser sends JSON terminalMessages. Every shell is
+// recorded with the user who opened it.
+func (h *Handler) Terminal(w http.ResponseWriter, r *http.Request) {
+	projectID := chi.URLParam(r, "id")
+
+	project, err := h.projectRepo.GetByID(projectID)
+	if err != nil {
+		log.Printf("Failed to get project: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	session := &models.ShellSession{
+		ID:         uuid.New().String(),
+		ProjectID:  project.ID,
+		Username:   h.auth.SessionUser(r),
+		RemoteAddr: clientAddr(r),
+		Service:    r.URL.Query().Get("service"),
+	}
+	cols, rows := terminalSize(r.URL.Query().Get("cols"), r.URL.Query().Get("rows"))
+
+	websocket.Server{
+		Handshake: checkSameOrigin,
+		Handler: func(ws *websocket.Conn) {
+			h.serveTerminal(ws, project, session, cols, rows)
+		},
+	}.ServeHTTP(w, r)
+}
+
+// serveTerminal relays a shell between a container and a WebSocket until
+// either side closes
+func (h *Handler) serveTerminal(ws *websocket.Conn, project *models.Project, session *models.ShellSession, cols, rows uint) {
+	// The connection outlives the server's read and write timeouts
+	ws.SetDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	fail := func(format string, args ...interface{}) {
+		websocket.Message.Send(ws, []byte(fmt.Sprintf("\r\n"+format+"\r\n", args...)))
+	}
+
+	containerID, err := h.dockerClient.ShellContainer(ctx, project.ID, session.Service)
+	if err != nil {
+		fail("%v", err)
+		return
+	}
+	exec, err := h.dockerClient.ExecShell(ctx, containerID, cols, rows)
+	if err != nil {
+		log.Printf("Failed to open shell in %s: %v", project.Name, err)
+		fail("%v", err)
+		return
+	}
+	defer exec.Close()
+
+	session.ContainerID = containerID
+	session.StartedAt = time.Now()
+	if err := h.shellRepo.Create(session); err != nil {
+		log.Printf("Failed to record shell session: %v", err)
+	}
+	log.Printf("Shell opened in %s (%s) by %s from %s", project.Name, containerID[:12], shellUser(session.Username), session.RemoteAddr)
+
+	defer func() {
+		session.EndedAt = time.Now()
+		if err := h.shellRepo.End(session); err != nil {
+			log.Printf("Failed to record shell session end: %v", err)
+		}
+		log.Printf("Shell closed in %s (%s) after %s", project.Name, containerID[:12], session.Duration())
+	}()
+
+	// Relay output until the shell exits, then close the socket so the
+	// input loop ends
+	go func() {
+		defer ws.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := exec.Read(buf)
+			if n > 0 {
+				if err := websocket.Message.Send(ws, buf[:n]); err != nil {
+					return
+				}
+			}
+			if err != nil {
+				fail("[shell exited]")
+				return
+			}
+		}
+	}()
+
+	for {
+		var msg terminalMessage
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			return
+		}
+		switch msg.Type {
+		case "input":
+			if _, err := exec.Write([]byte(msg.Data)); err != nil {
+				return
+			}
+		case "resize":
+			if msg.Cols > 0 && msg.Rows > 0 {
+				if err := exec.Resize(ctx, msg.Cols, msg.Rows); err != nil {
+					log.Printf("Failed to resize shell in %s: %v", project.Name, err)
+				}
+			}
+		}
+	}
+}
+
+// checkSameOrigin rejects WebSocket handshakes from other sites, which
+// browsers would otherwise send with the session cookie
+func checkSameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin == nil || origin.Host != r.Host {
+		return fmt.Errorf("cross-origin WebSocket request")
+	}
+	config.Origin = origin
+	return nil
+}
+
+// terminalSize parses the initial terminal size, defaulting to 80x24
+func terminalSize(colsParam, rowsParam string) (uint, uint) {
+	cols, err := strconv.ParseUint(colsParam, 10, 16)
+	if err != nil || cols == 0 {
+		cols = 80
+	}
+	rows, err := strconv.ParseUint(rowsParam, 10, 16)
+	if err != nil || rows == 0 {
+		rows = 24
+	}
+	return uint(cols), uint(rows)
+}
+
+// clientAddr returns the address of the client. Behind Traefik, which
+// connects from the Docker network, that is the last X-Forwarded-For entry,
+// the one Traefik appends; earlier entries come from the client and can't
+// be trusted. Forwarding headers of direct connections are ignored.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !(peer.IsLoopback() || peer.IsPrivate()) {
+		return r.RemoteAddr
+	}
+
+	forwarded := r.Header.Values("X-Forwarded-For")
+	if len(forwarded) == 0 {
+		return r.RemoteAddr
+	}
+	last := forwarded[len(forwarded)-1]
+	if i := strings.LastIndex(last, ","); i >= 0 {
+		last = last[i+1:]
+	}
+	if addr := strings.TrimSpace(last); addr != "" {
+		return addr
+	}
+	return r.RemoteAddr
+}
+
+// shellUser returns how a shell session's user is shown
+func shellUser(username string) string {
+	if username == "" {
+		return "password login"
+	}
+	return username
+}
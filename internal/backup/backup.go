@@ -0,0 +1,183 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// ErrBusy is returned when a backup or restore of the project is already running
+var ErrBusy = errors.New("a backup or restore of this project is already running")
+
+// ErrNotConfigured is returned when no object storage is configured
+var ErrNotConfigured = errors.New("backup storage is not configured (set BACKUP_S3_ENDPOINT)")
+
+// Manager backs up project volumes to object storage and restores them
+type Manager struct {
+	dockerClient *docker.Client
+	backupRepo   *db.BackupRepository
+	s3           *S3Client
+
+	mu   sync.Mutex
+	busy map[string]bool
+}
+
+// NewManager creates a new backup manager. s3 may be nil when no object
+// storage is configured, in which case backups and restores fail with
+// ErrNotConfigured.
+func NewManager(dockerClient *docker.Client, backupRepo *db.BackupRepository, s3 *S3Client) *Manager {
+	return &Manager{
+		dockerClient: dockerClient,
+		backupRepo:   backupRepo,
+		s3:           s3,
+		busy:         make(map[string]bool),
+	}
+}
+
+// Enabled reports whether object storage is configured
+func (m *Manager) Enabled() bool {
+	return m.s3 != nil
+}
+
+// lock marks a project as busy, or returns ErrBusy if it already is
+func (m *Manager) lock(projectID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.busy[projectID] {
+		return ErrBusy
+	}
+	m.busy[projectID] = true
+	return nil
+}
+
+func (m *Manager) unlock(projectID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.busy, projectID)
+}
+
+// IsBusy reports whether a backup or restore of the project is running
+func (m *Manager) IsBusy(projectID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.busy[projectID]
+}
+
+// BackupProject archives every named volume of a project, uploads the
+// archives and prunes old backups according to the project's policy. Every
+// volume is attempted; the returned error reports the ones that failed.
+func (m *Manager) BackupProject(ctx context.Context, project *models.Project) error {
+	if m.s3 == nil {
+		return ErrNotConfigured
+	}
+	if err := m.lock(project.ID); err != nil {
+		return err
+	}
+	defer m.unlock(project.ID)
+
+	var errs []error
+	for _, volume := range project.VolumeNames() {
+		if err := m.backupVolume(ctx, project, volume); err != nil {
+			log.Printf("Backup: failed to back up volume %s of %s: %v", volume, project.Name, err)
+			errs = append(errs, err)
+			continue
+		}
+		if project.Backup.Keep > 0 {
+			if err := m.prune(ctx, project.ID, volume, project.Backup.Keep); err != nil {
+				log.Printf("Backup: failed to prune backups of %s/%s: %v", project.Name, volume, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backupVolume archives and uploads one volume and records the outcome
+func (m *Manager) backupVolume(ctx context.Context, project *models.Project, volume string) error {
+	now := time.Now().UTC()
+	b := &models.Backup{
+		ID:        uuid.New().String(),
+		ProjectID: project.ID,
+		Volume:    volume,
+		ObjectKey: m.s3.Key(fmt.Sprintf("%s/%s/%s.tar.gz", project.ID, volume, now.Format("20060102T150405Z"))),
+		Status:    models.BackupSuccess,
+		CreatedAt: now,
+	}
+
+	err := m.dockerClient.ArchiveVolume(ctx, project.ID, volume, func(archive io.Reader, size int64) error {
+		b.Size = uint64(size)
+		return m.s3.Put(ctx, b.ObjectKey, archive, size)
+	})
+	if err != nil {
+		b.Status = models.BackupFailed
+		b.Error = err.Error()
+	}
+
+	if recordErr := m.backupRepo.Create(b); recordErr != nil {
+		log.Printf("Backup: failed to record backup of %s/%s: %v", project.Name, volume, recordErr)
+	}
+	if err == nil {
+		log.Printf("Backup: backed up %s/%s to %s (%d bytes)", project.Name, volume, b.ObjectKey, b.Size)
+	}
+	return err
+}
+
+// prune deletes all but the newest keep successful backups of a volume
+func (m *Manager) prune(ctx context.Context, projectID, volume string, keep int) error {
+	backups, err := m.backupRepo.ListSuccessful(projectID, volume)
+	if err != nil {
+		return err
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+
+	for _, b := range backups[keep:] {
+		if err := m.s3.Delete(ctx, b.ObjectKey); err != nil {
+			return err
+		}
+		if err := m.backupRepo.Delete(b.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore replaces the contents of a volume with a backup. The project's
+// containers must be stopped first.
+func (m *Manager) Restore(ctx context.Context, project *models.Project, b *models.Backup) error {
+	if m.s3 == nil {
+		return ErrNotConfigured
+	}
+	if b.ProjectID != project.ID || b.Status != models.BackupSuccess {
+		return fmt.Errorf("backup %s cannot be restored into %s", b.ID, project.Name)
+	}
+	if err := m.lock(project.ID); err != nil {
+		return err
+	}
+	defer m.unlock(project.ID)
+
+	body, size, err := m.s3.Get(ctx, b.ObjectKey)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if size < 0 {
+		return fmt.Errorf("S3 did not report the size of %s", b.ObjectKey)
+	}
+
+	if err := m.dockerClient.RestoreVolume(ctx, project.ID, b.Volume, body, size); err != nil {
+		return err
+	}
+
+	log.Printf("Backup: restored %s/%s from %s", project.Name, b.Volume, b.ObjectKey)
+	return nil
+}
@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// CheckInterval is how often the scheduler looks for due backups
+const CheckInterval = time.Minute
+
+// Scheduler runs project backups according to their backup policies
+type Scheduler struct {
+	manager     *Manager
+	projectRepo *db.ProjectRepository
+	backupRepo  *db.BackupRepository
+	interval    time.Duration
+	stopCh      chan struct{}
+	wg          sync.WaitGroup
+	running     bool
+	mu          sync.Mutex
+}
+
+// NewScheduler creates a new backup scheduler
+func NewScheduler(manager *Manager, projectRepo *db.ProjectRepository, backupRepo *db.BackupRepository, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		manager:     manager,
+		projectRepo: projectRepo,
+		backupRepo:  backupRepo,
+		interval:    interval,
+		stopCh:      make(chan struct{}),
+	}
+}
+
+// Start starts the scheduler
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("Backup scheduler started with interval %v", s.interval)
+}
+
+// Stop stops the scheduler and waits for a running backup to finish
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	log.Println("Backup scheduler stopped")
+}
+
+// run is the main scheduler loop
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.checkAll()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkAll()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// checkAll backs up every project whose backup is due
+func (s *Scheduler) checkAll() {
+	projects, err := s.projectRepo.List()
+	if err != nil {
+		log.Printf("Backup: failed to list projects: %v", err)
+		return
+	}
+
+	for _, project := range projects {
+		if !s.isDue(project) {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		if err := s.manager.BackupProject(ctx, project); err != nil && err != ErrBusy {
+			log.Printf("Backup: scheduled backup of %s failed: %v", project.Name, err)
+		}
+		cancel()
+	}
+}
+
+// isDue reports whether a project's next scheduled backup is due. Failed
+// backups count as runs so a broken project is retried on the next interval
+// rather than every minute.
+func (s *Scheduler) isDue(project *models.Project) bool {
+	if !project.Backup.Enabled || len(project.VolumeNames()) == 0 {
+		return false
+	}
+
+	last, err := s.backupRepo.LastRun(project.ID)
+	if err != nil {
+		log.Printf("Backup: failed to get last backup of %s: %v", project.Name, err)
+		return false
+	}
+	return time.Since(last) >= project.Backup.Interval()
+}
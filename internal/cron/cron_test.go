@@ -0,0 +1,98 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@every",
+	}
+	for _, expr := range tests {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{"* * * * *", "2024-01-01T10:00:30Z", "2024-01-01T10:01:00Z"},
+		{"*/15 * * * *", "2024-01-01T10:01:00Z", "2024-01-01T10:15:00Z"},
+		{"0 3 * * *", "2024-01-01T03:00:00Z", "2024-01-02T03:00:00Z"},
+		{"@hourly", "2024-01-01T10:59:00Z", "2024-01-01T11:00:00Z"},
+		{"@daily", "2024-12-31T12:00:00Z", "2025-01-01T00:00:00Z"},
+		{"0 0 1 1 *", "2024-06-01T00:00:00Z", "2025-01-01T00:00:00Z"},
+		{"30 9 * * mon-fri", "2024-01-06T00:00:00Z", "2024-01-08T09:30:00Z"},
+		{"0 0 * * 7", "2024-01-01T00:00:00Z", "2024-01-07T00:00:00Z"},
+		{"0 0 * jan,jul *", "2024-02-01T00:00:00Z", "2024-07-01T00:00:00Z"},
+		{"5/20 * * * *", "2024-01-01T10:06:00Z", "2024-01-01T10:25:00Z"},
+		{"0 12 29 feb *", "2024-03-01T00:00:00Z", "2028-02-29T12:00:00Z"},
+		// A restricted day of month and day of week match either
+		{"0 0 13 * fri", "2024-01-01T00:00:00Z", "2024-01-05T00:00:00Z"},
+		{"0 0 13 * fri", "2024-01-06T00:00:00Z", "2024-01-12T00:00:00Z"},
+		{"0 0 13 * fri", "2024-01-12T00:00:00Z", "2024-01-13T00:00:00Z"},
+		// With a * day of week only the day of month counts
+		{"0 0 13 * *", "2024-01-01T00:00:00Z", "2024-01-13T00:00:00Z"},
+		{"0 0 */2 * mon", "2024-01-01T00:00:00Z", "2024-01-15T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		from, _ := time.Parse(time.RFC3339, tt.from)
+		want, _ := time.Parse(time.RFC3339, tt.want)
+		if got := s.Next(from); !got.Equal(want) {
+			t.Errorf("Parse(%q).Next(%s) = %s, want %s", tt.expr, tt.from, got.Format(time.RFC3339), tt.want)
+		}
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	s, err := Parse("0 0 30 feb *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("Next = %s, want zero time", got)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	s, err := Parse("30 9 * * 1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2024, 1, 8, 9, 30, 0, 0, time.UTC), true},
+		{time.Date(2024, 1, 8, 9, 30, 59, 0, time.UTC), true},
+		{time.Date(2024, 1, 8, 9, 31, 0, 0, time.UTC), false},
+		{time.Date(2024, 1, 7, 9, 30, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := s.Matches(tt.at); got != tt.want {
+			t.Errorf("Matches(%s) = %v, want %v", tt.at, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// addonColumns is the column list used by every add-on SELECT, in the order
+// expected by scanAddon
+const addonColumns = `id, name, type, version, username, password, database_name,
+	status, status_msg, container_id, created_at, updated_at`
+
+// scanAddon scans a row selected with addonColumns
+func scanAddon(row rowScanner) (*models.Addon, error) {
+	a := &models.Addon{}
+	err := row.Scan(
+		&a.ID, &a.Name, &a.Type, &a.Version, &a.Username, &a.Password, &a.Database,
+		&a.Status, &a.StatusMsg, &a.ContainerID, &a.CreatedAt, &a.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// AddonRepository handles add-on and project link database operations
+type AddonRepository struct {
+	db *DB
+}
+
+// NewAddonRepository creates a new add-on repository
+func NewAddonRepository(db *DB) *AddonRepository {
+	return &AddonRepository{db: db}
+}
+
+// Create creates a new add-on
+func (r *AddonRepository) Create(a *models.Addon) error {
+	now := time.Now()
+	a.CreatedAt = now
+	a.UpdatedAt = now
+
+	_, err := r.db.Exec(`
+		INSERT INTO addons (
+			id, name, type, version, username, password, database_name,
+			status, status_msg, container_id, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`,
+		a.ID, a.Name, a.Type, a.Version, a.Username, a.Password, a.Database,
+		a.Status, a.StatusMsg, a.ContainerID, a.CreatedAt, a.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create add-on: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an add-on by ID
+func (r *AddonRepository) GetByID(id string) (*models.Addon, error) {
+	a, err := scanAddon(r.db.QueryRow(
+		"SELECT "+addonColumns+" FROM addons WHERE id = ?", id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get add-on: %w", err)
+	}
+	return a, nil
+}
+
+// GetByName retrieves an add-on by name
+func (r *AddonRepository) GetByName(name string) (*models.Addon, error) {
+	a, err := scanAddon(r.db.QueryRow(
+		"SELECT "+addonColumns+" FROM addons WHERE name = ?", name,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get add-on by name: %w", err)
+	}
+	return a, nil
+}
+
+// List retrieves all add-ons
+func (r *AddonRepository) List() ([]*models.Addon, error) {
+	rows, err := r.db.Query(
+		"SELECT " + addonColumns + " FROM addons ORDER BY name",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list add-ons: %w", err)
+	}
+	defer rows.Close()
+
+	var addons []*models.Addon
+	for rows.Next() {
+		a, err := scanAddon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan add-on: %w", err)
+		}
+		addons = append(addons, a)
+	}
+
+	return addons, nil
+}
+
+// UpdateStatus updates the status and container of an add-on
+func (r *AddonRepository) UpdateStatus(id string, status models.ProjectStatus, statusMsg, containerID string) error {
+	_, err := r.db.Exec(`
+		UPDATE addons SET status = ?, status_msg = ?, container_id = ?, updated_at = ? WHERE id = ?
+	`, status, statusMsg, containerID, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update add-on status: %w", err)
+	}
+	return nil
+}
+
+// Delete deletes an add-on and its project links
+func (r *AddonRepository) Delete(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM project_addons WHERE addon_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete add-on links: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM addons WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete add-on: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListLinks retrieves the add-on links of a project
+func (r *AddonRepository) ListLinks(projectID string) ([]models.AddonLink, error) {
+	return r.listLinks("SELECT project_id, addon_id, env_var FROM project_addons WHERE project_id = ? ORDER BY env_var", projectID)
+}
+
+// ListLinksByAddon retrieves the project links of an add-on
+func (r *AddonRepository) ListLinksByAddon(addonID string) ([]models.AddonLink, error) {
+	return r.listLinks("SELECT project_id, addon_id, env_var FROM project_addons WHERE addon_id = ?", addonID)
+}
+
+// listLinks runs a project_addons query
+func (r *AddonRepository) listLinks(query string, args ...interface{}) ([]models.AddonLink, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list add-on links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []models.AddonLink
+	for rows.Next() {
+		var link models.AddonLink
+		if err := rows.Scan(&link.ProjectID, &link.AddonID, &link.EnvVar); err != nil {
+			return nil, fmt.Errorf("failed to scan add-on link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	return links, nil
+}
+
+// SetLinks replaces the add-on links of a project
+func (r *AddonRepository) SetLinks(projectID string, links []models.AddonLink) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM project_addons WHERE project_id = ?", projectID); err != nil {
+		return fmt.Errorf("failed to clear add-on links: %w", err)
+	}
+	for _, link := range links {
+		_, err := tx.Exec(
+			"INSERT INTO project_addons (project_id, addon_id, env_var) VALUES (?, ?, ?)",
+			projectID, link.AddonID, link.EnvVar,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to link add-on: %w", err)
+		}
+	}
+	return tx.Commit()
+}
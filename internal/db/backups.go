@@ -0,0 +1,132 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// backupColumns is the column list used by every backup SELECT, in the
+// order expected by scanBackup
+const backupColumns = `id, project_id, volume, object_key, size, status, error, created_at`
+
+// scanBackup scans a row selected with backupColumns
+func scanBackup(row rowScanner) (*models.Backup, error) {
+	b := &models.Backup{}
+	err := row.Scan(&b.ID, &b.ProjectID, &b.Volume, &b.ObjectKey, &b.Size, &b.Status, &b.Error, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// BackupRepository handles volume backup database operations
+type BackupRepository struct {
+	db *DB
+}
+
+// NewBackupRepository creates a new backup repository
+func NewBackupRepository(db *DB) *BackupRepository {
+	return &BackupRepository{db: db}
+}
+
+// Create records a backup
+func (r *BackupRepository) Create(b *models.Backup) error {
+	if b.CreatedAt.IsZero() {
+		b.CreatedAt = time.Now()
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO backups (id, project_id, volume, object_key, size, status, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, b.ID, b.ProjectID, b.Volume, b.ObjectKey, b.Size, b.Status, b.Error, b.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create backup: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a backup by ID
+func (r *BackupRepository) GetByID(id string) (*models.Backup, error) {
+	b, err := scanBackup(r.db.QueryRow(
+		"SELECT "+backupColumns+" FROM backups WHERE id = ?", id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get backup: %w", err)
+	}
+	return b, nil
+}
+
+// ListByProject retrieves a project's backups, newest first
+func (r *BackupRepository) ListByProject(projectID string) ([]*models.Backup, error) {
+	return r.list(
+		"SELECT "+backupColumns+" FROM backups WHERE project_id = ? ORDER BY created_at DESC",
+		projectID,
+	)
+}
+
+// ListSuccessful retrieves the successful backups of a project volume,
+// newest first
+func (r *BackupRepository) ListSuccessful(projectID, volume string) ([]*models.Backup, error) {
+	return r.list(
+		"SELECT "+backupColumns+" FROM backups WHERE project_id = ? AND volume = ? AND status = ? ORDER BY created_at DESC",
+		projectID, volume, models.BackupSuccess,
+	)
+}
+
+// list runs a backup query
+func (r *BackupRepository) list(query string, args ...interface{}) ([]*models.Backup, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+	defer rows.Close()
+
+	var backups []*models.Backup
+	for rows.Next() {
+		b, err := scanBackup(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan backup: %w", err)
+		}
+		backups = append(backups, b)
+	}
+
+	return backups, nil
+}
+
+// LastRun returns when a project was last backed up, successfully or not, or
+// the zero time if it never was
+func (r *BackupRepository) LastRun(projectID string) (time.Time, error) {
+	var last time.Time
+	err := r.db.QueryRow(
+		"SELECT created_at FROM backups WHERE project_id = ? ORDER BY created_at DESC LIMIT 1", projectID,
+	).Scan(&last)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last backup: %w", err)
+	}
+	return last, nil
+}
+
+// Delete deletes a backup record
+func (r *BackupRepository) Delete(id string) error {
+	if _, err := r.db.Exec("DELETE FROM backups WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete backup: %w", err)
+	}
+	return nil
+}
+
+// DeleteByProject deletes all backup records of a project
+func (r *BackupRepository) DeleteByProject(projectID string) error {
+	if _, err := r.db.Exec("DELETE FROM backups WHERE project_id = ?", projectID); err != nil {
+		return fmt.Errorf("failed to delete backups: %w", err)
+	}
+	return nil
+}
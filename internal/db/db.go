@@ -51,6 +51,65 @@ func New(dataDir string) (*DB, error) {
 	return wrappedDB, nil
 }
 
+// Backup writes a consistent snapshot of the database to path. It is safe to
+// call while the database is in use.
+func (db *DB) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file %s already exists", path)
+	}
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the database in dataDir with the backup at path. The
+// backup is checked before anything is overwritten. The server must not be
+// running against dataDir while restoring.
+func Restore(dataDir, path string) error {
+	// Opening a missing file would create an empty database
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	backup, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	var version int
+	err = backup.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	backup.Close()
+	if err != nil {
+		return fmt.Errorf("%s is not a SlimDeploy backup: %w", path, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	dbPath := filepath.Join(dataDir, "slimdeploy.db")
+	tmpPath := dbPath + ".restore"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write database: %w", err)
+	}
+
+	// Drop the write-ahead log of the old database so it isn't replayed
+	// on top of the restored one
+	os.Remove(dbPath + "-wal")
+	os.Remove(dbPath + "-shm")
+
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+
+	return nil
+}
+
 // Close closes the database connection
 func (db *DB) Close() error {
 	return db.DB.Close()
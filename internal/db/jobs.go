@@ -0,0 +1,216 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// jobColumns is the column list used by every job SELECT, in the order
+// expected by scanJob
+const jobColumns = `id, project_id, name, schedule, command, service, timeout_seconds, created_at, updated_at`
+
+// scanJob scans a row selected with jobColumns
+func scanJob(row rowScanner) (*models.Job, error) {
+	j := &models.Job{}
+	err := row.Scan(
+		&j.ID, &j.ProjectID, &j.Name, &j.Schedule, &j.Command, &j.Service,
+		&j.TimeoutSeconds, &j.CreatedAt, &j.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// jobRunColumns is the column list used by every job run SELECT, in the
+// order expected by scanJobRun
+const jobRunColumns = `id, job_id, project_id, trigger, status, exit_code, output, error, started_at, finished_at`
+
+// scanJobRun scans a row selected with jobRunColumns
+func scanJobRun(row rowScanner) (*models.JobRun, error) {
+	run := &models.JobRun{}
+	var finishedAt sql.NullTime
+	err := row.Scan(
+		&run.ID, &run.JobID, &run.ProjectID, &run.Trigger, &run.Status, &run.ExitCode,
+		&run.Output, &run.Error, &run.StartedAt, &finishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	run.FinishedAt = finishedAt.Time
+	return run, nil
+}
+
+// JobRepository handles scheduled job and job run database operations
+type JobRepository struct {
+	db *DB
+}
+
+// NewJobRepository creates a new job repository
+func NewJobRepository(db *DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// Create creates a new job
+func (r *JobRepository) Create(j *models.Job) error {
+	now := time.Now()
+	j.CreatedAt = now
+	j.UpdatedAt = now
+
+	_, err := r.db.Exec(`
+		INSERT INTO jobs (id, project_id, name, schedule, command, service, timeout_seconds, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, j.ID, j.ProjectID, j.Name, j.Schedule, j.Command, j.Service, j.TimeoutSeconds, j.CreatedAt, j.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create job: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a job by ID
+func (r *JobRepository) GetByID(id string) (*models.Job, error) {
+	j, err := scanJob(r.db.QueryRow(
+		"SELECT "+jobColumns+" FROM jobs WHERE id = ?", id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return j, nil
+}
+
+// List retrieves all jobs
+func (r *JobRepository) List() ([]*models.Job, error) {
+	return r.list("SELECT " + jobColumns + " FROM jobs ORDER BY created_at")
+}
+
+// ListByProject retrieves a project's jobs
+func (r *JobRepository) ListByProject(projectID string) ([]*models.Job, error) {
+	return r.list("SELECT "+jobColumns+" FROM jobs WHERE project_id = ? ORDER BY name", projectID)
+}
+
+// list runs a job query
+func (r *JobRepository) list(query string, args ...interface{}) ([]*models.Job, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*models.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+// Delete deletes a job and its runs
+func (r *JobRepository) Delete(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM job_runs WHERE job_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete job runs: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM jobs WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete job: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteByProject deletes all jobs and job runs of a project
+func (r *JobRepository) DeleteByProject(projectID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM job_runs WHERE project_id = ?", projectID); err != nil {
+		return fmt.Errorf("failed to delete job runs: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM jobs WHERE project_id = ?", projectID); err != nil {
+		return fmt.Errorf("failed to delete jobs: %w", err)
+	}
+	return tx.Commit()
+}
+
+// CreateRun records the start of a job run
+func (r *JobRepository) CreateRun(run *models.JobRun) error {
+	_, err := r.db.Exec(`
+		INSERT INTO job_runs (id, job_id, project_id, trigger, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, run.ID, run.JobID, run.ProjectID, run.Trigger, run.Status, run.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create job run: %w", err)
+	}
+	return nil
+}
+
+// FinishRun records the outcome of a job run and drops the job's runs
+// beyond models.JobRunHistory
+func (r *JobRepository) FinishRun(run *models.JobRun) error {
+	_, err := r.db.Exec(`
+		UPDATE job_runs SET status = ?, exit_code = ?, output = ?, error = ?, finished_at = ? WHERE id = ?
+	`, run.Status, run.ExitCode, run.Output, run.Error, run.FinishedAt, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to finish job run: %w", err)
+	}
+
+	_, err = r.db.Exec(`
+		DELETE FROM job_runs WHERE job_id = ? AND id NOT IN (
+			SELECT id FROM job_runs WHERE job_id = ? ORDER BY started_at DESC LIMIT ?
+		)
+	`, run.JobID, run.JobID, models.JobRunHistory)
+	if err != nil {
+		return fmt.Errorf("failed to prune job runs: %w", err)
+	}
+	return nil
+}
+
+// FailUnfinishedRuns marks runs left running by a previous process as failed
+func (r *JobRepository) FailUnfinishedRuns() error {
+	_, err := r.db.Exec(`
+		UPDATE job_runs SET status = ?, error = ?, finished_at = ? WHERE status = ?
+	`, models.JobFailed, "interrupted by a SlimDeploy restart", time.Now(), models.JobRunning)
+	if err != nil {
+		return fmt.Errorf("failed to update unfinished job runs: %w", err)
+	}
+	return nil
+}
+
+// ListRunsByProject retrieves a project's most recent job runs, newest first
+func (r *JobRepository) ListRunsByProject(projectID string, limit int) ([]*models.JobRun, error) {
+	rows, err := r.db.Query(
+		"SELECT "+jobRunColumns+" FROM job_runs WHERE project_id = ? ORDER BY started_at DESC LIMIT ?",
+		projectID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list job runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.JobRun
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
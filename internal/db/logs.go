@@ -0,0 +1,243 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// DefaultLogSearchLimit is the number of lines a search returns when the
+// query sets no limit
+const DefaultLogSearchLimit = 500
+
+// ErrInvalidLogQuery is returned by Search when the full-text query can't be
+// parsed
+var ErrInvalidLogQuery = errors.New("invalid search query")
+
+// logColumns is the column list used by every log SELECT, in the order
+// expected by scanLogRecord
+const logColumns = `id, project_id, service, container_id, stream, time, text`
+
+// scanLogRecord scans a row selected with logColumns
+func scanLogRecord(row rowScanner) (*models.LogRecord, error) {
+	l := &models.LogRecord{}
+	err := row.Scan(&l.ID, &l.ProjectID, &l.Service, &l.ContainerID, &l.Stream, &l.Time, &l.Text)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// LogRepository handles collected container log database operations. Times
+// are stored in UTC so they compare correctly.
+type LogRepository struct {
+	db *DB
+}
+
+// NewLogRepository creates a new log repository
+func NewLogRepository(db *DB) *LogRepository {
+	return &LogRepository{db: db}
+}
+
+// Insert stores log lines in a single transaction
+func (r *LogRepository) Insert(records []models.LogRecord) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO container_logs (project_id, service, container_id, stream, time, text)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare log insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, l := range records {
+		if _, err := stmt.Exec(l.ProjectID, l.Service, l.ContainerID, l.Stream, l.Time.UTC(), l.Text); err != nil {
+			return fmt.Errorf("failed to insert log line: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit log lines: %w", err)
+	}
+	return nil
+}
+
+// LastTime returns the time of the latest stored line of a container, zero
+// if none are stored
+func (r *LogRepository) LastTime(containerID string) (time.Time, error) {
+	var t time.Time
+	err := r.db.QueryRow(`
+		SELECT time FROM container_logs WHERE container_id = ? ORDER BY time DESC LIMIT 1
+	`, containerID).Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get last log time: %w", err)
+	}
+	return t, nil
+}
+
+// Search returns the lines matching a query, newest first
+func (r *LogRepository) Search(q models.LogQuery) ([]*models.LogRecord, error) {
+	where := []string{"project_id = ?"}
+	args := []interface{}{q.ProjectID}
+
+	if q.Text != "" {
+		where = append(where, "id IN (SELECT docid FROM container_logs_fts WHERE container_logs_fts MATCH ?)")
+		args = append(args, q.Text)
+	}
+	if len(q.Services) > 0 {
+		where = append(where, "service IN (?"+strings.Repeat(", ?", len(q.Services)-1)+")")
+		for _, service := range q.Services {
+			args = append(args, service)
+		}
+	}
+	if q.Stream != "" {
+		where = append(where, "stream = ?")
+		args = append(args, q.Stream)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "time <= ?")
+		args = append(args, q.Until.UTC())
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLogSearchLimit
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.Query(
+		"SELECT "+logColumns+" FROM container_logs WHERE "+strings.Join(where, " AND ")+
+			" ORDER BY time DESC, id DESC LIMIT ?",
+		args...,
+	)
+	if err != nil {
+		return nil, searchError(q, err)
+	}
+	defer rows.Close()
+
+	var records []*models.LogRecord
+	for rows.Next() {
+		l, err := scanLogRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan log line: %w", err)
+		}
+		records = append(records, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, searchError(q, err)
+	}
+	return records, nil
+}
+
+// searchError wraps a search error, reporting malformed full-text queries
+// as ErrInvalidLogQuery. SQLite only parses the query once rows are read.
+func searchError(q models.LogQuery, err error) error {
+	if strings.Contains(err.Error(), "malformed MATCH") {
+		return fmt.Errorf("%w: %q", ErrInvalidLogQuery, q.Text)
+	}
+	return fmt.Errorf("failed to search logs: %w", err)
+}
+
+// Services returns the sorted names of the services a project has stored
+// lines of
+func (r *LogRepository) Services(projectID string) ([]string, error) {
+	rows, err := r.db.Query(
+		"SELECT DISTINCT service FROM container_logs WHERE project_id = ? ORDER BY service", projectID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list log services: %w", err)
+	}
+	defer rows.Close()
+
+	var services []string
+	for rows.Next() {
+		var service string
+		if err := rows.Scan(&service); err != nil {
+			return nil, fmt.Errorf("failed to scan log service: %w", err)
+		}
+		services = append(services, service)
+	}
+	return services, rows.Err()
+}
+
+// Stats returns the number of stored lines of a project and the time of the
+// oldest one
+func (r *LogRepository) Stats(projectID string) (int, time.Time, error) {
+	var count int
+	if err := r.db.QueryRow(
+		"SELECT COUNT(*) FROM container_logs WHERE project_id = ?", projectID,
+	).Scan(&count); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count logs: %w", err)
+	}
+
+	var oldest time.Time
+	err := r.db.QueryRow(`
+		SELECT time FROM container_logs WHERE project_id = ? ORDER BY time LIMIT 1
+	`, projectID).Scan(&oldest)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, time.Time{}, fmt.Errorf("failed to get oldest log time: %w", err)
+	}
+	return count, oldest, nil
+}
+
+// Prune deletes a project's lines older than before and all but its newest
+// maxLines lines, and returns the number deleted
+func (r *LogRepository) Prune(projectID string, before time.Time, maxLines int) (int64, error) {
+	result, err := r.db.Exec(
+		"DELETE FROM container_logs WHERE project_id = ? AND time < ?",
+		projectID, before.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune logs: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+
+	// Lines are inserted roughly in time order, so the highest IDs are the
+	// newest
+	result, err = r.db.Exec(`
+		DELETE FROM container_logs WHERE project_id = ? AND id <= (
+			SELECT id FROM container_logs WHERE project_id = ? ORDER BY id DESC LIMIT 1 OFFSET ?
+		)
+	`, projectID, projectID, maxLines)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to prune logs: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return deleted + n, nil
+}
+
+// DeleteByProject deletes all lines of a project
+func (r *LogRepository) DeleteByProject(projectID string) error {
+	if _, err := r.db.Exec("DELETE FROM container_logs WHERE project_id = ?", projectID); err != nil {
+		return fmt.Errorf("failed to delete logs: %w", err)
+	}
+	return nil
+}
+
+// DeleteOrphaned deletes the lines of projects that no longer exist
+func (r *LogRepository) DeleteOrphaned() (int64, error) {
+	result, err := r.db.Exec(
+		"DELETE FROM container_logs WHERE project_id NOT IN (SELECT id FROM projects)",
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete orphaned logs: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+	"log"
 )
 
 // Migration represents a database migration
@@ -54,6 +55,266 @@ var migrations = []Migration{
 			CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
 		`,
 	},
+	{
+		Version: 3,
+		Name:    "create_users_table",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS users (
+				username TEXT PRIMARY KEY,
+				password_hash TEXT NOT NULL,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			ALTER TABLE sessions ADD COLUMN username TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "create_api_tokens_table",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS api_tokens (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				token_hash TEXT NOT NULL UNIQUE,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_used_at DATETIME
+			);
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "add_projects_resource_limits",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN resource_limits TEXT NOT NULL DEFAULT '{}';
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "add_projects_mounts",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN mounts TEXT NOT NULL DEFAULT '[]';
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "create_backups_table",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN backup_policy TEXT NOT NULL DEFAULT '{}';
+
+			CREATE TABLE IF NOT EXISTS backups (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				volume TEXT NOT NULL,
+				object_key TEXT NOT NULL DEFAULT '',
+				size INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL,
+				error TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_backups_project ON backups(project_id, created_at);
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "create_addons_tables",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS addons (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL UNIQUE,
+				type TEXT NOT NULL,
+				version TEXT NOT NULL,
+				username TEXT NOT NULL DEFAULT '',
+				password TEXT NOT NULL DEFAULT '',
+				database_name TEXT NOT NULL DEFAULT '',
+				status TEXT NOT NULL DEFAULT 'pending',
+				status_msg TEXT NOT NULL DEFAULT '',
+				container_id TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS project_addons (
+				project_id TEXT NOT NULL,
+				addon_id TEXT NOT NULL,
+				env_var TEXT NOT NULL,
+				PRIMARY KEY (project_id, addon_id)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_project_addons_addon ON project_addons(addon_id);
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "create_jobs_tables",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS jobs (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				name TEXT NOT NULL,
+				schedule TEXT NOT NULL,
+				command TEXT NOT NULL,
+				service TEXT NOT NULL DEFAULT '',
+				timeout_seconds INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_jobs_project ON jobs(project_id);
+
+			CREATE TABLE IF NOT EXISTS job_runs (
+				id TEXT PRIMARY KEY,
+				job_id TEXT NOT NULL,
+				project_id TEXT NOT NULL,
+				trigger TEXT NOT NULL,
+				status TEXT NOT NULL,
+				exit_code INTEGER NOT NULL DEFAULT 0,
+				output TEXT NOT NULL DEFAULT '',
+				error TEXT NOT NULL DEFAULT '',
+				started_at DATETIME NOT NULL,
+				finished_at DATETIME
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_job_runs_job ON job_runs(job_id, started_at);
+			CREATE INDEX IF NOT EXISTS idx_job_runs_project ON job_runs(project_id, started_at);
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "add_projects_release_config",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN release_config TEXT NOT NULL DEFAULT '{}';
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "create_notification_tables",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS notification_channels (
+				id TEXT PRIMARY KEY,
+				name TEXT NOT NULL,
+				type TEXT NOT NULL,
+				url TEXT NOT NULL DEFAULT '',
+				secret TEXT NOT NULL DEFAULT '',
+				email_to TEXT NOT NULL DEFAULT '',
+				created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS project_notifications (
+				project_id TEXT NOT NULL,
+				channel_id TEXT NOT NULL,
+				events TEXT NOT NULL DEFAULT '[]',
+				PRIMARY KEY (project_id, channel_id)
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_project_notifications_channel ON project_notifications(channel_id);
+		`,
+	},
+	{
+		Version: 12,
+		Name:    "add_projects_crash_tracking",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN last_exit_code INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE projects ADD COLUMN oom_kills INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE projects ADD COLUMN last_crash_at DATETIME;
+		`,
+	},
+	{
+		Version: 13,
+		Name:    "create_container_logs",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN log_retention TEXT NOT NULL DEFAULT '{}';
+
+			CREATE TABLE IF NOT EXISTS container_logs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				project_id TEXT NOT NULL,
+				service TEXT NOT NULL,
+				container_id TEXT NOT NULL,
+				stream TEXT NOT NULL,
+				time DATETIME NOT NULL,
+				text TEXT NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_container_logs_project_time ON container_logs(project_id, time);
+			CREATE INDEX IF NOT EXISTS idx_container_logs_container_time ON container_logs(container_id, time);
+
+			-- Full-text index over the log text, kept in sync by triggers
+			CREATE VIRTUAL TABLE IF NOT EXISTS container_logs_fts USING fts4(content="container_logs", text);
+
+			CREATE TRIGGER IF NOT EXISTS container_logs_ai AFTER INSERT ON container_logs BEGIN
+				INSERT INTO container_logs_fts(docid, text) VALUES (new.id, new.text);
+			END;
+
+			CREATE TRIGGER IF NOT EXISTS container_logs_bd BEFORE DELETE ON container_logs BEGIN
+				DELETE FROM container_logs_fts WHERE docid = old.id;
+			END;
+		`,
+	},
+	{
+		Version: 14,
+		Name:    "create_shell_sessions",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS shell_sessions (
+				id TEXT PRIMARY KEY,
+				project_id TEXT NOT NULL,
+				username TEXT NOT NULL DEFAULT '',
+				remote_addr TEXT NOT NULL DEFAULT '',
+				service TEXT NOT NULL DEFAULT '',
+				container_id TEXT NOT NULL,
+				started_at DATETIME NOT NULL,
+				ended_at DATETIME
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_shell_sessions_project ON shell_sessions(project_id, started_at);
+		`,
+	},
+	{
+		Version: 15,
+		Name:    "add_projects_previews",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN preview_config TEXT NOT NULL DEFAULT '{}';
+			ALTER TABLE projects ADD COLUMN preview_of TEXT NOT NULL DEFAULT '';
+			ALTER TABLE projects ADD COLUMN preview_expires_at DATETIME;
+
+			CREATE INDEX IF NOT EXISTS idx_projects_preview_of ON projects(preview_of);
+		`,
+	},
+	{
+		Version: 16,
+		Name:    "add_projects_tag_tracking",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN tag_rule TEXT NOT NULL DEFAULT '';
+			ALTER TABLE projects ADD COLUMN deployed_tag TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version: 17,
+		Name:    "add_projects_pinned_commit",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN pinned_commit TEXT NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version: 18,
+		Name:    "add_projects_path_filter",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN path_filter TEXT NOT NULL DEFAULT '{}';
+		`,
+	},
+	{
+		Version: 19,
+		Name:    "add_projects_compose_config",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN compose_config TEXT NOT NULL DEFAULT '{}';
+		`,
+	},
+	{
+		Version: 20,
+		Name:    "add_projects_commits_ahead",
+		SQL: `
+			ALTER TABLE projects ADD COLUMN commits_ahead INTEGER NOT NULL DEFAULT -1;
+		`,
+	},
 }
 
 // Migrate runs all pending migrations
@@ -106,7 +367,7 @@ func (db *DB) Migrate() error {
 			return fmt.Errorf("failed to commit migration %d: %w", m.Version, err)
 		}
 
-		fmt.Printf("Applied migration %d: %s\n", m.Version, m.Name)
+		log.Printf("Applied migration %d: %s", m.Version, m.Name)
 	}
 
 	return nil
@@ -0,0 +1,163 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// channelColumns is the column list used by every notification channel
+// SELECT, in the order expected by scanChannel
+const channelColumns = `id, name, type, url, secret, email_to, created_at`
+
+// scanChannel scans a row selected with channelColumns
+func scanChannel(row rowScanner) (*models.NotificationChannel, error) {
+	c := &models.NotificationChannel{}
+	err := row.Scan(&c.ID, &c.Name, &c.Type, &c.URL, &c.Secret, &c.EmailTo, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NotificationRepository handles notification channel and project
+// subscription database operations
+type NotificationRepository struct {
+	db *DB
+}
+
+// NewNotificationRepository creates a new notification repository
+func NewNotificationRepository(db *DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// CreateChannel creates a new notification channel
+func (r *NotificationRepository) CreateChannel(c *models.NotificationChannel) error {
+	c.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(`
+		INSERT INTO notification_channels (id, name, type, url, secret, email_to, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, c.ID, c.Name, c.Type, c.URL, c.Secret, c.EmailTo, c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create notification channel: %w", err)
+	}
+	return nil
+}
+
+// GetChannel retrieves a notification channel by ID
+func (r *NotificationRepository) GetChannel(id string) (*models.NotificationChannel, error) {
+	c, err := scanChannel(r.db.QueryRow(
+		"SELECT "+channelColumns+" FROM notification_channels WHERE id = ?", id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification channel: %w", err)
+	}
+	return c, nil
+}
+
+// ListChannels retrieves all notification channels
+func (r *NotificationRepository) ListChannels() ([]*models.NotificationChannel, error) {
+	rows, err := r.db.Query(
+		"SELECT " + channelColumns + " FROM notification_channels ORDER BY name",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification channels: %w", err)
+	}
+	defer rows.Close()
+
+	var channels []*models.NotificationChannel
+	for rows.Next() {
+		c, err := scanChannel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan notification channel: %w", err)
+		}
+		channels = append(channels, c)
+	}
+
+	return channels, nil
+}
+
+// DeleteChannel deletes a notification channel and its subscriptions
+func (r *NotificationRepository) DeleteChannel(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM project_notifications WHERE channel_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete notification subscriptions: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM notification_channels WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete notification channel: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ListSubscriptions retrieves the notification subscriptions of a project
+func (r *NotificationRepository) ListSubscriptions(projectID string) ([]models.NotificationSubscription, error) {
+	return r.listSubscriptions("SELECT project_id, channel_id, events FROM project_notifications WHERE project_id = ?", projectID)
+}
+
+// ListSubscriptionsByChannel retrieves the project subscriptions of a channel
+func (r *NotificationRepository) ListSubscriptionsByChannel(channelID string) ([]models.NotificationSubscription, error) {
+	return r.listSubscriptions("SELECT project_id, channel_id, events FROM project_notifications WHERE channel_id = ?", channelID)
+}
+
+// listSubscriptions runs a project_notifications query
+func (r *NotificationRepository) listSubscriptions(query string, args ...interface{}) ([]models.NotificationSubscription, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []models.NotificationSubscription
+	for rows.Next() {
+		var sub models.NotificationSubscription
+		var events string
+		if err := rows.Scan(&sub.ProjectID, &sub.ChannelID, &events); err != nil {
+			return nil, fmt.Errorf("failed to scan notification subscription: %w", err)
+		}
+		if err := json.Unmarshal([]byte(events), &sub.Events); err != nil {
+			return nil, fmt.Errorf("failed to parse notification events: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, nil
+}
+
+// SetSubscriptions replaces the notification subscriptions of a project
+func (r *NotificationRepository) SetSubscriptions(projectID string, subs []models.NotificationSubscription) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM project_notifications WHERE project_id = ?", projectID); err != nil {
+		return fmt.Errorf("failed to clear notification subscriptions: %w", err)
+	}
+	for _, sub := range subs {
+		events, err := json.Marshal(sub.Events)
+		if err != nil {
+			return fmt.Errorf("failed to encode notification events: %w", err)
+		}
+		_, err = tx.Exec(
+			"INSERT INTO project_notifications (project_id, channel_id, events) VALUES (?, ?, ?)",
+			projectID, sub.ChannelID, string(events),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to subscribe channel: %w", err)
+		}
+	}
+	return tx.Commit()
+}
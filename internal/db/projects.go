@@ -5,17 +5,90 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/mhenrichsen/slimdeploy/internal/events"
 	"github.com/mhenrichsen/slimdeploy/internal/models"
 )
 
+// projectColumns is the column list used by every project SELECT, in the
+// order expected by scanProject
+const projectColumns = `id, name, git_url, branch, tag_rule, deployed_tag, pinned_commit, commits_ahead, deploy_type, image, domain,
+	use_subdomain, port, env_vars, auto_deploy, last_commit, status, status_msg,
+	container_ids, resource_limits, mounts, backup_policy, release_config,
+	log_retention, preview_config, path_filter, compose_config, preview_of, preview_expires_at, last_exit_code,
+	oom_kills, last_crash_at, created_at, updated_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanProject scans a row selected with projectColumns
+func scanProject(row rowScanner) (*models.Project, error) {
+	p := &models.Project{}
+	var envVars, containerIDs, resourceLimits, mounts, backupPolicy, release, logRetention, previews, pathFilter, composeConfig string
+	var useSubdomain, autoDeploy int
+	var previewExpiresAt, lastCrashAt sql.NullTime
+
+	err := row.Scan(
+		&p.ID, &p.Name, &p.GitURL, &p.Branch, &p.TagRule, &p.DeployedTag, &p.PinnedCommit, &p.CommitsAhead, &p.DeployType, &p.Image, &p.Domain,
+		&useSubdomain, &p.Port, &envVars, &autoDeploy, &p.LastCommit,
+		&p.Status, &p.StatusMsg, &containerIDs, &resourceLimits, &mounts,
+		&backupPolicy, &release, &logRetention, &previews, &pathFilter, &composeConfig, &p.PreviewOf, &previewExpiresAt,
+		&p.LastExitCode, &p.OOMKills, &lastCrashAt,
+		&p.CreatedAt, &p.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	p.UseSubdomain = useSubdomain == 1
+	p.AutoDeploy = autoDeploy == 1
+	p.PreviewExpiresAt = previewExpiresAt.Time
+	p.LastCrashAt = lastCrashAt.Time
+	if err := p.ParseEnvVars(envVars); err != nil {
+		return nil, fmt.Errorf("failed to parse env vars: %w", err)
+	}
+	if err := p.ParseContainerIDs(containerIDs); err != nil {
+		return nil, fmt.Errorf("failed to parse container IDs: %w", err)
+	}
+	if err := p.ParseResourceLimits(resourceLimits); err != nil {
+		return nil, fmt.Errorf("failed to parse resource limits: %w", err)
+	}
+	if err := p.ParseMounts(mounts); err != nil {
+		return nil, fmt.Errorf("failed to parse mounts: %w", err)
+	}
+	if err := p.ParseBackupPolicy(backupPolicy); err != nil {
+		return nil, fmt.Errorf("failed to parse backup policy: %w", err)
+	}
+	if err := p.ParseRelease(release); err != nil {
+		return nil, fmt.Errorf("failed to parse release config: %w", err)
+	}
+	if err := p.ParseLogRetention(logRetention); err != nil {
+		return nil, fmt.Errorf("failed to parse log retention: %w", err)
+	}
+	if err := p.ParsePreviews(previews); err != nil {
+		return nil, fmt.Errorf("failed to parse preview config: %w", err)
+	}
+	if err := p.ParsePathFilter(pathFilter); err != nil {
+		return nil, fmt.Errorf("failed to parse path filter: %w", err)
+	}
+	if err := p.ParseCompose(composeConfig); err != nil {
+		return nil, fmt.Errorf("failed to parse compose config: %w", err)
+	}
+
+	return p, nil
+}
+
 // ProjectRepository handles project database operations
 type ProjectRepository struct {
-	db *DB
+	db  *DB
+	bus *events.Bus
 }
 
-// NewProjectRepository creates a new project repository
-func NewProjectRepository(db *DB) *ProjectRepository {
-	return &ProjectRepository{db: db}
+// NewProjectRepository creates a new project repository. Status updates are
+// published to bus, which may be nil.
+func NewProjectRepository(db *DB, bus *events.Bus) *ProjectRepository {
+	return &ProjectRepository{db: db, bus: bus}
 }
 
 // Create creates a new project
@@ -26,14 +99,18 @@ func (r *ProjectRepository) Create(p *models.Project) error {
 
 	_, err := r.db.Exec(`
 		INSERT INTO projects (
-			id, name, git_url, branch, deploy_type, image, domain, use_subdomain,
+			id, name, git_url, branch, tag_rule, deployed_tag, pinned_commit, deploy_type, image, domain, use_subdomain,
 			port, env_vars, auto_deploy, last_commit, status, status_msg, container_ids,
-			created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			resource_limits, mounts, backup_policy, release_config, log_retention,
+			preview_config, path_filter, compose_config, preview_of, preview_expires_at, created_at,
+			updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
-		p.ID, p.Name, p.GitURL, p.Branch, p.DeployType, p.Image, p.Domain,
+		p.ID, p.Name, p.GitURL, p.Branch, p.TagRule, p.DeployedTag, p.PinnedCommit, p.DeployType, p.Image, p.Domain,
 		p.UseSubdomain, p.Port, p.EnvVarsJSON(), p.AutoDeploy, p.LastCommit,
-		p.Status, p.StatusMsg, p.ContainerIDsJSON(), p.CreatedAt, p.UpdatedAt,
+		p.Status, p.StatusMsg, p.ContainerIDsJSON(), p.ResourceLimitsJSON(),
+		p.MountsJSON(), p.BackupPolicyJSON(), p.ReleaseJSON(), p.LogRetentionJSON(),
+		p.PreviewsJSON(), p.PathFilterJSON(), p.ComposeJSON(), p.PreviewOf, p.PreviewExpiresAt, p.CreatedAt, p.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create project: %w", err)
@@ -43,82 +120,37 @@ func (r *ProjectRepository) Create(p *models.Project) error {
 
 // GetByID retrieves a project by ID
 func (r *ProjectRepository) GetByID(id string) (*models.Project, error) {
-	p := &models.Project{}
-	var envVars, containerIDs string
-	var useSubdomain, autoDeploy int
-
-	err := r.db.QueryRow(`
-		SELECT id, name, git_url, branch, deploy_type, image, domain, use_subdomain,
-			port, env_vars, auto_deploy, last_commit, status, status_msg, container_ids,
-			created_at, updated_at
-		FROM projects WHERE id = ?
-	`, id).Scan(
-		&p.ID, &p.Name, &p.GitURL, &p.Branch, &p.DeployType, &p.Image, &p.Domain,
-		&useSubdomain, &p.Port, &envVars, &autoDeploy, &p.LastCommit,
-		&p.Status, &p.StatusMsg, &containerIDs, &p.CreatedAt, &p.UpdatedAt,
-	)
+	p, err := scanProject(r.db.QueryRow(
+		"SELECT "+projectColumns+" FROM projects WHERE id = ?", id,
+	))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get project: %w", err)
 	}
-
-	p.UseSubdomain = useSubdomain == 1
-	p.AutoDeploy = autoDeploy == 1
-	if err := p.ParseEnvVars(envVars); err != nil {
-		return nil, fmt.Errorf("failed to parse env vars: %w", err)
-	}
-	if err := p.ParseContainerIDs(containerIDs); err != nil {
-		return nil, fmt.Errorf("failed to parse container IDs: %w", err)
-	}
-
 	return p, nil
 }
 
 // GetByName retrieves a project by name
 func (r *ProjectRepository) GetByName(name string) (*models.Project, error) {
-	p := &models.Project{}
-	var envVars, containerIDs string
-	var useSubdomain, autoDeploy int
-
-	err := r.db.QueryRow(`
-		SELECT id, name, git_url, branch, deploy_type, image, domain, use_subdomain,
-			port, env_vars, auto_deploy, last_commit, status, status_msg, container_ids,
-			created_at, updated_at
-		FROM projects WHERE name = ?
-	`, name).Scan(
-		&p.ID, &p.Name, &p.GitURL, &p.Branch, &p.DeployType, &p.Image, &p.Domain,
-		&useSubdomain, &p.Port, &envVars, &autoDeploy, &p.LastCommit,
-		&p.Status, &p.StatusMsg, &containerIDs, &p.CreatedAt, &p.UpdatedAt,
-	)
+	p, err := scanProject(r.db.QueryRow(
+		"SELECT "+projectColumns+" FROM projects WHERE name = ?", name,
+	))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get project by name: %w", err)
 	}
-
-	p.UseSubdomain = useSubdomain == 1
-	p.AutoDeploy = autoDeploy == 1
-	if err := p.ParseEnvVars(envVars); err != nil {
-		return nil, fmt.Errorf("failed to parse env vars: %w", err)
-	}
-	if err := p.ParseContainerIDs(containerIDs); err != nil {
-		return nil, fmt.Errorf("failed to parse container IDs: %w", err)
-	}
-
 	return p, nil
 }
 
 // List retrieves all projects
 func (r *ProjectRepository) List() ([]*models.Project, error) {
-	rows, err := r.db.Query(`
-		SELECT id, name, git_url, branch, deploy_type, image, domain, use_subdomain,
-			port, env_vars, auto_deploy, last_commit, status, status_msg, container_ids,
-			created_at, updated_at
-		FROM projects ORDER BY created_at DESC
-	`)
+	rows, err := r.db.Query(
+		"SELECT " + projectColumns + " FROM projects ORDER BY created_at DESC",
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list projects: %w", err)
 	}
@@ -126,28 +158,10 @@ func (r *ProjectRepository) List() ([]*models.Project, error) {
 
 	var projects []*models.Project
 	for rows.Next() {
-		p := &models.Project{}
-		var envVars, containerIDs string
-		var useSubdomain, autoDeploy int
-
-		err := rows.Scan(
-			&p.ID, &p.Name, &p.GitURL, &p.Branch, &p.DeployType, &p.Image, &p.Domain,
-			&useSubdomain, &p.Port, &envVars, &autoDeploy, &p.LastCommit,
-			&p.Status, &p.StatusMsg, &containerIDs, &p.CreatedAt, &p.UpdatedAt,
-		)
+		p, err := scanProject(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan project: %w", err)
 		}
-
-		p.UseSubdomain = useSubdomain == 1
-		p.AutoDeploy = autoDeploy == 1
-		if err := p.ParseEnvVars(envVars); err != nil {
-			return nil, fmt.Errorf("failed to parse env vars: %w", err)
-		}
-		if err := p.ParseContainerIDs(containerIDs); err != nil {
-			return nil, fmt.Errorf("failed to parse container IDs: %w", err)
-		}
-
 		projects = append(projects, p)
 	}
 
@@ -156,12 +170,9 @@ func (r *ProjectRepository) List() ([]*models.Project, error) {
 
 // ListAutoDeployEnabled retrieves all projects with auto-deploy enabled
 func (r *ProjectRepository) ListAutoDeployEnabled() ([]*models.Project, error) {
-	rows, err := r.db.Query(`
-		SELECT id, name, git_url, branch, deploy_type, image, domain, use_subdomain,
-			port, env_vars, auto_deploy, last_commit, status, status_msg, container_ids,
-			created_at, updated_at
-		FROM projects WHERE auto_deploy = 1 ORDER BY created_at DESC
-	`)
+	rows, err := r.db.Query(
+		"SELECT " + projectColumns + " FROM projects WHERE auto_deploy = 1 ORDER BY created_at DESC",
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list auto-deploy projects: %w", err)
 	}
@@ -169,28 +180,32 @@ func (r *ProjectRepository) ListAutoDeployEnabled() ([]*models.Project, error) {
 
 	var projects []*models.Project
 	for rows.Next() {
-		p := &models.Project{}
-		var envVars, containerIDs string
-		var useSubdomain, autoDeploy int
-
-		err := rows.Scan(
-			&p.ID, &p.Name, &p.GitURL, &p.Branch, &p.DeployType, &p.Image, &p.Domain,
-			&useSubdomain, &p.Port, &envVars, &autoDeploy, &p.LastCommit,
-			&p.Status, &p.StatusMsg, &containerIDs, &p.CreatedAt, &p.UpdatedAt,
-		)
+		p, err := scanProject(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan project: %w", err)
 		}
+		projects = append(projects, p)
+	}
 
-		p.UseSubdomain = useSubdomain == 1
-		p.AutoDeploy = autoDeploy == 1
-		if err := p.ParseEnvVars(envVars); err != nil {
-			return nil, fmt.Errorf("failed to parse env vars: %w", err)
-		}
-		if err := p.ParseContainerIDs(containerIDs); err != nil {
-			return nil, fmt.Errorf("failed to parse container IDs: %w", err)
-		}
+	return projects, nil
+}
+
+// ListPreviews retrieves the branch previews of a project
+func (r *ProjectRepository) ListPreviews(projectID string) ([]*models.Project, error) {
+	rows, err := r.db.Query(
+		"SELECT "+projectColumns+" FROM projects WHERE preview_of = ? ORDER BY created_at DESC", projectID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list previews: %w", err)
+	}
+	defer rows.Close()
 
+	var projects []*models.Project
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan project: %w", err)
+		}
 		projects = append(projects, p)
 	}
 
@@ -203,14 +218,20 @@ func (r *ProjectRepository) Update(p *models.Project) error {
 
 	result, err := r.db.Exec(`
 		UPDATE projects SET
-			name = ?, git_url = ?, branch = ?, deploy_type = ?, image = ?,
+			name = ?, git_url = ?, branch = ?, tag_rule = ?, deployed_tag = ?, pinned_commit = ?, deploy_type = ?, image = ?,
 			domain = ?, use_subdomain = ?, port = ?, env_vars = ?, auto_deploy = ?,
-			last_commit = ?, status = ?, status_msg = ?, container_ids = ?, updated_at = ?
+			last_commit = ?, status = ?, status_msg = ?, container_ids = ?,
+			resource_limits = ?, mounts = ?, backup_policy = ?, release_config = ?,
+			log_retention = ?, preview_config = ?, path_filter = ?, compose_config = ?, preview_of = ?, preview_expires_at = ?,
+			updated_at = ?
 		WHERE id = ?
 	`,
-		p.Name, p.GitURL, p.Branch, p.DeployType, p.Image, p.Domain,
+		p.Name, p.GitURL, p.Branch, p.TagRule, p.DeployedTag, p.PinnedCommit, p.DeployType, p.Image, p.Domain,
 		p.UseSubdomain, p.Port, p.EnvVarsJSON(), p.AutoDeploy,
-		p.LastCommit, p.Status, p.StatusMsg, p.ContainerIDsJSON(), p.UpdatedAt, p.ID,
+		p.LastCommit, p.Status, p.StatusMsg, p.ContainerIDsJSON(),
+		p.ResourceLimitsJSON(), p.MountsJSON(), p.BackupPolicyJSON(), p.ReleaseJSON(),
+		p.LogRetentionJSON(), p.PreviewsJSON(), p.PathFilterJSON(), p.ComposeJSON(), p.PreviewOf, p.PreviewExpiresAt,
+		p.UpdatedAt, p.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update project: %w", err)
@@ -235,6 +256,13 @@ func (r *ProjectRepository) UpdateStatus(id string, status models.ProjectStatus,
 	if err != nil {
 		return fmt.Errorf("failed to update project status: %w", err)
 	}
+
+	r.bus.Publish(events.Event{
+		Type:      events.TypeStatus,
+		ProjectID: id,
+		Status:    status,
+		Message:   statusMsg,
+	})
 	return nil
 }
 
@@ -261,6 +289,55 @@ func (r *ProjectRepository) UpdateLastCommit(id string, commit string) error {
 	return nil
 }
 
+// UpdateDeployedTag updates the tag a project has checked out
+func (r *ProjectRepository) UpdateDeployedTag(id string, tag string) error {
+	_, err := r.db.Exec(`
+		UPDATE projects SET deployed_tag = ?, updated_at = ? WHERE id = ?
+	`, tag, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update deployed tag: %w", err)
+	}
+	return nil
+}
+
+// UpdateCommitsAhead updates how many commits a pinned project's branch has
+// on top of its pin, -1 if unknown
+func (r *ProjectRepository) UpdateCommitsAhead(id string, ahead int) error {
+	_, err := r.db.Exec(`
+		UPDATE projects SET commits_ahead = ? WHERE id = ?
+	`, ahead, id)
+	if err != nil {
+		return fmt.Errorf("failed to update commits ahead: %w", err)
+	}
+	return nil
+}
+
+// RecordCrash records an unexpected exit of one of a project's containers
+func (r *ProjectRepository) RecordCrash(id string, exitCode int, oomKilled bool, at time.Time) error {
+	oomKills := 0
+	if oomKilled {
+		oomKills = 1
+	}
+	_, err := r.db.Exec(`
+		UPDATE projects SET last_exit_code = ?, oom_kills = oom_kills + ?, last_crash_at = ? WHERE id = ?
+	`, exitCode, oomKills, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to record crash: %w", err)
+	}
+	return nil
+}
+
+// ResetCrashes clears the crash record of a project
+func (r *ProjectRepository) ResetCrashes(id string) error {
+	_, err := r.db.Exec(`
+		UPDATE projects SET last_exit_code = 0, oom_kills = 0, last_crash_at = NULL WHERE id = ?
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to reset crashes: %w", err)
+	}
+	return nil
+}
+
 // Delete deletes a project
 func (r *ProjectRepository) Delete(id string) error {
 	result, err := r.db.Exec("DELETE FROM projects WHERE id = ?", id)
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// shellColumns is the column list used by every shell session SELECT, in the
+// order expected by scanShellSession
+const shellColumns = `id, project_id, username, remote_addr, service, container_id, started_at, ended_at`
+
+// scanShellSession scans a row selected with shellColumns
+func scanShellSession(row rowScanner) (*models.ShellSession, error) {
+	s := &models.ShellSession{}
+	var endedAt sql.NullTime
+	err := row.Scan(
+		&s.ID, &s.ProjectID, &s.Username, &s.RemoteAddr, &s.Service, &s.ContainerID,
+		&s.StartedAt, &endedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.EndedAt = endedAt.Time
+	return s, nil
+}
+
+// ShellRepository handles the audit records of shells opened into project
+// containers
+type ShellRepository struct {
+	db *DB
+}
+
+// NewShellRepository creates a new shell session repository
+func NewShellRepository(db *DB) *ShellRepository {
+	return &ShellRepository{db: db}
+}
+
+// Create records an opened shell
+func (r *ShellRepository) Create(s *models.ShellSession) error {
+	_, err := r.db.Exec(`
+		INSERT INTO shell_sessions (id, project_id, username, remote_addr, service, container_id, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.ProjectID, s.Username, s.RemoteAddr, s.Service, s.ContainerID, s.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create shell session: %w", err)
+	}
+	return nil
+}
+
+// End records when a shell was closed and drops the project's sessions
+// beyond models.ShellSessionHistory
+func (r *ShellRepository) End(s *models.ShellSession) error {
+	if _, err := r.db.Exec(
+		"UPDATE shell_sessions SET ended_at = ? WHERE id = ?", s.EndedAt, s.ID,
+	); err != nil {
+		return fmt.Errorf("failed to end shell session: %w", err)
+	}
+
+	_, err := r.db.Exec(`
+		DELETE FROM shell_sessions WHERE project_id = ? AND id NOT IN (
+			SELECT id FROM shell_sessions WHERE project_id = ? ORDER BY started_at DESC LIMIT ?
+		)
+	`, s.ProjectID, s.ProjectID, models.ShellSessionHistory)
+	if err != nil {
+		return fmt.Errorf("failed to prune shell sessions: %w", err)
+	}
+	return nil
+}
+
+// EndOpenSessions marks sessions left open by a previous process as ended
+func (r *ShellRepository) EndOpenSessions() error {
+	if _, err := r.db.Exec(
+		"UPDATE shell_sessions SET ended_at = ? WHERE ended_at IS NULL", time.Now(),
+	); err != nil {
+		return fmt.Errorf("failed to end open shell sessions: %w", err)
+	}
+	return nil
+}
+
+// ListByProject retrieves a project's most recent shell sessions, newest
+// first
+func (r *ShellRepository) ListByProject(projectID string, limit int) ([]*models.ShellSession, error) {
+	rows, err := r.db.Query(
+		"SELECT "+shellColumns+" FROM shell_sessions WHERE project_id = ? ORDER BY started_at DESC LIMIT ?",
+		projectID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list shell sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.ShellSession
+	for rows.Next() {
+		s, err := scanShellSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shell session: %w", err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, nil
+}
+
+// DeleteByProject deletes all shell sessions of a project
+func (r *ShellRepository) DeleteByProject(projectID string) error {
+	if _, err := r.db.Exec("DELETE FROM shell_sessions WHERE project_id = ?", projectID); err != nil {
+		return fmt.Errorf("failed to delete shell sessions: %w", err)
+	}
+	return nil
+}
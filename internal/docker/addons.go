@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// RunAddon (re)creates and starts an add-on's container on the SlimDeploy
+// network with its data on a persistent volume
+func (c *Client) RunAddon(ctx context.Context, addon *models.Addon) (string, error) {
+	containerName := addon.Host()
+
+	if err := c.PullImage(ctx, addon.Image()); err != nil {
+		return "", err
+	}
+
+	// Stop and remove existing container if any
+	if err := c.RemoveContainer(ctx, containerName); err != nil {
+		return "", err
+	}
+
+	err := c.ensureVolume(ctx, addon.VolumeName(), map[string]string{
+		LabelPrefix + ".managed": "true",
+		LabelPrefix + ".addon":   addon.ID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.cli.ContainerCreate(ctx,
+		&container.Config{
+			Image: addon.Image(),
+			Env:   addon.ContainerEnv(),
+			Cmd:   addon.ContainerCmd(),
+			Labels: map[string]string{
+				LabelPrefix + ".managed": "true",
+				LabelPrefix + ".addon":   addon.ID,
+			},
+		},
+		&container.HostConfig{
+			RestartPolicy: container.RestartPolicy{
+				Name: "unless-stopped",
+			},
+			Mounts: []mount.Mount{{
+				Type:   mount.TypeVolume,
+				Source: addon.VolumeName(),
+				Target: addon.Spec().DataPath,
+			}},
+		},
+		&network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				NetworkName: {},
+			},
+		},
+		nil,
+		containerName,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create add-on container: %w", err)
+	}
+
+	if err := c.cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return "", fmt.Errorf("failed to start add-on container: %w", err)
+	}
+
+	return resp.ID, nil
+}
+
+// RemoveAddon removes an add-on's container and, if removeVolume is set, its
+// data volume
+func (c *Client) RemoveAddon(ctx context.Context, addon *models.Addon, removeVolume bool) error {
+	if err := c.RemoveContainer(ctx, addon.Host()); err != nil {
+		return err
+	}
+	if !removeVolume {
+		return nil
+	}
+
+	if err := c.cli.VolumeRemove(ctx, addon.VolumeName(), false); err != nil {
+		if client.IsErrNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to remove volume %s: %w", addon.VolumeName(), err)
+	}
+	return nil
+}
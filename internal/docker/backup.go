@@ -0,0 +1,167 @@
+package docker
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// HelperImage is the image used for short-lived volume helper containers
+	HelperImage = "alpine:3.20"
+
+	// archivePath is where helper containers read and write volume archives
+	archivePath = "/backup.tar.gz"
+	// volumePath is where helper containers mount the volume
+	volumePath = "/volume"
+)
+
+// ArchiveVolume snapshots a project volume into a tar.gz archive using a
+// helper container and passes the archive and its size to fn. The helper
+// container is removed when fn returns.
+func (c *Client) ArchiveVolume(ctx context.Context, projectID, volume string, fn func(archive io.Reader, size int64) error) error {
+	volumeName := VolumeName(projectID, volume)
+	if err := c.ensureVolume(ctx, volumeName, projectVolumeLabels(projectID, volume)); err != nil {
+		return err
+	}
+
+	id, err := c.createHelper(ctx, volumeName, true,
+		[]string{"tar", "czf", archivePath, "-C", volumePath, "."},
+	)
+	if err != nil {
+		return err
+	}
+	defer c.removeHelper(id)
+
+	if err := c.runHelper(ctx, id); err != nil {
+		return fmt.Errorf("failed to archive volume %s: %w", volumeName, err)
+	}
+
+	// The archive is returned wrapped in a tar stream
+	reader, _, err := c.cli.CopyFromContainer(ctx, id, archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to copy archive of volume %s: %w", volumeName, err)
+	}
+	defer reader.Close()
+
+	tr := tar.NewReader(reader)
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("failed to read archive of volume %s: %w", volumeName, err)
+	}
+
+	return fn(tr, header.Size)
+}
+
+// RestoreVolume replaces the contents of a project volume with a tar.gz
+// archive created by ArchiveVolume, creating the volume if it was removed.
+// Containers using the volume should be stopped first.
+func (c *Client) RestoreVolume(ctx context.Context, projectID, volume string, archive io.Reader, size int64) error {
+	volumeName := VolumeName(projectID, volume)
+	if err := c.ensureVolume(ctx, volumeName, projectVolumeLabels(projectID, volume)); err != nil {
+		return err
+	}
+
+	id, err := c.createHelper(ctx, volumeName, false,
+		[]string{"sh", "-c", fmt.Sprintf("find %s -mindepth 1 -delete && tar xzf %s -C %s", volumePath, archivePath, volumePath)},
+	)
+	if err != nil {
+		return err
+	}
+	defer c.removeHelper(id)
+
+	// Copy the archive into the helper as a single-file tar stream
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := tw.WriteHeader(&tar.Header{Name: archivePath[1:], Mode: 0644, Size: size})
+		if err == nil {
+			_, err = io.CopyN(tw, archive, size)
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	err = c.cli.CopyToContainer(ctx, id, "/", pr, types.CopyToContainerOptions{})
+	pr.Close()
+	if err != nil {
+		return fmt.Errorf("failed to copy archive for volume %s: %w", volumeName, err)
+	}
+
+	if err := c.runHelper(ctx, id); err != nil {
+		return fmt.Errorf("failed to restore volume %s: %w", volumeName, err)
+	}
+	return nil
+}
+
+// createHelper creates a helper container with a volume mounted at volumePath
+func (c *Client) createHelper(ctx context.Context, volumeName string, readOnly bool, cmd []string) (string, error) {
+	if err := c.ensureImage(ctx, HelperImage); err != nil {
+		return "", err
+	}
+
+	resp, err := c.cli.ContainerCreate(ctx,
+		&container.Config{
+			Image: HelperImage,
+			Cmd:   cmd,
+			Labels: map[string]string{
+				LabelPrefix + ".helper": "volume",
+			},
+		},
+		&container.HostConfig{
+			Mounts: []mount.Mount{{
+				Type:     mount.TypeVolume,
+				Source:   volumeName,
+				Target:   volumePath,
+				ReadOnly: readOnly,
+			}},
+		},
+		nil, nil, "",
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create helper container: %w", err)
+	}
+	return resp.ID, nil
+}
+
+// runHelper starts a helper container and waits for it to exit successfully
+func (c *Client) runHelper(ctx context.Context, id string) error {
+	if err := c.cli.ContainerStart(ctx, id, types.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("failed to start helper container: %w", err)
+	}
+
+	statusCh, errCh := c.cli.ContainerWait(ctx, id, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to wait for helper container: %w", err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("helper container exited with code %d", status.StatusCode)
+		}
+	}
+	return nil
+}
+
+// removeHelper removes a helper container. It uses a fresh context so the
+// container is cleaned up even if the operation was cancelled.
+func (c *Client) removeHelper(id string) {
+	c.cli.ContainerRemove(context.Background(), id, types.ContainerRemoveOptions{Force: true})
+}
+
+// ensureImage pulls an image unless it is already present
+func (c *Client) ensureImage(ctx context.Context, imageName string) error {
+	if _, _, err := c.cli.ImageInspectWithRaw(ctx, imageName); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+	}
+	return c.PullImage(ctx, imageName)
+}
@@ -24,20 +24,23 @@ const (
 
 // Client wraps the Docker client
 type Client struct {
-	cli        *client.Client
-	baseDomain string
+	cli              *client.Client
+	baseDomain       string
+	allowedBindPaths []string
 }
 
-// NewClient creates a new Docker client
-func NewClient(baseDomain string) (*Client, error) {
+// NewClient creates a new Docker client. Bind mounts are only allowed inside
+// allowedBindPaths.
+func NewClient(baseDomain string, allowedBindPaths []string) (*Client, error) {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Docker client: %w", err)
 	}
 
 	return &Client{
-		cli:        cli,
-		baseDomain: baseDomain,
+		cli:              cli,
+		baseDomain:       baseDomain,
+		allowedBindPaths: allowedBindPaths,
 	}, nil
 }
 
@@ -119,6 +122,12 @@ func (c *Client) RunContainer(ctx context.Context, project *models.Project) (str
 		env = append(env, fmt.Sprintf("%s=%s", k, v))
 	}
 
+	// Create volumes and resolve mounts
+	mounts, err := c.projectMounts(ctx, project)
+	if err != nil {
+		return "", err
+	}
+
 	// Create container
 	resp, err := c.cli.ContainerCreate(ctx,
 		&container.Config{
@@ -130,6 +139,8 @@ func (c *Client) RunContainer(ctx context.Context, project *models.Project) (str
 			RestartPolicy: container.RestartPolicy{
 				Name: "unless-stopped",
 			},
+			Resources: hostResources(project.Resources),
+			Mounts:    mounts,
 		},
 		&network.NetworkingConfig{
 			EndpointsConfig: map[string]*network.EndpointSettings{
@@ -190,19 +201,37 @@ func (c *Client) GetContainerStatus(ctx context.Context, containerID string) (st
 	return info.State.Status, nil
 }
 
-// GetContainerLogs gets the logs of a container
-func (c *Client) GetContainerLogs(ctx context.Context, containerID string, tail int, follow bool) (io.ReadCloser, error) {
+// GetContainerLogs gets the logs of a container as stdout and stderr
+// entries. opts.Services is ignored. The reader must be closed.
+func (c *Client) GetContainerLogs(ctx context.Context, containerID string, opts LogOptions) (*LogReader, error) {
+	info, err := c.cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
 	options := types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
-		Follow:     follow,
+		Follow:     opts.Follow,
 		Timestamps: true,
 	}
-	if tail > 0 {
-		options.Tail = fmt.Sprintf("%d", tail)
+	if opts.Tail > 0 {
+		options.Tail = fmt.Sprintf("%d", opts.Tail)
+	}
+	if !opts.Since.IsZero() {
+		options.Since = opts.Since.Format(time.RFC3339Nano)
+	}
+	if !opts.Until.IsZero() {
+		options.Until = opts.Until.Format(time.RFC3339Nano)
+	}
+
+	reader, err := c.cli.ContainerLogs(ctx, containerID, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container logs: %w", err)
 	}
 
-	return c.cli.ContainerLogs(ctx, containerID, options)
+	// TTY containers write a raw stream without multiplexing headers
+	return newLogReader(reader, info.Config != nil && info.Config.Tty), nil
 }
 
 // ListProjectContainers lists all containers for a project
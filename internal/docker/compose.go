@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/mhenrichsen/slimdeploy/internal/models"
@@ -63,9 +64,27 @@ type ComposeService struct {
 	Extra map[string]interface{} `yaml:",inline"`
 }
 
-// GetProjectDir returns the directory for a project's deployment files
-func (cm *ComposeManager) GetProjectDir(projectName string) string {
-	return filepath.Join(cm.deploymentsDir, projectName)
+// modifiedComposeFile is the name of the compose file SlimDeploy writes,
+// with its labels and networks injected, next to the project's own. It is
+// the file of the last successful deploy.
+const modifiedComposeFile = ".slimdeploy-compose.yml"
+
+// GetProjectDir returns the directory docker compose runs in for a project:
+// its checkout, or the configured subdirectory of it
+func (cm *ComposeManager) GetProjectDir(project *models.Project) string {
+	return filepath.Join(cm.deploymentsDir, project.Name, filepath.FromSlash(project.Compose.Dir))
+}
+
+// composeProjectDir returns the directory relative paths in a project's
+// compose files resolve against: that of its first compose file, as docker
+// compose itself would use. SlimDeploy's generated file lives in the
+// project directory, so it is passed explicitly.
+func (cm *ComposeManager) composeProjectDir(project *models.Project) string {
+	projectDir := cm.GetProjectDir(project)
+	if project.Compose.File == "" {
+		return projectDir
+	}
+	return filepath.Dir(filepath.Join(projectDir, filepath.FromSlash(project.Compose.File)))
 }
 
 // FindComposeFile finds the docker-compose file in a project directory
@@ -87,6 +106,91 @@ func (cm *ComposeManager) FindComposeFile(projectDir string) (string, error) {
 	return "", fmt.Errorf("no docker-compose file found in %s", projectDir)
 }
 
+// composeFiles returns the paths of a project's compose file and its
+// override files, in the order docker compose merges them
+func (cm *ComposeManager) composeFiles(project *models.Project) ([]string, error) {
+	projectDir := cm.GetProjectDir(project)
+
+	var files []string
+	if project.Compose.File != "" {
+		path := filepath.Join(projectDir, filepath.FromSlash(project.Compose.File))
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("compose file %s not found in %s", project.Compose.File, projectDir)
+		}
+		files = append(files, path)
+	} else {
+		path, err := cm.FindComposeFile(projectDir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, path)
+	}
+
+	for _, override := range project.Compose.Overrides {
+		path := filepath.Join(projectDir, filepath.FromSlash(override))
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("compose override %s not found in %s", override, projectDir)
+		}
+		files = append(files, path)
+	}
+
+	return files, nil
+}
+
+// composeCommand returns a docker compose command for a project's compose
+// files with its profiles enabled, run in its project directory with
+// relative paths resolved from its compose file's directory
+func (cm *ComposeManager) composeCommand(ctx context.Context, project *models.Project, files []string, args ...string) *exec.Cmd {
+	cmdArgs := []string{"compose"}
+	for _, file := range files {
+		cmdArgs = append(cmdArgs, "-f", file)
+	}
+	cmdArgs = append(cmdArgs, "--project-directory", cm.composeProjectDir(project))
+	cmdArgs = append(cmdArgs, "-p", fmt.Sprintf("slimdeploy-%s", project.Name))
+	for _, profile := range project.Compose.Profiles {
+		cmdArgs = append(cmdArgs, "--profile", profile)
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", append(cmdArgs, args...)...)
+	cmd.Dir = cm.GetProjectDir(project)
+	return cmd
+}
+
+// deployedFiles returns the compose files of a project's running
+// deployment: the modified file if it was deployed, or its own files
+func (cm *ComposeManager) deployedFiles(project *models.Project) ([]string, error) {
+	modifiedPath := filepath.Join(cm.GetProjectDir(project), modifiedComposeFile)
+	if _, err := os.Stat(modifiedPath); err == nil {
+		return []string{modifiedPath}, nil
+	}
+	return cm.composeFiles(project)
+}
+
+// loadCompose parses a project's compose file, merged with its override
+// files by docker compose if it has any. Variables are left for docker
+// compose to interpolate when it runs the result.
+func (cm *ComposeManager) loadCompose(ctx context.Context, project *models.Project) (*ComposeFile, error) {
+	files, err := cm.composeFiles(project)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 1 {
+		return cm.ParseComposeFile(files[0])
+	}
+
+	cmd := cm.composeCommand(ctx, project, files, "config", "--no-interpolate")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("docker compose config failed: %w\nstderr: %s", err, stderr.String())
+	}
+
+	return parseCompose(stdout.Bytes())
+}
+
 // ParseComposeFile parses a docker-compose.yml file
 func (cm *ComposeManager) ParseComposeFile(path string) (*ComposeFile, error) {
 	data, err := os.ReadFile(path)
@@ -94,6 +198,11 @@ func (cm *ComposeManager) ParseComposeFile(path string) (*ComposeFile, error) {
 		return nil, fmt.Errorf("failed to read compose file: %w", err)
 	}
 
+	return parseCompose(data)
+}
+
+// parseCompose parses the contents of a compose file
+func parseCompose(data []byte) (*ComposeFile, error) {
 	var compose ComposeFile
 	if err := yaml.Unmarshal(data, &compose); err != nil {
 		return nil, fmt.Errorf("failed to parse compose file: %w", err)
@@ -103,7 +212,12 @@ func (cm *ComposeManager) ParseComposeFile(path string) (*ComposeFile, error) {
 }
 
 // InjectLabels injects Traefik and SlimDeploy labels into a compose file
-func (cm *ComposeManager) InjectLabels(project *models.Project, compose *ComposeFile, mainService string) *ComposeFile {
+func (cm *ComposeManager) InjectLabels(project *models.Project, compose *ComposeFile) *ComposeFile {
+	routes := make(map[string]models.RoutedService)
+	for _, service := range cm.routedServices(project, compose) {
+		routes[service.Name] = service
+	}
+
 	// Make a copy to avoid modifying the original
 	modified := *compose
 	modified.Services = make(map[string]ComposeService)
@@ -138,10 +252,12 @@ func (cm *ComposeManager) InjectLabels(project *models.Project, compose *Compose
 		// Convert labels to map format for easier manipulation
 		labels := cm.getLabelsAsMap(service.Labels)
 
-		// Remove conflicting Traefik labels (keep only traefik.enable)
+		// Remove conflicting Traefik labels (keep only traefik.enable),
+		// unless the project keeps its own
+		merge := project.Compose.MergeTraefikLabels
 		labelsToRemove := []string{}
 		for k := range labels {
-			if strings.HasPrefix(k, "traefik.") && k != "traefik.enable" {
+			if !merge && strings.HasPrefix(k, "traefik.") && k != "traefik.enable" {
 				labelsToRemove = append(labelsToRemove, k)
 			}
 		}
@@ -153,15 +269,29 @@ func (cm *ComposeManager) InjectLabels(project *models.Project, compose *Compose
 		labels[LabelPrefix+".managed"] = "true"
 		labels[LabelPrefix+".project"] = project.ID
 
-		// Add Traefik labels only to the main service
-		if name == mainService || (mainService == "" && name == cm.findMainService(compose)) {
-			traefikLabels := GenerateTraefikLabelsForCompose(project, cm.baseDomain, name)
+		// Add Traefik labels only to routed services
+		if route, ok := routes[name]; ok {
+			traefikLabels := GenerateTraefikLabelsForCompose(project, cm.baseDomain, route)
 			for k, v := range traefikLabels {
+				// The compose file's own labels win when merging
+				if _, ok := labels[k]; ok && merge {
+					continue
+				}
 				labels[k] = v
 			}
 		}
 
 		service.Labels = labels
+
+		// Container names are global, so a preview's would clash with the
+		// project's own containers
+		if project.IsPreview() && service.ContainerName != "" {
+			service.ContainerName = project.Name + "-" + service.ContainerName
+		}
+
+		// Apply project resource limits to every service
+		injectResources(&service, project.Resources)
+
 		modified.Services[name] = service
 	}
 
@@ -180,8 +310,14 @@ func (cm *ComposeManager) getLabelsAsMap(labels interface{}) map[string]string {
 		return l
 	case map[string]interface{}:
 		for k, v := range l {
-			if s, ok := v.(string); ok {
-				result[k] = s
+			// YAML turns values like 8080 and true into numbers and bools
+			switch v := v.(type) {
+			case string:
+				result[k] = v
+			case nil:
+				result[k] = ""
+			default:
+				result[k] = fmt.Sprint(v)
 			}
 		}
 	case []interface{}:
@@ -234,14 +370,51 @@ func (cm *ComposeManager) findMainService(compose *ComposeFile) string {
 		}
 	}
 
-	// Return the first service
+	// Return the first service by name, so the choice is stable
+	names := make([]string, 0, len(compose.Services))
 	for name := range compose.Services {
-		return name
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		return names[0]
 	}
 
 	return ""
 }
 
+// routedServices returns the services Traefik routes to: the project's
+// declared services, or the guessed main service on the project's domain
+func (cm *ComposeManager) routedServices(project *models.Project, compose *ComposeFile) []models.RoutedService {
+	if len(project.Compose.Services) > 0 {
+		return project.Compose.Services
+	}
+	if name := cm.findMainService(compose); name != "" {
+		return []models.RoutedService{{Name: name}}
+	}
+	return nil
+}
+
+// serviceLabels returns the labels of every service of a compose file,
+// keyed by service name
+func (cm *ComposeManager) serviceLabels(compose *ComposeFile) map[string]map[string]string {
+	labels := make(map[string]map[string]string, len(compose.Services))
+	for name, service := range compose.Services {
+		labels[name] = cm.getLabelsAsMap(service.Labels)
+	}
+	return labels
+}
+
+// RenderLabels returns the labels each service of a project's compose file
+// is deployed with, keyed by service name
+func (cm *ComposeManager) RenderLabels(ctx context.Context, project *models.Project) (map[string]map[string]string, error) {
+	compose, err := cm.generate(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	return cm.serviceLabels(compose), nil
+}
+
 // WriteComposeFile writes a compose file to disk
 func (cm *ComposeManager) WriteComposeFile(path string, compose *ComposeFile) error {
 	data, err := yaml.Marshal(compose)
@@ -256,160 +429,313 @@ func (cm *ComposeManager) WriteComposeFile(path string, compose *ComposeFile) er
 	return nil
 }
 
-// Up runs docker compose up for a project
-func (cm *ComposeManager) Up(ctx context.Context, project *models.Project) error {
-	projectDir := cm.GetProjectDir(project.Name)
+// generate returns a project's compose file, merged with its overrides,
+// with SlimDeploy's labels and networks injected
+func (cm *ComposeManager) generate(ctx context.Context, project *models.Project) (*ComposeFile, error) {
+	compose, err := cm.loadCompose(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	return cm.InjectLabels(project, compose), nil
+}
+
+// check reports why a generated compose file can't be deployed: a routed
+// service missing from it, or conflicting Traefik labels when they are merged
+func (cm *ComposeManager) check(project *models.Project, compose *ComposeFile) error {
+	for _, service := range project.Compose.Services {
+		if _, ok := compose.Services[service.Name]; !ok {
+			return fmt.Errorf("routed service %s is not in the compose file", service.Name)
+		}
+	}
 
-	// Find compose file
-	composePath, err := cm.FindComposeFile(projectDir)
+	if project.Compose.MergeTraefikLabels {
+		if err := ValidateTraefikLabels(cm.serviceLabels(compose)); err != nil {
+			return fmt.Errorf("invalid Traefik labels: %w", err)
+		}
+	}
+	return nil
+}
+
+// validate writes a generated compose file to a temporary file in the
+// project directory and checks it with docker compose config. It returns
+// the file's path, which the caller removes, or promotes to the modified
+// compose file once the file is deployed; an invalid file is removed.
+func (cm *ComposeManager) validate(ctx context.Context, project *models.Project, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(cm.GetProjectDir(project), ".slimdeploy-compose-*.yml")
 	if err != nil {
-		return err
+		return "", fmt.Errorf("failed to write compose file: %w", err)
 	}
+	path := tmp.Name()
 
-	// Parse compose file
-	compose, err := cm.ParseComposeFile(composePath)
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(path, 0644)
+	}
 	if err != nil {
-		return err
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write compose file: %w", err)
 	}
 
-	// Inject labels
-	modified := cm.InjectLabels(project, compose, "")
+	cmd := cm.composeCommand(ctx, project, []string{path}, "config", "--quiet")
+	cmd.Env = composeEnv(project)
 
-	// Write modified compose file
-	modifiedPath := filepath.Join(projectDir, ".slimdeploy-compose.yml")
-	if err := cm.WriteComposeFile(modifiedPath, modified); err != nil {
-		return err
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		os.Remove(path)
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("invalid compose file: %s", msg)
+		}
+		return "", fmt.Errorf("docker compose config failed: %w", err)
+	}
+	return path, nil
+}
+
+// prepare writes a project's compose file, merged with its overrides, with
+// SlimDeploy's labels and networks injected and returns its path and the
+// name of the main service. The file is checked with docker compose config
+// first, so an invalid file fails before anything is deployed. It is a
+// temporary file the caller removes; the modified compose file is only
+// replaced by Up once the deploy succeeded.
+func (cm *ComposeManager) prepare(ctx context.Context, project *models.Project) (string, string, error) {
+	modified, err := cm.generate(ctx, project)
+	if err != nil {
+		return "", "", err
+	}
+	if err := cm.check(project, modified); err != nil {
+		return "", "", err
+	}
+
+	data, err := yaml.Marshal(modified)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to marshal compose file: %w", err)
+	}
+	path, err := cm.validate(ctx, project, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	mainService := project.Compose.MainService()
+	if mainService == "" {
+		mainService = cm.findMainService(modified)
+	}
+	return path, mainService, nil
+}
+
+// ComposePreview is the compose file a deploy would run, generated from a
+// project's current checkout, next to the one deployed now
+type ComposePreview struct {
+	// Generated is the compose file a deploy would write, with variables
+	// left for docker compose to interpolate
+	Generated string `json:"generated"`
+	// Deployed is the compose file of the last successful deploy, empty if
+	// the project hasn't been deployed with one
+	Deployed string `json:"deployed,omitempty"`
+	// Diff compares Deployed to Generated line by line
+	Diff []DiffLine `json:"diff,omitempty"`
+	// Env is the environment the variables are interpolated from, besides
+	// the server's own
+	Env map[string]string `json:"env"`
+	// Invalid explains why a deploy of Generated would fail
+	Invalid string `json:"invalid,omitempty"`
+}
+
+// Changed reports whether the generated file differs from the deployed one
+func (p *ComposePreview) Changed() bool {
+	for _, line := range p.Diff {
+		if line.Op != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// DryRun generates the compose file a deploy of the project would run and
+// validates it with docker compose config, without writing or starting
+// anything. Problems that would fail the deploy are reported in Invalid;
+// err is only set when no compose file could be generated.
+func (cm *ComposeManager) DryRun(ctx context.Context, project *models.Project) (*ComposePreview, error) {
+	modified, err := cm.generate(ctx, project)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build environment variables
+	data, err := yaml.Marshal(modified)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal compose file: %w", err)
+	}
+
+	preview := &ComposePreview{
+		Generated: string(data),
+		Env:       project.EnvVars,
+	}
+	if err := cm.check(project, modified); err != nil {
+		preview.Invalid = err.Error()
+	} else if path, err := cm.validate(ctx, project, data); err != nil {
+		preview.Invalid = err.Error()
+	} else {
+		os.Remove(path)
+	}
+
+	deployed, err := os.ReadFile(filepath.Join(cm.GetProjectDir(project), modifiedComposeFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read deployed compose file: %w", err)
+	}
+	if err == nil {
+		preview.Deployed = string(deployed)
+		preview.Diff = LineDiff(preview.Deployed, preview.Generated)
+	}
+
+	return preview, nil
+}
+
+// composeEnv returns the environment docker compose runs with, so that the
+// project's variables can be interpolated in the compose file
+func composeEnv(project *models.Project) []string {
 	var envList []string
 	for k, v := range project.EnvVars {
 		envList = append(envList, fmt.Sprintf("%s=%s", k, v))
 	}
+	return append(os.Environ(), envList...)
+}
 
-	// Run docker compose up
-	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", modifiedPath, "-p", fmt.Sprintf("slimdeploy-%s", project.Name), "up", "-d", "--build", "--remove-orphans")
-	cmd.Dir = projectDir
-	cmd.Env = append(os.Environ(), envList...)
+// RunRelease builds a compose project's images and runs a release command
+// with docker compose run in a one-off container of service, or of the main
+// service if service is empty. It returns the command's exit code and
+// output; err is only set when the command could not run to completion.
+func (cm *ComposeManager) RunRelease(ctx context.Context, project *models.Project, service, command string) (exitCode int, output string, err error) {
+	path, mainService, err := cm.prepare(ctx, project)
+	if err != nil {
+		return 0, "", err
+	}
+	defer os.Remove(path)
+	if service == "" {
+		service = mainService
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, project.Release.Timeout())
+	defer cancel()
+
+	files := []string{path}
+
+	// Build first so the command runs in the new image
+	cmd := cm.composeCommand(ctx, project, files, "build")
+	cmd.Env = composeEnv(project)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker compose up failed: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+		return 0, "", fmt.Errorf("docker compose build failed: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
 	}
 
-	return nil
-}
+	// Keep Traefik from routing to the one-off container
+	cmd = cm.composeCommand(ctx, project, files,
+		"run", "--rm", "-T", "--label", "traefik.enable=false", "--entrypoint", "sh", service, "-c", command)
+	cmd.Env = composeEnv(project)
 
-// Down runs docker compose down for a project
-func (cm *ComposeManager) Down(ctx context.Context, project *models.Project) error {
-	projectDir := cm.GetProjectDir(project.Name)
+	out := &tailBuffer{limit: models.JobOutputLimit}
+	cmd.Stdout = out
+	cmd.Stderr = out
 
-	modifiedPath := filepath.Join(projectDir, ".slimdeploy-compose.yml")
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok && ctx.Err() == nil {
+		return exitErr.ExitCode(), string(out.buf), nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return 0, string(out.buf), fmt.Errorf("timed out after %s", project.Release.Timeout())
+	}
+	if err != nil {
+		return 0, string(out.buf), fmt.Errorf("docker compose run failed: %w", err)
+	}
+	return 0, string(out.buf), nil
+}
 
-	// Check if modified compose file exists
-	if _, err := os.Stat(modifiedPath); os.IsNotExist(err) {
-		// Try to find original compose file
-		var composeErr error
-		modifiedPath, composeErr = cm.FindComposeFile(projectDir)
-		if composeErr != nil {
-			return composeErr
-		}
+// Up runs docker compose up for a project. The modified compose file is
+// only replaced once it succeeds, so it always describes the running
+// deployment.
+func (cm *ComposeManager) Up(ctx context.Context, project *models.Project) error {
+	path, _, err := cm.prepare(ctx, project)
+	if err != nil {
+		return err
 	}
+	defer os.Remove(path)
 
-	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", modifiedPath, "-p", fmt.Sprintf("slimdeploy-%s", project.Name), "down", "--remove-orphans")
-	cmd.Dir = projectDir
+	// Run docker compose up
+	cmd := cm.composeCommand(ctx, project, []string{path}, "up", "-d", "--build", "--remove-orphans")
+	cmd.Env = composeEnv(project)
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker compose down failed: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+		return fmt.Errorf("docker compose up failed: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
 	}
 
+	if err := os.Rename(path, filepath.Join(cm.GetProjectDir(project), modifiedComposeFile)); err != nil {
+		return fmt.Errorf("failed to write compose file: %w", err)
+	}
 	return nil
 }
 
-// Restart runs docker compose restart for a project
-func (cm *ComposeManager) Restart(ctx context.Context, project *models.Project) error {
-	projectDir := cm.GetProjectDir(project.Name)
-
-	modifiedPath := filepath.Join(projectDir, ".slimdeploy-compose.yml")
-
-	// Check if modified compose file exists
-	if _, err := os.Stat(modifiedPath); os.IsNotExist(err) {
-		// Need to run Up instead
-		return cm.Up(ctx, project)
+// Down runs docker compose down for a project
+func (cm *ComposeManager) Down(ctx context.Context, project *models.Project) error {
+	// Fall back to the project's own files if it was never deployed
+	files, err := cm.deployedFiles(project)
+	if err != nil {
+		return err
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", modifiedPath, "-p", fmt.Sprintf("slimdeploy-%s", project.Name), "restart")
-	cmd.Dir = projectDir
+	cmd := cm.composeCommand(ctx, project, files, "down", "--remove-orphans")
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker compose restart failed: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+		return fmt.Errorf("docker compose down failed: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
 	}
 
 	return nil
 }
 
-// Logs gets logs from docker compose services
-func (cm *ComposeManager) Logs(ctx context.Context, project *models.Project, follow bool, tail int) (string, error) {
-	projectDir := cm.GetProjectDir(project.Name)
-
-	modifiedPath := filepath.Join(projectDir, ".slimdeploy-compose.yml")
+// Restart runs docker compose restart for a project
+func (cm *ComposeManager) Restart(ctx context.Context, project *models.Project) error {
+	modifiedPath := filepath.Join(cm.GetProjectDir(project), modifiedComposeFile)
 
 	// Check if modified compose file exists
 	if _, err := os.Stat(modifiedPath); os.IsNotExist(err) {
-		var composeErr error
-		modifiedPath, composeErr = cm.FindComposeFile(projectDir)
-		if composeErr != nil {
-			return "", composeErr
-		}
-	}
-
-	args := []string{"compose", "-f", modifiedPath, "-p", fmt.Sprintf("slimdeploy-%s", project.Name), "logs", "--timestamps"}
-	if tail > 0 {
-		args = append(args, "--tail", fmt.Sprintf("%d", tail))
+		// Need to run Up instead
+		return cm.Up(ctx, project)
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	cmd.Dir = projectDir
+	cmd := cm.composeCommand(ctx, project, []string{modifiedPath}, "restart")
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("docker compose logs failed: %w\nstderr: %s", err, stderr.String())
+		return fmt.Errorf("docker compose restart failed: %w\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
 	}
 
-	return stdout.String(), nil
+	return nil
 }
 
 // PS gets the status of docker compose services
 func (cm *ComposeManager) PS(ctx context.Context, project *models.Project) (string, error) {
-	projectDir := cm.GetProjectDir(project.Name)
-
-	modifiedPath := filepath.Join(projectDir, ".slimdeploy-compose.yml")
-
-	// Check if modified compose file exists
-	if _, err := os.Stat(modifiedPath); os.IsNotExist(err) {
-		var composeErr error
-		modifiedPath, composeErr = cm.FindComposeFile(projectDir)
-		if composeErr != nil {
-			return "", composeErr
-		}
+	files, err := cm.deployedFiles(project)
+	if err != nil {
+		return "", err
 	}
 
-	cmd := exec.CommandContext(ctx, "docker", "compose", "-f", modifiedPath, "-p", fmt.Sprintf("slimdeploy-%s", project.Name), "ps", "--format", "table")
-	cmd.Dir = projectDir
+	cmd := cm.composeCommand(ctx, project, files, "ps", "--format", "table")
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
@@ -0,0 +1,192 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// writeFile creates a file and its parent directories
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeDocker puts a docker script first in PATH that succeeds, unless one of
+// its arguments is fail
+func fakeDocker(t *testing.T, fail string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell")
+	}
+	dir := t.TempDir()
+	script := "#!/bin/sh\nfor arg; do [ \"$arg\" = \"$FAKE_DOCKER_FAIL\" ] && exit 1; done\nexit 0\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_DOCKER_FAIL", fail)
+}
+
+// readFile returns the contents of a file, or "" if it doesn't exist
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// composeFixture returns a compose manager with a deployed project whose
+// checkout has changed since
+func composeFixture(t *testing.T) (*ComposeManager, *models.Project, string) {
+	t.Helper()
+	deploymentsDir := t.TempDir()
+	checkout := filepath.Join(deploymentsDir, "web")
+	writeFile(t, filepath.Join(checkout, "docker-compose.yml"), "services:\n  web:\n    image: nginx:2\n")
+	writeFile(t, filepath.Join(checkout, modifiedComposeFile), "deployed\n")
+	return NewComposeManager("localhost", deploymentsDir), &models.Project{Name: "web"}, checkout
+}
+
+func TestUpReplacesComposeFileOnlyWhenDeployed(t *testing.T) {
+	up := func(cm *ComposeManager, project *models.Project) error {
+		return cm.Up(context.Background(), project)
+	}
+	release := func(cm *ComposeManager, project *models.Project) error {
+		_, _, err := cm.RunRelease(context.Background(), project, "", "./migrate")
+		return err
+	}
+
+	tests := []struct {
+		name         string
+		run          func(cm *ComposeManager, project *models.Project) error
+		fail         string
+		wantErr      bool
+		wantReplaced bool
+	}{
+		{"up", up, "", false, true},
+		{"failed up", up, "up", true, false},
+		{"invalid file", up, "config", true, false},
+		{"release", release, "", false, false},
+		{"failed release", release, "run", false, false},
+	}
+	for _, tt := range tests {
+		fakeDocker(t, tt.fail)
+		cm, project, checkout := composeFixture(t)
+
+		if err := tt.run(cm, project); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+
+		deployed := readFile(t, filepath.Join(checkout, modifiedComposeFile))
+		if replaced := deployed != "deployed\n"; replaced != tt.wantReplaced {
+			t.Errorf("%s: compose file replaced = %v, want %v:\n%s", tt.name, replaced, tt.wantReplaced, deployed)
+		}
+
+		temps, err := filepath.Glob(filepath.Join(checkout, ".slimdeploy-compose-*.yml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(temps) > 0 {
+			t.Errorf("%s: temporary files left behind: %q", tt.name, temps)
+		}
+	}
+}
+
+func TestComposeCommandProjectDirectory(t *testing.T) {
+	deploymentsDir := t.TempDir()
+	checkout := filepath.Join(deploymentsDir, "web")
+	writeFile(t, filepath.Join(checkout, "docker-compose.yml"), "services: {}\n")
+	writeFile(t, filepath.Join(checkout, "deploy", "docker-compose.yml"), "services: {}\n")
+	writeFile(t, filepath.Join(checkout, "services", "api", "compose", "prod.yml"), "services: {}\n")
+
+	tests := []struct {
+		name    string
+		compose models.ComposeConfig
+		file    string
+		dir     string
+		workDir string
+	}{
+		{
+			name:    "detected file",
+			file:    filepath.Join(checkout, "docker-compose.yml"),
+			dir:     checkout,
+			workDir: checkout,
+		},
+		{
+			// Relative paths in a nested file resolve from its own directory,
+			// not the checkout root
+			name:    "nested file",
+			compose: models.ComposeConfig{File: "deploy/docker-compose.yml"},
+			file:    filepath.Join(checkout, "deploy", "docker-compose.yml"),
+			dir:     filepath.Join(checkout, "deploy"),
+			workDir: checkout,
+		},
+		{
+			name:    "nested file in a subdirectory",
+			compose: models.ComposeConfig{Dir: "services/api", File: "compose/prod.yml"},
+			file:    filepath.Join(checkout, "services", "api", "compose", "prod.yml"),
+			dir:     filepath.Join(checkout, "services", "api", "compose"),
+			workDir: filepath.Join(checkout, "services", "api"),
+		},
+	}
+
+	cm := NewComposeManager("localhost", deploymentsDir)
+	for _, tt := range tests {
+		project := &models.Project{Name: "web", Compose: tt.compose}
+
+		files, err := cm.composeFiles(project)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(files, []string{tt.file}) {
+			t.Errorf("%s: composeFiles = %q, want %q", tt.name, files, tt.file)
+		}
+
+		cmd := cm.composeCommand(context.Background(), project, files, "config")
+		want := []string{"docker", "compose", "-f", tt.file, "--project-directory", tt.dir, "-p", "slimdeploy-web", "config"}
+		if !reflect.DeepEqual(cmd.Args, want) {
+			t.Errorf("%s: args = %q, want %q", tt.name, cmd.Args, want)
+		}
+		if cmd.Dir != tt.workDir {
+			t.Errorf("%s: dir = %q, want %q", tt.name, cmd.Dir, tt.workDir)
+		}
+	}
+}
+
+func TestDryRunAfterFailedDeploy(t *testing.T) {
+	fakeDocker(t, "up")
+	cm, project, _ := composeFixture(t)
+
+	if err := cm.Up(context.Background(), project); err == nil {
+		t.Fatal("Up succeeded, want the fake up to fail")
+	}
+
+	// The failed deploy's file must not show up as deployed, or the preview
+	// would report nothing to change
+	preview, err := cm.DryRun(context.Background(), project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Deployed != "deployed\n" {
+		t.Errorf("Deployed = %q, want the file of the last successful deploy", preview.Deployed)
+	}
+	if !preview.Changed() {
+		t.Error("Changed() = false after a failed deploy")
+	}
+	if preview.Invalid != "" {
+		t.Errorf("Invalid = %q", preview.Invalid)
+	}
+}
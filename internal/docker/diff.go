@@ -0,0 +1,103 @@
+package docker
+
+import "strings"
+
+// DiffOp is the kind of change a DiffLine records
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = " "
+	DiffAdd    DiffOp = "+"
+	DiffRemove DiffOp = "-"
+)
+
+// DiffLine is a line of a line-by-line diff
+type DiffLine struct {
+	Op   DiffOp `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells bounds the table LineDiff builds; beyond it the changed
+// middle of the files is shown as removed and added wholesale
+const maxDiffCells = 4 << 20
+
+// LineDiff returns the lines of a and b in order, marking the ones only in
+// a as removed and the ones only in b as added
+func LineDiff(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+
+	// Lines shared at the start and end need no table
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	var diff []DiffLine
+	for _, line := range x[:prefix] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Text: line})
+	}
+	diff = append(diff, diffMiddle(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix])...)
+	for _, line := range x[len(x)-suffix:] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Text: line})
+	}
+	return diff
+}
+
+// diffMiddle diffs two runs of lines by their longest common subsequence
+func diffMiddle(x, y []string) []DiffLine {
+	var diff []DiffLine
+	if (len(x)+1)*(len(y)+1) > maxDiffCells {
+		for _, line := range x {
+			diff = append(diff, DiffLine{Op: DiffRemove, Text: line})
+		}
+		for _, line := range y {
+			diff = append(diff, DiffLine{Op: DiffAdd, Text: line})
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			diff = append(diff, DiffLine{Op: DiffEqual, Text: x[i]})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			diff = append(diff, DiffLine{Op: DiffRemove, Text: x[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: DiffAdd, Text: y[j]})
+			j++
+		}
+	}
+	return diff
+}
+
+// splitLines splits text into lines without their trailing newlines
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
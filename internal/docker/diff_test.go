@@ -0,0 +1,79 @@
+package docker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// formatDiff writes a diff one line per entry, prefixed with its op
+func formatDiff(diff []DiffLine) []string {
+	lines := make([]string, len(diff))
+	for i, line := range diff {
+		lines[i] = string(line.Op) + line.Text
+	}
+	return lines
+}
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{"both empty", "", "", []string{}},
+		{"equal", "a\nb\n", "a\nb\n", []string{" a", " b"}},
+		{"added file", "", "a\nb\n", []string{"+a", "+b"}},
+		{"removed file", "a\nb\n", "", []string{"-a", "-b"}},
+		{"changed line", "a\nb\nc\n", "a\nx\nc\n", []string{" a", "-b", "+x", " c"}},
+		{"inserted line", "a\nc\n", "a\nb\nc\n", []string{" a", "+b", " c"}},
+		{"removed line", "a\nb\nc\n", "a\nc\n", []string{" a", "-b", " c"}},
+		{"appended line", "a\n", "a\nb\n", []string{" a", "+b"}},
+		{"missing final newline", "a\nb", "a\nb\n", []string{" a", " b"}},
+		{
+			"interleaved",
+			"services:\n  web:\n    image: nginx:1\n  db:\n    image: postgres:15\n",
+			"services:\n  web:\n    image: nginx:2\n  db:\n    image: postgres:15\n    restart: always\n",
+			[]string{" services:", "   web:", "-    image: nginx:1", "+    image: nginx:2", "   db:", "     image: postgres:15", "+    restart: always"},
+		},
+	}
+	for _, tt := range tests {
+		if got := formatDiff(LineDiff(tt.a, tt.b)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: LineDiff = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLineDiffLarge(t *testing.T) {
+	// Past maxDiffCells the changed middle is replaced wholesale, but the
+	// shared start and end are still kept
+	var a, b strings.Builder
+	a.WriteString("head\n")
+	b.WriteString("head\n")
+	for i := 0; i < 3000; i++ {
+		a.WriteString("a\n")
+		b.WriteString("b\n")
+	}
+	a.WriteString("tail\n")
+	b.WriteString("tail\n")
+
+	diff := LineDiff(a.String(), b.String())
+	if len(diff) != 6002 {
+		t.Fatalf("got %d lines, want 6002", len(diff))
+	}
+	if diff[0].Op != DiffEqual || diff[len(diff)-1].Op != DiffEqual {
+		t.Errorf("shared lines not kept: first %+v, last %+v", diff[0], diff[len(diff)-1])
+	}
+	if diff[1].Op != DiffRemove || diff[3001].Op != DiffAdd {
+		t.Errorf("changed lines not replaced: %+v, %+v", diff[1], diff[3001])
+	}
+}
+
+func TestComposePreviewChanged(t *testing.T) {
+	if (&ComposePreview{Diff: LineDiff("a\n", "a\n")}).Changed() {
+		t.Error("Changed() = true for identical files")
+	}
+	if !(&ComposePreview{Diff: LineDiff("a\n", "b\n")}).Changed() {
+		t.Error("Changed() = false for different files")
+	}
+}
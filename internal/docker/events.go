@@ -0,0 +1,78 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// Container event actions reported by WatchContainerEvents
+const (
+	// EventDie is sent when a container's main process exits, for any reason
+	EventDie = "die"
+	// EventOOM is sent before EventDie when the kernel kills the container
+	// for running out of memory
+	EventOOM = "oom"
+	// EventKill is sent before EventDie when a container is stopped,
+	// restarted or removed through Docker
+	EventKill = "kill"
+)
+
+// ContainerEvent is a lifecycle event of a project container
+type ContainerEvent struct {
+	Action        string
+	ContainerID   string
+	ContainerName string
+	ProjectID     string
+	// ExitCode is set for EventDie
+	ExitCode int
+	Time     time.Time
+}
+
+// WatchContainerEvents calls handle for every die, oom and kill event of a
+// SlimDeploy-managed project container. It blocks until ctx is cancelled or
+// the event stream fails, and returns the stream's error.
+func (c *Client) WatchContainerEvents(ctx context.Context, handle func(ContainerEvent)) error {
+	messages, errs := c.cli.Events(ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", events.ContainerEventType),
+			filters.Arg("label", LabelPrefix+".managed=true"),
+			filters.Arg("event", EventDie),
+			filters.Arg("event", EventOOM),
+			filters.Arg("event", EventKill),
+		),
+	})
+
+	for {
+		select {
+		case msg := <-messages:
+			// Add-ons are managed too but don't belong to a project, and
+			// compose release commands run in one-off service containers
+			projectID := msg.Actor.Attributes[LabelPrefix+".project"]
+			if projectID == "" || msg.Actor.Attributes["com.docker.compose.oneoff"] == "True" {
+				continue
+			}
+			event := ContainerEvent{
+				Action:        msg.Action,
+				ContainerID:   msg.Actor.ID,
+				ContainerName: msg.Actor.Attributes["name"],
+				ProjectID:     projectID,
+				Time:          time.Unix(0, msg.TimeNano),
+			}
+			if code, err := strconv.Atoi(msg.Actor.Attributes["exitCode"]); err == nil {
+				event.ExitCode = code
+			}
+			handle(event)
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("docker event stream failed: %w", err)
+		}
+	}
+}
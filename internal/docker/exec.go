@@ -0,0 +1,92 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+)
+
+// shellCommand starts bash when the image has it and sh otherwise
+var shellCommand = []string{"/bin/sh", "-c", "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"}
+
+// ExecSession is an interactive shell in a container. Reads return the
+// terminal output and writes send keyboard input.
+type ExecSession struct {
+	cli  *Client
+	id   string
+	resp types.HijackedResponse
+}
+
+// ShellContainer returns the ID of the running container of a project's
+// service to open a shell in. An empty service selects any.
+func (c *Client) ShellContainer(ctx context.Context, projectID, service string) (string, error) {
+	containers, err := c.ListProjectContainers(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+
+	for _, cont := range containers {
+		if service != "" && containerServiceName(cont) != service {
+			continue
+		}
+		if cont.State == "running" {
+			return cont.ID, nil
+		}
+	}
+	if service != "" {
+		return "", fmt.Errorf("no running container for service %s", service)
+	}
+	return "", fmt.Errorf("no running container")
+}
+
+// ExecShell starts an interactive shell with a TTY of the given size in a
+// running container. The session must be closed.
+func (c *Client) ExecShell(ctx context.Context, containerID string, cols, rows uint) (*ExecSession, error) {
+	size := &[2]uint{rows, cols}
+	exec, err := c.cli.ContainerExecCreate(ctx, containerID, types.ExecConfig{
+		Tty:          true,
+		ConsoleSize:  size,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Env:          []string{"TERM=xterm-256color"},
+		Cmd:          shellCommand,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exec: %w", err)
+	}
+
+	resp, err := c.cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{Tty: true, ConsoleSize: size})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach to exec: %w", err)
+	}
+
+	return &ExecSession{cli: c, id: exec.ID, resp: resp}, nil
+}
+
+// Read reads terminal output
+func (s *ExecSession) Read(p []byte) (int, error) {
+	return s.resp.Reader.Read(p)
+}
+
+// Write sends terminal input
+func (s *ExecSession) Write(p []byte) (int, error) {
+	return s.resp.Conn.Write(p)
+}
+
+// Resize changes the terminal size
+func (s *ExecSession) Resize(ctx context.Context, cols, rows uint) error {
+	if err := s.cli.cli.ContainerExecResize(ctx, s.id, types.ResizeOptions{Width: cols, Height: rows}); err != nil {
+		return fmt.Errorf("failed to resize terminal: %w", err)
+	}
+	return nil
+}
+
+// Close ends the input, which makes the shell exit, and closes the
+// connection
+func (s *ExecSession) Close() error {
+	s.resp.CloseWrite()
+	s.resp.Close()
+	return nil
+}
@@ -0,0 +1,393 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// LogOptions selects the logs of a project's containers
+type LogOptions struct {
+	// Services limits the logs to these services; empty means all
+	Services []string
+	// Tail is the number of lines per container, 0 for all
+	Tail  int
+	Since time.Time
+	// Until is ignored when following
+	Until  time.Time
+	Follow bool
+}
+
+// LogStream is the output stream a container wrote a log line to
+type LogStream string
+
+// Log streams
+const (
+	StreamStdout LogStream = "stdout"
+	StreamStderr LogStream = "stderr"
+)
+
+// LogEntry is a line of a container's output
+type LogEntry struct {
+	Stream LogStream `json:"stream"`
+	// Time is when Docker received the line, zero if it has no timestamp
+	Time time.Time `json:"time"`
+	Text string    `json:"text"`
+}
+
+// LogLine is a line of output of one of a project's containers
+type LogLine struct {
+	Service string `json:"service"`
+	LogEntry
+}
+
+// ProjectLogServices returns the sorted service names of a project's
+// containers, as used in LogLine.Service
+func (c *Client) ProjectLogServices(ctx context.Context, projectID string) ([]string, error) {
+	containers, err := c.ListProjectContainers(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []string
+	seen := make(map[string]bool)
+	for _, cont := range containers {
+		if service := containerServiceName(cont); !seen[service] {
+			seen[service] = true
+			services = append(services, service)
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// LogSource is a project container whose logs can be collected
+type LogSource struct {
+	ContainerID string
+	ProjectID   string
+	Service     string
+	Running     bool
+}
+
+// ListLogSources lists the containers of all projects. Add-ons and compose
+// one-off runs are left out.
+func (c *Client) ListLogSources(ctx context.Context) ([]LogSource, error) {
+	containers, err := c.ListAllManagedContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []LogSource
+	for _, cont := range containers {
+		projectID := cont.Labels[LabelPrefix+".project"]
+		if projectID == "" || cont.Labels["com.docker.compose.oneoff"] == "True" {
+			continue
+		}
+		sources = append(sources, LogSource{
+			ContainerID: cont.ID,
+			ProjectID:   projectID,
+			Service:     containerServiceName(cont),
+			Running:     cont.State == "running",
+		})
+	}
+	return sources, nil
+}
+
+// ProjectLogs calls handle for each log line of a project's containers.
+// Without follow, the lines of all containers are read first and handled in
+// time order. With follow, lines are handled as they arrive until ctx is
+// done or handle returns an error.
+func (c *Client) ProjectLogs(ctx context.Context, projectID string, opts LogOptions, handle func(LogLine) error) error {
+	containers, err := c.ListProjectContainers(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	selected := make(map[string]bool)
+	for _, service := range opts.Services {
+		selected[service] = true
+	}
+	if opts.Follow {
+		opts.Until = time.Time{}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var lines []LogLine
+	var handleErr error
+	emit := func(line LogLine) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !opts.Follow {
+			lines = append(lines, line)
+			return
+		}
+		if handleErr != nil {
+			return
+		}
+		if handleErr = handle(line); handleErr != nil {
+			cancel()
+		}
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(containers))
+	for _, cont := range containers {
+		service := containerServiceName(cont)
+		if len(selected) > 0 && !selected[service] {
+			continue
+		}
+
+		wg.Add(1)
+		go func(cont types.Container) {
+			defer wg.Done()
+			if err := c.readContainerLogs(ctx, cont.ID, opts, func(entry LogEntry) {
+				emit(LogLine{Service: service, LogEntry: entry})
+			}); err != nil {
+				errCh <- err
+			}
+		}(cont)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if handleErr != nil {
+		return handleErr
+	}
+	for err := range errCh {
+		if ctx.Err() == nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time.Before(lines[j].Time)
+	})
+	for _, line := range lines {
+		if err := handle(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StreamProjectLogs writes the logs of all containers of a project to w.
+// When the project has more than one container, each line is prefixed with
+// the container's service name. With follow set, it blocks until ctx is done.
+func (c *Client) StreamProjectLogs(ctx context.Context, projectID string, tail int, follow bool, w io.Writer) error {
+	services, err := c.ProjectLogServices(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		_, err := io.WriteString(w, "No containers running\n")
+		return err
+	}
+
+	width := 0
+	for _, service := range services {
+		width = max(width, len(service))
+	}
+
+	return c.ProjectLogs(ctx, projectID, LogOptions{Tail: tail, Follow: follow}, func(line LogLine) error {
+		if len(services) > 1 {
+			if _, err := fmt.Fprintf(w, "%-*s | ", width, line.Service); err != nil {
+				return err
+			}
+		}
+		if !line.Time.IsZero() {
+			if _, err := fmt.Fprintf(w, "%s ", line.Time.Format(time.RFC3339Nano)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(w, line.Text); err != nil {
+			return err
+		}
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+		return nil
+	})
+}
+
+// readContainerLogs passes each log entry of a container to emit
+func (c *Client) readContainerLogs(ctx context.Context, containerID string, opts LogOptions, emit func(LogEntry)) error {
+	reader, err := c.GetContainerLogs(ctx, containerID, opts)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to read container logs: %w", err)
+		}
+		emit(entry)
+	}
+}
+
+// containerServiceName returns the compose service name of a container,
+// falling back to its container name
+func containerServiceName(cont types.Container) string {
+	if service := cont.Labels["com.docker.compose.service"]; service != "" {
+		return service
+	}
+	if len(cont.Names) > 0 {
+		return strings.TrimPrefix(cont.Names[0], "/")
+	}
+	return cont.ID[:12]
+}
+
+// LogReader reads the entries of a container log stream. Docker prefixes
+// each write of a non-TTY container with an 8-byte header holding the stream
+// and payload size. Frames don't align with lines, so each stream's partial
+// line is buffered until it is complete.
+type LogReader struct {
+	rc      io.ReadCloser
+	r       *bufio.Reader
+	tty     bool
+	partial map[LogStream][]byte
+	queue   []LogEntry
+	err     error
+}
+
+func newLogReader(rc io.ReadCloser, tty bool) *LogReader {
+	return &LogReader{
+		rc:      rc,
+		r:       bufio.NewReader(rc),
+		tty:     tty,
+		partial: make(map[LogStream][]byte),
+	}
+}
+
+// Next returns the next log entry, or io.EOF after the last one
+func (lr *LogReader) Next() (LogEntry, error) {
+	for len(lr.queue) == 0 {
+		if lr.err != nil {
+			return LogEntry{}, lr.err
+		}
+		if lr.err = lr.readFrame(); lr.err != nil {
+			// A stream may end without a final newline
+			for _, stream := range []LogStream{StreamStdout, StreamStderr} {
+				if len(lr.partial[stream]) > 0 {
+					lr.queueLine(stream, lr.partial[stream])
+					lr.partial[stream] = nil
+				}
+			}
+		}
+	}
+
+	entry := lr.queue[0]
+	lr.queue = lr.queue[1:]
+	return entry, nil
+}
+
+// Close closes the underlying log stream
+func (lr *LogReader) Close() error {
+	return lr.rc.Close()
+}
+
+// readFrame reads the next frame, or the next chunk of a TTY stream, and
+// queues the lines it completes
+func (lr *LogReader) readFrame() error {
+	if lr.tty {
+		buf := make([]byte, 32*1024)
+		n, err := lr.r.Read(buf)
+		lr.write(StreamStdout, buf[:n])
+		return err
+	}
+
+	var header [8]byte
+	if _, err := io.ReadFull(lr.r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("truncated log frame header")
+		}
+		return err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[4:]))
+	if _, err := io.ReadFull(lr.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("truncated log frame: %w", err)
+	}
+
+	switch header[0] {
+	case 0, 1:
+		lr.write(StreamStdout, payload)
+	case 2:
+		lr.write(StreamStderr, payload)
+	case 3:
+		return fmt.Errorf("error from daemon in log stream: %s", payload)
+	default:
+		return fmt.Errorf("unknown log stream %d", header[0])
+	}
+	return nil
+}
+
+// write appends data to a stream's partial line and queues the lines it
+// completes
+func (lr *LogReader) write(stream LogStream, data []byte) {
+	// Lines longer than Docker's message size arrive as partial messages,
+	// each with its own timestamp; keep only the first
+	if !lr.tty && len(lr.partial[stream]) > 0 {
+		if _, rest, ok := cutTimestamp(data); ok {
+			data = rest
+		}
+	}
+
+	buf := append(lr.partial[stream], data...)
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			break
+		}
+		lr.queueLine(stream, buf[:i])
+		buf = buf[i+1:]
+	}
+	lr.partial[stream] = append([]byte(nil), buf...)
+}
+
+// queueLine queues a complete line, splitting off its timestamp
+func (lr *LogReader) queueLine(stream LogStream, line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	entry := LogEntry{Stream: stream, Text: string(line)}
+	if t, rest, ok := cutTimestamp(line); ok {
+		entry.Time, entry.Text = t, string(rest)
+	}
+	lr.queue = append(lr.queue, entry)
+}
+
+// cutTimestamp splits the RFC 3339 timestamp Docker prefixes log lines with
+// off the start of b
+func cutTimestamp(b []byte) (time.Time, []byte, bool) {
+	ts, rest, ok := bytes.Cut(b, []byte(" "))
+	if !ok {
+		return time.Time{}, b, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, string(ts))
+	if err != nil {
+		return time.Time{}, b, false
+	}
+	return t, rest, true
+}
@@ -0,0 +1,132 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// frame encodes a payload as a multiplexed log frame of stream, 1 for
+// stdout and 2 for stderr
+func frame(stream byte, payload string) string {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return string(header) + payload
+}
+
+// readEntries reads every entry of a log stream
+func readEntries(t *testing.T, data string, tty bool) ([]LogEntry, error) {
+	t.Helper()
+	lr := newLogReader(io.NopCloser(strings.NewReader(data)), tty)
+	var entries []LogEntry
+	for {
+		entry, err := lr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+}
+
+func TestLogReader(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	stamp := ts.Format(time.RFC3339Nano) + " "
+
+	tests := []struct {
+		name string
+		data string
+		tty  bool
+		want []LogEntry
+	}{
+		{
+			name: "frames",
+			data: frame(1, "out\n") + frame(2, "err\n"),
+			want: []LogEntry{{Stream: StreamStdout, Text: "out"}, {Stream: StreamStderr, Text: "err"}},
+		},
+		{
+			name: "several lines in a frame",
+			data: frame(1, "a\nb\r\n"),
+			want: []LogEntry{{Stream: StreamStdout, Text: "a"}, {Stream: StreamStdout, Text: "b"}},
+		},
+		{
+			name: "line split across frames",
+			data: frame(1, "hel") + frame(2, "err\n") + frame(1, "lo\n"),
+			want: []LogEntry{{Stream: StreamStderr, Text: "err"}, {Stream: StreamStdout, Text: "hello"}},
+		},
+		{
+			name: "no final newline",
+			data: frame(1, "a\nb"),
+			want: []LogEntry{{Stream: StreamStdout, Text: "a"}, {Stream: StreamStdout, Text: "b"}},
+		},
+		{
+			name: "timestamps",
+			data: frame(1, stamp+"with time\n") + frame(1, "without time\n"),
+			want: []LogEntry{{Stream: StreamStdout, Time: ts, Text: "with time"}, {Stream: StreamStdout, Text: "without time"}},
+		},
+		{
+			name: "partial messages keep the first timestamp",
+			data: frame(1, stamp+"long ") + frame(1, stamp+"line\n"),
+			want: []LogEntry{{Stream: StreamStdout, Time: ts, Text: "long line"}},
+		},
+		{
+			name: "tty",
+			data: stamp + "one\r\n" + "two\n",
+			tty:  true,
+			want: []LogEntry{{Stream: StreamStdout, Time: ts, Text: "one"}, {Stream: StreamStdout, Text: "two"}},
+		},
+		{
+			name: "empty",
+			data: "",
+		},
+	}
+	for _, tt := range tests {
+		got, err := readEntries(t, tt.data, tt.tty)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLogReaderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"truncated header", frame(1, "a\n")[:4]},
+		{"truncated payload", frame(1, "abc\n")[:10]},
+		{"daemon error", frame(3, "boom")},
+		{"unknown stream", frame(9, "a\n")},
+	}
+	for _, tt := range tests {
+		entries, err := readEntries(t, tt.data, false)
+		if err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("%s: got entries %+v and no error", tt.name, entries)
+		}
+	}
+}
+
+func TestLogReaderFlushesBeforeError(t *testing.T) {
+	data := frame(1, "partial") + frame(3, "boom")
+	lr := newLogReader(io.NopCloser(bytes.NewReader([]byte(data))), false)
+
+	entry, err := lr.Next()
+	if err != nil || entry.Text != "partial" {
+		t.Fatalf("Next() = %+v, %v, want the partial line", entry, err)
+	}
+	if _, err := lr.Next(); err == nil {
+		t.Fatal("Next() after the partial line succeeded, want the daemon error")
+	}
+}
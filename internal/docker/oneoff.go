@@ -0,0 +1,213 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// RunJob runs a job's command in a one-off container created from the image,
+// environment, mounts and networks of the project's current container. It
+// returns the command's exit code and the tail of its output; err is only
+// set when the command could not run to completion, such as on a timeout.
+func (c *Client) RunJob(ctx context.Context, project *models.Project, job *models.Job) (exitCode int, output string, err error) {
+	source, err := c.jobSourceContainer(ctx, project, job.Service)
+	if err != nil {
+		return 0, "", err
+	}
+
+	info, err := c.cli.ContainerInspect(ctx, source)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to inspect project container: %w", err)
+	}
+
+	var mounts []mount.Mount
+	for _, mp := range info.Mounts {
+		switch mp.Type {
+		case mount.TypeVolume:
+			mounts = append(mounts, mount.Mount{Type: mount.TypeVolume, Source: mp.Name, Target: mp.Destination, ReadOnly: !mp.RW})
+		case mount.TypeBind:
+			mounts = append(mounts, mount.Mount{Type: mount.TypeBind, Source: mp.Source, Target: mp.Destination, ReadOnly: !mp.RW})
+		}
+	}
+
+	var networks []string
+	if info.NetworkSettings != nil {
+		for name := range info.NetworkSettings.Networks {
+			networks = append(networks, name)
+		}
+	}
+
+	return c.runOneOff(ctx,
+		&container.Config{
+			Image:      info.Image,
+			Env:        info.Config.Env,
+			User:       info.Config.User,
+			WorkingDir: info.Config.WorkingDir,
+			Entrypoint: []string{"sh", "-c"},
+			Cmd:        []string{job.Command},
+			Labels: map[string]string{
+				LabelPrefix + ".job": job.ID,
+			},
+		},
+		&container.HostConfig{
+			Resources: hostResources(project.Resources),
+			Mounts:    mounts,
+		},
+		networks,
+		job.Timeout(),
+	)
+}
+
+// RunRelease runs a release command of an image project in a one-off
+// container from the project's image with its environment, mounts and
+// network. It returns the command's exit code and the tail of its output;
+// err is only set when the command could not run to completion.
+func (c *Client) RunRelease(ctx context.Context, project *models.Project, command string) (exitCode int, output string, err error) {
+	var env []string
+	for k, v := range project.EnvVars {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	mounts, err := c.projectMounts(ctx, project)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return c.runOneOff(ctx,
+		&container.Config{
+			Image:      project.Image,
+			Env:        env,
+			Entrypoint: []string{"sh", "-c"},
+			Cmd:        []string{command},
+			Labels: map[string]string{
+				LabelPrefix + ".release": project.ID,
+			},
+		},
+		&container.HostConfig{
+			Resources: hostResources(project.Resources),
+			Mounts:    mounts,
+		},
+		[]string{NetworkName},
+		project.Release.Timeout(),
+	)
+}
+
+// runOneOff creates a container attached to networks, runs it to completion
+// or until timeout and removes it. It returns the container's exit code and
+// the tail of its output; err is only set when the container could not run
+// to completion.
+func (c *Client) runOneOff(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networks []string, timeout time.Duration) (exitCode int, output string, err error) {
+	var networking *network.NetworkingConfig
+	if len(networks) > 0 {
+		networking = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{networks[0]: {}},
+		}
+	}
+
+	resp, err := c.cli.ContainerCreate(ctx, config, hostConfig, networking, nil, "")
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create container: %w", err)
+	}
+	// Remove with a fresh context so the container is cleaned up on timeout
+	defer c.cli.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{Force: true})
+
+	for _, name := range networks[min(1, len(networks)):] {
+		if err := c.cli.NetworkConnect(ctx, name, resp.ID, nil); err != nil {
+			return 0, "", fmt.Errorf("failed to connect container to %s: %w", name, err)
+		}
+	}
+
+	if err := c.cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return 0, "", fmt.Errorf("failed to start container: %w", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	statusCh, errCh := c.cli.ContainerWait(waitCtx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		exitCode = int(status.StatusCode)
+	case werr := <-errCh:
+		if waitCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		} else {
+			err = fmt.Errorf("failed to wait for container: %w", werr)
+		}
+		// Stop the command before collecting what it printed so far
+		c.cli.ContainerKill(context.Background(), resp.ID, "KILL")
+	}
+
+	return exitCode, c.containerOutput(resp.ID), err
+}
+
+// jobSourceContainer returns the ID of the project container a job copies,
+// preferring running containers. For compose projects service selects the
+// service; empty selects any.
+func (c *Client) jobSourceContainer(ctx context.Context, project *models.Project, service string) (string, error) {
+	containers, err := c.ListProjectContainers(ctx, project.ID)
+	if err != nil {
+		return "", err
+	}
+
+	var found string
+	for _, cont := range containers {
+		if service != "" && containerServiceName(cont) != service {
+			continue
+		}
+		if cont.State == "running" {
+			return cont.ID, nil
+		}
+		if found == "" {
+			found = cont.ID
+		}
+	}
+	if found == "" {
+		if service != "" {
+			return "", fmt.Errorf("no container found for service %s; deploy the project first", service)
+		}
+		return "", fmt.Errorf("no container found; deploy the project first")
+	}
+	return found, nil
+}
+
+// containerOutput returns the tail of a one-off container's output
+func (c *Client) containerOutput(containerID string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	reader, err := c.cli.ContainerLogs(ctx, containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return fmt.Sprintf("(failed to get output: %v)", err)
+	}
+	defer reader.Close()
+
+	out := &tailBuffer{limit: models.JobOutputLimit}
+	stdcopy.StdCopy(out, out, reader)
+	return string(out.buf)
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = append(b.buf[:0], b.buf[len(b.buf)-b.limit:]...)
+	}
+	return len(p), nil
+}
@@ -0,0 +1,87 @@
+package docker
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+const megabyte = 1024 * 1024
+
+// hostResources converts project resource limits into container resources
+func hostResources(limits models.ResourceLimits) container.Resources {
+	var resources container.Resources
+	if limits.MemoryMB > 0 {
+		resources.Memory = limits.MemoryMB * megabyte
+	}
+	if limits.MemoryReservationMB > 0 {
+		resources.MemoryReservation = limits.MemoryReservationMB * megabyte
+	}
+	if limits.CPUs > 0 {
+		resources.NanoCPUs = int64(limits.CPUs * 1e9)
+	}
+	if limits.CPUShares > 0 {
+		resources.CPUShares = limits.CPUShares
+	}
+	if limits.PidsLimit > 0 {
+		pids := limits.PidsLimit
+		resources.PidsLimit = &pids
+	}
+	return resources
+}
+
+// injectResources sets the project's resource limits on a compose service.
+// Limits set on the project override the same keys in deploy.resources; other
+// deploy settings from the compose file are kept.
+func injectResources(service *ComposeService, limits models.ResourceLimits) {
+	if limits.IsZero() {
+		return
+	}
+
+	deploy := toStringMap(service.Deploy)
+	resources := toStringMap(deploy["resources"])
+	limitsMap := toStringMap(resources["limits"])
+	reservations := toStringMap(resources["reservations"])
+
+	if limits.MemoryMB > 0 {
+		limitsMap["memory"] = fmt.Sprintf("%dM", limits.MemoryMB)
+	}
+	if limits.CPUs > 0 {
+		limitsMap["cpus"] = strconv.FormatFloat(limits.CPUs, 'f', -1, 64)
+	}
+	if limits.PidsLimit > 0 {
+		limitsMap["pids"] = limits.PidsLimit
+	}
+	if limits.MemoryReservationMB > 0 {
+		reservations["memory"] = fmt.Sprintf("%dM", limits.MemoryReservationMB)
+	}
+
+	if len(limitsMap) > 0 {
+		resources["limits"] = limitsMap
+	}
+	if len(reservations) > 0 {
+		resources["reservations"] = reservations
+	}
+	deploy["resources"] = resources
+	service.Deploy = deploy
+
+	// CPU shares are not part of deploy.resources
+	if limits.CPUShares > 0 {
+		extra := toStringMap(service.Extra)
+		extra["cpu_shares"] = limits.CPUShares
+		service.Extra = extra
+	}
+}
+
+// toStringMap returns a copy of a decoded YAML mapping, or an empty map
+func toStringMap(v interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, val := range m {
+			result[k] = val
+		}
+	}
+	return result
+}
@@ -0,0 +1,158 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// ContainerStats is a point-in-time resource usage sample for one container.
+// Network and block I/O values are cumulative since the container started.
+type ContainerStats struct {
+	ContainerID string    `json:"container_id"`
+	Name        string    `json:"name"`
+	Service     string    `json:"service"`
+	ProjectID   string    `json:"project_id"`
+	Time        time.Time `json:"time"`
+	CPUPercent  float64   `json:"cpu_percent"`
+	MemoryUsage uint64    `json:"memory_usage"`
+	MemoryLimit uint64    `json:"memory_limit"`
+	NetworkRx   uint64    `json:"network_rx"`
+	NetworkTx   uint64    `json:"network_tx"`
+	BlockRead   uint64    `json:"block_read"`
+	BlockWrite  uint64    `json:"block_write"`
+}
+
+// HostInfo describes the resources of the Docker host
+type HostInfo struct {
+	CPUs        int    `json:"cpus"`
+	MemoryTotal uint64 `json:"memory_total"`
+}
+
+// GetHostInfo returns the CPU count and total memory of the Docker host
+func (c *Client) GetHostInfo(ctx context.Context) (*HostInfo, error) {
+	info, err := c.cli.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get docker info: %w", err)
+	}
+	return &HostInfo{CPUs: info.NCPU, MemoryTotal: uint64(info.MemTotal)}, nil
+}
+
+// GetContainerStats takes a single stats sample of a running container.
+// Docker waits for a second sample internally so CPU usage can be computed.
+func (c *Client) GetContainerStats(ctx context.Context, containerID string) (*ContainerStats, error) {
+	resp, err := c.cli.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats for container %s: %w", containerID, err)
+	}
+	defer resp.Body.Close()
+
+	var raw types.StatsJSON
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode stats for container %s: %w", containerID, err)
+	}
+
+	stats := &ContainerStats{
+		ContainerID: containerID,
+		Name:        strings.TrimPrefix(raw.Name, "/"),
+		Time:        raw.Read,
+		CPUPercent:  cpuPercent(&raw),
+		MemoryUsage: memoryUsage(&raw.MemoryStats),
+		MemoryLimit: raw.MemoryStats.Limit,
+	}
+
+	for _, network := range raw.Networks {
+		stats.NetworkRx += network.RxBytes
+		stats.NetworkTx += network.TxBytes
+	}
+
+	for _, entry := range raw.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+
+	if stats.Time.IsZero() {
+		stats.Time = time.Now()
+	}
+
+	return stats, nil
+}
+
+// GetManagedContainerStats samples every running SlimDeploy-managed container
+// concurrently. Containers that fail to report stats are skipped.
+func (c *Client) GetManagedContainerStats(ctx context.Context) ([]ContainerStats, error) {
+	containers, err := c.ListAllManagedContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results []ContainerStats
+	)
+	for _, cont := range containers {
+		if cont.State != "running" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(cont types.Container) {
+			defer wg.Done()
+
+			stats, err := c.GetContainerStats(ctx, cont.ID)
+			if err != nil {
+				return
+			}
+			stats.ProjectID = cont.Labels[LabelPrefix+".project"]
+			stats.Service = containerServiceName(cont)
+
+			mu.Lock()
+			results = append(results, *stats)
+			mu.Unlock()
+		}(cont)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// cpuPercent computes CPU usage the same way as "docker stats": the share of
+// host CPU time used since the previous sample, scaled by the number of CPUs
+func cpuPercent(s *types.StatsJSON) float64 {
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpus == 0 {
+		cpus = 1
+	}
+
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage excludes the page cache, like "docker stats" does
+func memoryUsage(m *types.MemoryStats) uint64 {
+	// cgroup v1 reports total_inactive_file, cgroup v2 inactive_file
+	for _, key := range []string{"total_inactive_file", "inactive_file"} {
+		if v, ok := m.Stats[key]; ok && v < m.Usage {
+			return m.Usage - v
+		}
+	}
+	return m.Usage
+}
@@ -1,7 +1,10 @@
 package docker
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/mhenrichsen/slimdeploy/internal/models"
@@ -57,22 +60,28 @@ func GenerateTraefikLabels(project *models.Project, baseDomain string) map[strin
 	return labels
 }
 
-// GenerateTraefikLabelsForCompose generates Traefik labels for docker-compose services
-// Returns a map of service name to labels
-func GenerateTraefikLabelsForCompose(project *models.Project, baseDomain string, serviceName string) map[string]string {
-	// For compose, we use project-service as the router name
-	routerName := sanitizeRouterName(fmt.Sprintf("%s-%s", project.Name, serviceName))
+// GenerateTraefikLabelsForCompose generates the Traefik labels of a routed
+// docker-compose service
+func GenerateTraefikLabelsForCompose(project *models.Project, baseDomain string, service models.RoutedService) map[string]string {
+	// For compose, we use project-service as the router name, namespaced
+	// when it sits next to the compose file's own routers
+	routerName := sanitizeRouterName(fmt.Sprintf("%s-%s", project.Name, service.Name))
+	if project.Compose.MergeTraefikLabels {
+		routerName = "slimdeploy-" + routerName
+	}
 
 	// Get the domain to use
-	domain := project.GetEffectiveDomain(baseDomain)
+	domain := service.EffectiveDomain(project, baseDomain)
 	if domain == "" {
 		return map[string]string{}
 	}
 
 	// Get the port
-	port := project.Port
-	if port == 0 {
-		port = 80
+	port := service.EffectivePort(project)
+
+	rule := fmt.Sprintf("Host(`%s`)", domain)
+	if service.PathPrefix != "" {
+		rule += fmt.Sprintf(" && PathPrefix(`%s`)", service.PathPrefix)
 	}
 
 	// Check if we're in local/dev mode (localhost domain means no SSL)
@@ -89,21 +98,30 @@ func GenerateTraefikLabelsForCompose(project *models.Project, baseDomain string,
 
 	if isLocal {
 		// Simple HTTP-only routing for local development
-		labels[fmt.Sprintf("traefik.http.routers.%s.rule", routerName)] = fmt.Sprintf("Host(`%s`)", domain)
+		labels[fmt.Sprintf("traefik.http.routers.%s.rule", routerName)] = rule
 		labels[fmt.Sprintf("traefik.http.routers.%s.entrypoints", routerName)] = "web"
 	} else {
 		// Production routing with HTTPS redirect
 		// HTTP router (for redirect to HTTPS)
-		labels[fmt.Sprintf("traefik.http.routers.%s-http.rule", routerName)] = fmt.Sprintf("Host(`%s`)", domain)
+		labels[fmt.Sprintf("traefik.http.routers.%s-http.rule", routerName)] = rule
 		labels[fmt.Sprintf("traefik.http.routers.%s-http.entrypoints", routerName)] = "web"
 		labels[fmt.Sprintf("traefik.http.routers.%s-http.middlewares", routerName)] = "redirect-to-https@docker"
 
 		// HTTPS router
-		labels[fmt.Sprintf("traefik.http.routers.%s.rule", routerName)] = fmt.Sprintf("Host(`%s`)", domain)
+		labels[fmt.Sprintf("traefik.http.routers.%s.rule", routerName)] = rule
 		labels[fmt.Sprintf("traefik.http.routers.%s.entrypoints", routerName)] = "websecure"
 		labels[fmt.Sprintf("traefik.http.routers.%s.tls.certresolver", routerName)] = "letsencrypt"
 	}
 
+	// Traefik can't pick a service for a router on a container that
+	// defines several, so name it when the compose file may add its own
+	if project.Compose.MergeTraefikLabels {
+		labels[fmt.Sprintf("traefik.http.routers.%s.service", routerName)] = routerName
+		if !isLocal {
+			labels[fmt.Sprintf("traefik.http.routers.%s-http.service", routerName)] = routerName
+		}
+	}
+
 	return labels
 }
 
@@ -137,3 +155,108 @@ func GenerateRedirectMiddleware() map[string]string {
 		"traefik.http.middlewares.redirect-to-https.redirectscheme.permanent": "true",
 	}
 }
+
+// traefikLabelPattern matches the Traefik labels that define routers,
+// services and middlewares, capturing the protocol, kind, name and option
+var traefikLabelPattern = regexp.MustCompile(`^traefik\.(http|tcp|udp)\.(routers|services|middlewares)\.([^.]+)\.(.+)$`)
+
+// traefikObject is a router, service or middleware defined by labels
+type traefikObject struct {
+	protocol string
+	kind     string
+	name     string
+}
+
+// ValidateTraefikLabels checks the Traefik labels of a compose project's
+// services, keyed by service name, for mistakes Traefik would only log:
+// objects defined differently by two services, routers without a rule,
+// and references to services or middlewares that aren't defined. Names
+// with a provider suffix such as @file are assumed to exist.
+func ValidateTraefikLabels(services map[string]map[string]string) error {
+	defined := make(map[traefikObject]bool)
+	setBy := make(map[string]string)
+	routers := make(map[string]map[traefikObject]map[string]string)
+	serviceCount := make(map[string]map[string]int)
+	var errs []error
+
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		labels := services[name]
+		if labels["traefik.enable"] == "false" {
+			continue
+		}
+		routers[name] = make(map[traefikObject]map[string]string)
+		serviceCount[name] = make(map[string]int)
+		own := make(map[traefikObject]bool)
+
+		keys := make([]string, 0, len(labels))
+		for k := range labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			m := traefikLabelPattern.FindStringSubmatch(k)
+			if m == nil {
+				continue
+			}
+			obj := traefikObject{protocol: m[1], kind: m[2], name: m[3]}
+			if !own[obj] && obj.kind == "services" {
+				serviceCount[name][obj.protocol]++
+			}
+			own[obj] = true
+			defined[obj] = true
+
+			if other, ok := setBy[k]; ok && services[other][k] != labels[k] {
+				errs = append(errs, fmt.Errorf("%s is set differently by services %s and %s", k, other, name))
+			}
+			setBy[k] = name
+
+			if obj.kind == "routers" {
+				if routers[name][obj] == nil {
+					routers[name][obj] = make(map[string]string)
+				}
+				routers[name][obj][m[4]] = labels[k]
+			}
+		}
+	}
+
+	for _, name := range names {
+		objs := make([]traefikObject, 0, len(routers[name]))
+		for obj := range routers[name] {
+			objs = append(objs, obj)
+		}
+		sort.Slice(objs, func(i, j int) bool {
+			return objs[i].protocol+objs[i].name < objs[j].protocol+objs[j].name
+		})
+
+		for _, obj := range objs {
+			options := routers[name][obj]
+			if obj.protocol != "udp" && options["rule"] == "" {
+				errs = append(errs, fmt.Errorf("%s router %s of service %s has no rule", obj.protocol, obj.name, name))
+			}
+
+			if target := options["service"]; target != "" {
+				if !strings.Contains(target, "@") && !defined[traefikObject{obj.protocol, "services", target}] {
+					errs = append(errs, fmt.Errorf("%s router %s of service %s uses undefined service %s", obj.protocol, obj.name, name, target))
+				}
+			} else if serviceCount[name][obj.protocol] > 1 {
+				errs = append(errs, fmt.Errorf("%s router %s of service %s must name its service, as the service defines several", obj.protocol, obj.name, name))
+			}
+
+			for _, mw := range strings.Split(options["middlewares"], ",") {
+				mw = strings.TrimSpace(mw)
+				if mw != "" && !strings.Contains(mw, "@") && !defined[traefikObject{obj.protocol, "middlewares", mw}] {
+					errs = append(errs, fmt.Errorf("%s router %s of service %s uses undefined middleware %s", obj.protocol, obj.name, name, mw))
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTraefikLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		services map[string]map[string]string
+		// wantErr is a substring of the expected error, "" for none
+		wantErr string
+	}{
+		{
+			name: "valid",
+			services: map[string]map[string]string{
+				"web": {
+					"traefik.enable":                                     "true",
+					"traefik.http.routers.web.rule":                      "Host(`example.com`)",
+					"traefik.http.routers.web.middlewares":               "auth, compress@file",
+					"traefik.http.middlewares.auth.basicauth.users":      "admin:hash",
+					"traefik.http.services.web.loadbalancer.server.port": "8080",
+					"traefik.tcp.routers.db.rule":                        "HostSNI(`*`)",
+					"traefik.tcp.services.db.loadbalancer.server.port":   "5432",
+					"traefik.udp.routers.dns.entrypoints":                "dns",
+					"traefik.udp.services.dns.loadbalancer.server.port":  "53",
+					"com.example.unrelated":                              "x",
+				},
+			},
+		},
+		{
+			name: "middleware defined by another service",
+			services: map[string]map[string]string{
+				"web":   {"traefik.http.routers.web.rule": "Host(`a`)", "traefik.http.routers.web.middlewares": "auth"},
+				"proxy": {"traefik.http.middlewares.auth.basicauth.users": "admin:hash"},
+			},
+		},
+		{
+			name: "same label set the same way twice",
+			services: map[string]map[string]string{
+				"a": {"traefik.http.middlewares.gzip.compress": "true"},
+				"b": {"traefik.http.middlewares.gzip.compress": "true"},
+			},
+		},
+		{
+			name: "conflicting definitions",
+			services: map[string]map[string]string{
+				"a": {"traefik.http.middlewares.gzip.compress": "true"},
+				"b": {"traefik.http.middlewares.gzip.compress": "false"},
+			},
+			wantErr: "is set differently by services a and b",
+		},
+		{
+			name: "router without rule",
+			services: map[string]map[string]string{
+				"web": {"traefik.http.routers.web.entrypoints": "websecure"},
+			},
+			wantErr: "http router web of service web has no rule",
+		},
+		{
+			name: "undefined service",
+			services: map[string]map[string]string{
+				"web": {"traefik.http.routers.web.rule": "Host(`a`)", "traefik.http.routers.web.service": "api"},
+			},
+			wantErr: "uses undefined service api",
+		},
+		{
+			name: "service of another protocol",
+			services: map[string]map[string]string{
+				"web": {
+					"traefik.http.routers.web.rule":                    "Host(`a`)",
+					"traefik.http.routers.web.service":                 "db",
+					"traefik.tcp.services.db.loadbalancer.server.port": "5432",
+				},
+			},
+			wantErr: "uses undefined service db",
+		},
+		{
+			name: "provider service",
+			services: map[string]map[string]string{
+				"web": {"traefik.http.routers.web.rule": "Host(`a`)", "traefik.http.routers.web.service": "api@file"},
+			},
+		},
+		{
+			name: "undefined middleware",
+			services: map[string]map[string]string{
+				"web": {"traefik.http.routers.web.rule": "Host(`a`)", "traefik.http.routers.web.middlewares": "auth"},
+			},
+			wantErr: "uses undefined middleware auth",
+		},
+		{
+			name: "several services without a named one",
+			services: map[string]map[string]string{
+				"web": {
+					"traefik.http.routers.web.rule":                        "Host(`a`)",
+					"traefik.http.services.web.loadbalancer.server.port":   "80",
+					"traefik.http.services.admin.loadbalancer.server.port": "81",
+				},
+			},
+			wantErr: "must name its service",
+		},
+		{
+			name: "services of other compose services don't count",
+			services: map[string]map[string]string{
+				"web": {
+					"traefik.http.routers.web.rule":                      "Host(`a`)",
+					"traefik.http.services.web.loadbalancer.server.port": "80",
+				},
+				"api": {
+					"traefik.http.routers.api.rule":                      "Host(`b`)",
+					"traefik.http.services.api.loadbalancer.server.port": "81",
+				},
+			},
+		},
+		{
+			name: "disabled services are ignored",
+			services: map[string]map[string]string{
+				"web": {"traefik.enable": "false", "traefik.http.routers.web.entrypoints": "websecure"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		err := ValidateTraefikLabels(tt.services)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		case tt.wantErr != "" && err == nil:
+			t.Errorf("%s: got no error, want %q", tt.name, tt.wantErr)
+		case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// VolumeName returns the Docker volume name of a project volume. The project
+// ID is used rather than the name so renaming a project keeps its data.
+func VolumeName(projectID, name string) string {
+	return fmt.Sprintf("slimdeploy-%s-%s", projectID, name)
+}
+
+// ValidateMounts checks a project's mounts, including that every bind mount
+// source is inside one of the allowed host paths
+func (c *Client) ValidateMounts(mounts []models.Mount) error {
+	if err := models.ValidateMounts(mounts); err != nil {
+		return err
+	}
+
+	for _, m := range mounts {
+		if m.Type != models.MountTypeBind {
+			continue
+		}
+		if _, err := c.resolveBind(m.Source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveBind returns the real path of a bind mount source after checking
+// that it is inside an allowed path. Symlinks are resolved first, so a
+// link created inside an allowed path can't point a mount outside of it.
+// Paths are resolved in SlimDeploy's own filesystem, so allowed roots must be
+// mounted into its container at their host paths.
+func (c *Client) resolveBind(source string) (string, error) {
+	if len(c.allowedBindPaths) == 0 {
+		return "", fmt.Errorf("bind mount %s is not allowed: no host paths are allowed (set ALLOWED_BIND_PATHS)", source)
+	}
+
+	resolved, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		return "", fmt.Errorf("bind mount %s is not allowed: %w", source, err)
+	}
+
+	for _, allowed := range c.allowedBindPaths {
+		root, err := filepath.EvalSymlinks(allowed)
+		if err != nil {
+			continue
+		}
+		if resolved == root || strings.HasPrefix(resolved, strings.TrimSuffix(root, "/")+"/") {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("bind mount %s is not allowed: must be inside %s", source, strings.Join(c.allowedBindPaths, ", "))
+}
+
+// projectMounts validates a project's mounts, creates any missing volumes and
+// returns the mounts for the container's host config
+func (c *Client) projectMounts(ctx context.Context, project *models.Project) ([]mount.Mount, error) {
+	if err := c.ValidateMounts(project.Mounts); err != nil {
+		return nil, err
+	}
+
+	var mounts []mount.Mount
+	for _, m := range project.Mounts {
+		switch m.Type {
+		case models.MountTypeVolume:
+			name := VolumeName(project.ID, m.Source)
+			if err := c.ensureVolume(ctx, name, projectVolumeLabels(project.ID, m.Source)); err != nil {
+				return nil, err
+			}
+			mounts = append(mounts, mount.Mount{
+				Type:     mount.TypeVolume,
+				Source:   name,
+				Target:   m.Target,
+				ReadOnly: m.ReadOnly,
+			})
+		case models.MountTypeBind:
+			// Mount the path that was checked, not whatever the link
+			// points to by the time Docker follows it
+			source, err := c.resolveBind(m.Source)
+			if err != nil {
+				return nil, err
+			}
+			mounts = append(mounts, mount.Mount{
+				Type:     mount.TypeBind,
+				Source:   source,
+				Target:   m.Target,
+				ReadOnly: m.ReadOnly,
+			})
+		}
+	}
+	return mounts, nil
+}
+
+// projectVolumeLabels returns the labels of a project volume
+func projectVolumeLabels(projectID, volumeName string) map[string]string {
+	return map[string]string{
+		LabelPrefix + ".managed": "true",
+		LabelPrefix + ".project": projectID,
+		LabelPrefix + ".volume":  volumeName,
+	}
+}
+
+// ensureVolume creates a labelled volume if it doesn't exist yet
+func (c *Client) ensureVolume(ctx context.Context, name string, labels map[string]string) error {
+	if _, err := c.cli.VolumeInspect(ctx, name); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return fmt.Errorf("failed to inspect volume %s: %w", name, err)
+	}
+
+	_, err := c.cli.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   name,
+		Labels: labels,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListProjectVolumes lists all volumes created for a project
+func (c *Client) ListProjectVolumes(ctx context.Context, projectID string) ([]*volume.Volume, error) {
+	resp, err := c.cli.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", fmt.Sprintf("%s.project=%s", LabelPrefix, projectID)),
+		),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumes: %w", err)
+	}
+	return resp.Volumes, nil
+}
+
+// RemoveProjectVolumes removes all volumes created for a project. The
+// project's containers must be removed first.
+func (c *Client) RemoveProjectVolumes(ctx context.Context, projectID string) error {
+	volumes, err := c.ListProjectVolumes(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range volumes {
+		if err := c.cli.VolumeRemove(ctx, v.Name, false); err != nil {
+			return fmt.Errorf("failed to remove volume %s: %w", v.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package events is an in-process bus for project events, streamed to the
+// web UI over Server-Sent Events.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// Type identifies the kind of an event
+type Type string
+
+// Event types
+const (
+	// TypeStatus is published whenever a project's status or status message
+	// is updated
+	TypeStatus Type = "status"
+	// TypeDeploy is published when a deploy starts and when it finishes
+	TypeDeploy Type = "deploy"
+	// TypeCommit is published when the watcher pulls a new commit
+	TypeCommit Type = "commit"
+)
+
+// bufferSize is how many events a subscriber may fall behind before events
+// are dropped for it
+const bufferSize = 64
+
+// Event is a change to a project
+type Event struct {
+	Type      Type                 `json:"type"`
+	ProjectID string               `json:"project_id"`
+	Status    models.ProjectStatus `json:"status,omitempty"`
+	Message   string               `json:"message,omitempty"`
+	Trigger   models.DeployTrigger `json:"trigger,omitempty"`
+	Commit    string               `json:"commit,omitempty"`
+	Time      time.Time            `json:"time"`
+}
+
+// Bus fans published events out to subscribers. A nil Bus discards events.
+type Bus struct {
+	mu     sync.Mutex
+	subs   map[chan Event]struct{}
+	closed bool
+}
+
+// New creates a new event bus
+func New() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel receiving all events published from now on and
+// a function that unsubscribes it. The channel is closed on unsubscribe and
+// when the bus is closed.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, bufferSize)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends an event to all subscribers without blocking. Subscribers
+// whose buffer is full miss the event.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Close closes all subscriber channels, ending their streams
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 )
@@ -83,6 +85,86 @@ func (m *Manager) Clone(gitURL, branch, projectName string) error {
 	return nil
 }
 
+// CloneTag clones a repository at a tag
+func (m *Manager) CloneTag(gitURL, tag, projectName string) error {
+	repoDir := m.GetRepoDir(projectName)
+
+	// Remove existing directory if it exists
+	if err := os.RemoveAll(repoDir); err != nil {
+		return fmt.Errorf("failed to remove existing directory: %w", err)
+	}
+
+	// Get auth
+	auth, err := m.getAuth(gitURL)
+	if err != nil {
+		return err
+	}
+
+	cloneOpts := &git.CloneOptions{
+		URL:           gitURL,
+		ReferenceName: plumbing.NewTagReferenceName(tag),
+		SingleBranch:  true,
+		Depth:         1,
+	}
+	if auth != nil {
+		cloneOpts.Auth = auth
+	}
+
+	if _, err := git.PlainClone(repoDir, false, cloneOpts); err != nil {
+		return fmt.Errorf("failed to clone tag %s: %w", tag, err)
+	}
+
+	return nil
+}
+
+// CloneCommit clones a branch with its full history and checks out one of
+// its commits, which may be given as a short hash
+func (m *Manager) CloneCommit(gitURL, branch, commit, projectName string) error {
+	repoDir := m.GetRepoDir(projectName)
+
+	// Remove existing directory if it exists
+	if err := os.RemoveAll(repoDir); err != nil {
+		return fmt.Errorf("failed to remove existing directory: %w", err)
+	}
+
+	// Get auth
+	auth, err := m.getAuth(gitURL)
+	if err != nil {
+		return err
+	}
+
+	// A shallow clone wouldn't contain older commits
+	cloneOpts := &git.CloneOptions{
+		URL:           gitURL,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+		SingleBranch:  true,
+	}
+	if auth != nil {
+		cloneOpts.Auth = auth
+	}
+
+	repo, err := git.PlainClone(repoDir, false, cloneOpts)
+	if err != nil {
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(commit))
+	if err != nil {
+		return fmt.Errorf("commit %s not found on branch %s: %w", commit, branch, err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: *hash, Force: true}); err != nil {
+		return fmt.Errorf("failed to checkout commit %s: %w", commit, err)
+	}
+
+	return nil
+}
+
 // Pull pulls the latest changes from a repository
 func (m *Manager) Pull(gitURL, branch, projectName string) error {
 	repoDir := m.GetRepoDir(projectName)
@@ -204,6 +286,109 @@ func (m *Manager) CheckForUpdates(gitURL, branch, projectName string) (bool, str
 	return currentCommit != remoteCommit, remoteCommit, nil
 }
 
+// CommitsAhead fetches a branch and counts the commits it has on top of the
+// checked out commit
+func (m *Manager) CommitsAhead(gitURL, branch, projectName string) (int, error) {
+	current, err := m.GetLatestCommit(projectName)
+	if err != nil {
+		return 0, err
+	}
+
+	remote, err := m.GetRemoteLatestCommit(gitURL, branch, projectName)
+	if err != nil {
+		return 0, err
+	}
+
+	repo, err := git.PlainOpen(m.GetRepoDir(projectName))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	commits, err := repo.Log(&git.LogOptions{From: plumbing.NewHash(remote)})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read log: %w", err)
+	}
+	defer commits.Close()
+
+	ahead := 0
+	for {
+		commit, err := commits.Next()
+		if err != nil {
+			return 0, fmt.Errorf("commit %s is not on branch %s", current, branch)
+		}
+		if commit.Hash.String() == current {
+			return ahead, nil
+		}
+		ahead++
+	}
+}
+
+// ChangedFiles returns the paths that differ between two commits of a
+// repository, which must both have been fetched
+func (m *Manager) ChangedFiles(projectName, from, to string) ([]string, error) {
+	repo, err := git.PlainOpen(m.GetRepoDir(projectName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	trees := make([]*object.Tree, 2)
+	for i, hash := range []string{from, to} {
+		commit, err := repo.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get commit %s: %w", hash, err)
+		}
+		if trees[i], err = commit.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to get tree of %s: %w", hash, err)
+		}
+	}
+
+	changes, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff commits: %w", err)
+	}
+
+	var files []string
+	for _, change := range changes {
+		// Renames touch both the old and the new path
+		if change.From.Name != "" {
+			files = append(files, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			files = append(files, change.To.Name)
+		}
+	}
+	return files, nil
+}
+
+// CommitMessage returns the message of a fetched commit
+func (m *Manager) CommitMessage(projectName, hash string) (string, error) {
+	repo, err := git.PlainOpen(m.GetRepoDir(projectName))
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return "", fmt.Errorf("failed to get commit %s: %w", hash, err)
+	}
+	return commit.Message, nil
+}
+
+// IsDetached reports whether a repository has a tag or commit checked out
+// rather than a branch
+func (m *Manager) IsDetached(projectName string) bool {
+	repo, err := git.PlainOpen(m.GetRepoDir(projectName))
+	if err != nil {
+		return false
+	}
+
+	head, err := repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return false
+	}
+	return head.Type() == plumbing.HashReference
+}
+
 // SwitchBranch switches to a different branch
 func (m *Manager) SwitchBranch(gitURL, branch, projectName string) error {
 	repoDir := m.GetRepoDir(projectName)
@@ -272,6 +457,85 @@ func isSSHURL(url string) bool {
 	return len(url) > 4 && (url[:4] == "git@" || url[:6] == "ssh://")
 }
 
+// IsCommitHash reports whether s looks like a full or abbreviated commit hash
+func IsCommitHash(s string) bool {
+	if len(s) < 7 || len(s) > 40 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListRemoteBranches returns the head commit of every branch of a remote
+// repository, keyed by branch name
+func (m *Manager) ListRemoteBranches(gitURL string) (map[string]string, error) {
+	// Get auth
+	auth, err := m.getAuth(gitURL)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := git.NewRemote(nil, &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{gitURL},
+	})
+
+	listOpts := &git.ListOptions{}
+	if auth != nil {
+		listOpts.Auth = auth
+	}
+
+	refs, err := remote.List(listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote refs: %w", err)
+	}
+
+	branches := make(map[string]string)
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			branches[ref.Name().Short()] = ref.Hash().String()
+		}
+	}
+	return branches, nil
+}
+
+// ListRemoteTags returns the names of every tag of a remote repository
+func (m *Manager) ListRemoteTags(gitURL string) ([]string, error) {
+	// Get auth
+	auth, err := m.getAuth(gitURL)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := git.NewRemote(nil, &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{gitURL},
+	})
+
+	listOpts := &git.ListOptions{}
+	if auth != nil {
+		listOpts.Auth = auth
+	}
+
+	refs, err := remote.List(listOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list remote refs: %w", err)
+	}
+
+	var tags []string
+	for _, ref := range refs {
+		// Skip the peeled commits of annotated tags
+		if ref.Name().IsTag() && !strings.HasSuffix(ref.Name().String(), "^{}") {
+			tags = append(tags, ref.Name().Short())
+		}
+	}
+	return tags, nil
+}
+
 // GetDefaultBranch detects the default branch of a remote repository
 func (m *Manager) GetDefaultBranch(gitURL string) (string, error) {
 	// Get auth
@@ -0,0 +1,137 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a parsed semantic version tag such as v1.2.3 or 1.2.3-rc.1
+type version struct {
+	parts      [3]int
+	prerelease string
+}
+
+// parseVersion parses a tag as a semantic version. The "v" prefix, minor and
+// patch are optional and build metadata is ignored.
+func parseVersion(tag string) (version, bool) {
+	var v version
+	s := strings.TrimPrefix(strings.TrimPrefix(tag, "v"), "V")
+	if i := strings.Index(s, "+"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.prerelease = s[i+1:]
+		s = s[:i]
+	}
+
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return v, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.parts[i] = n
+	}
+	return v, true
+}
+
+// less reports whether v sorts before o, ignoring prereleases
+func (v version) less(o version) bool {
+	for i := range v.parts {
+		if v.parts[i] != o.parts[i] {
+			return v.parts[i] < o.parts[i]
+		}
+	}
+	return false
+}
+
+// isWildcard reports whether a tag rule component matches any number
+func isWildcard(s string) bool {
+	return s == "x" || s == "X" || s == "*"
+}
+
+// IsTagConstraint reports whether a tag rule is a semver constraint such as
+// v1.x rather than an exact tag
+func IsTagConstraint(rule string) bool {
+	for _, f := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(rule, "v"), "V"), ".") {
+		if isWildcard(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTagRule checks a tag rule: an exact tag, or a constraint of up to
+// three components where the last ones may be x, such as v1.x or 2.3.x
+func ValidateTagRule(rule string) error {
+	if rule == "" || !IsTagConstraint(rule) {
+		return nil
+	}
+
+	fields := strings.Split(strings.TrimPrefix(strings.TrimPrefix(rule, "v"), "V"), ".")
+	if len(fields) > 3 {
+		return fmt.Errorf("tag rule %q has more than three components", rule)
+	}
+	wildcard := false
+	for _, f := range fields {
+		if isWildcard(f) {
+			wildcard = true
+			continue
+		}
+		if wildcard {
+			return fmt.Errorf("tag rule %q: only the last components can be x", rule)
+		}
+		if n, err := strconv.Atoi(f); err != nil || n < 0 {
+			return fmt.Errorf("tag rule %q: %q is not a number or x", rule, f)
+		}
+	}
+	return nil
+}
+
+// matchesConstraint reports whether a release version satisfies a tag
+// constraint. Prereleases never match.
+func matchesConstraint(rule string, v version) bool {
+	if v.prerelease != "" {
+		return false
+	}
+	fields := strings.Split(strings.TrimPrefix(strings.TrimPrefix(rule, "v"), "V"), ".")
+	for i, f := range fields {
+		if isWildcard(f) {
+			return true
+		}
+		if n, err := strconv.Atoi(f); err != nil || n != v.parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// LatestTag returns the tag a rule selects from tags: the tag itself for an
+// exact rule, or the highest release matching a constraint
+func LatestTag(rule string, tags []string) (string, bool) {
+	if !IsTagConstraint(rule) {
+		for _, tag := range tags {
+			if tag == rule {
+				return tag, true
+			}
+		}
+		return "", false
+	}
+
+	var best string
+	var bestVersion version
+	for _, tag := range tags {
+		v, ok := parseVersion(tag)
+		if !ok || !matchesConstraint(rule, v) {
+			continue
+		}
+		if best == "" || bestVersion.less(v) {
+			best, bestVersion = tag, v
+		}
+	}
+	return best, best != ""
+}
@@ -0,0 +1,60 @@
+package git
+
+import "testing"
+
+func TestValidateTagRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr bool
+	}{
+		{"", false},
+		{"v1.4.2", false},
+		{"release-2024", false},
+		{"v1.x", false},
+		{"v2.3.x", false},
+		{"1.*", false},
+		{"x", false},
+		{"v1.x.3", true},
+		{"v1.2.3.x", true},
+		{"va.x", true},
+	}
+	for _, tt := range tests {
+		err := ValidateTagRule(tt.rule)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateTagRule(%q) = %v, want error %v", tt.rule, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLatestTag(t *testing.T) {
+	tags := []string{"v1.2.0", "v1.10.0", "v1.9.3", "v1.11.0-rc.1", "v2.0.0", "v2.1", "1.3.0", "latest", "v2.1.1+build.5"}
+	tests := []struct {
+		rule   string
+		want   string
+		wantOK bool
+	}{
+		// Versions compare numerically, not as strings
+		{"v1.x", "v1.10.0", true},
+		{"v1.9.x", "v1.9.3", true},
+		{"v2.x", "v2.1.1+build.5", true},
+		{"v2.1.x", "v2.1.1+build.5", true},
+		{"x", "v2.1.1+build.5", true},
+		{"v3.x", "", false},
+		// Exact rules only match the tag itself, prereleases included
+		{"v1.11.0-rc.1", "v1.11.0-rc.1", true},
+		{"latest", "latest", true},
+		{"v1.2", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := LatestTag(tt.rule, tags)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("LatestTag(%q) = %q, %v, want %q, %v", tt.rule, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLatestTagSkipsPrereleases(t *testing.T) {
+	if got, ok := LatestTag("v1.x", []string{"v1.5.0-rc.1"}); ok {
+		t.Errorf("LatestTag matched prerelease %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+// Package jobs runs scheduled one-off commands for projects.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// ErrBusy is returned when the job is already running
+var ErrBusy = errors.New("this job is already running")
+
+// Runner runs jobs in one-off containers and records their runs
+type Runner struct {
+	dockerClient *docker.Client
+	projectRepo  *db.ProjectRepository
+	jobRepo      *db.JobRepository
+
+	// ctx is cancelled by Stop to kill running jobs
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+// NewRunner creates a new job runner
+func NewRunner(dockerClient *docker.Client, projectRepo *db.ProjectRepository, jobRepo *db.JobRepository) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		dockerClient: dockerClient,
+		projectRepo:  projectRepo,
+		jobRepo:      jobRepo,
+		ctx:          ctx,
+		cancel:       cancel,
+		running:      make(map[string]bool),
+	}
+}
+
+// IsRunning reports whether a run of the job is in progress
+func (r *Runner) IsRunning(jobID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running[jobID]
+}
+
+// Start records a new run of a job and runs it in the background. It returns
+// ErrBusy if the previous run has not finished.
+func (r *Runner) Start(job *models.Job, trigger models.DeployTrigger) (*models.JobRun, error) {
+	r.mu.Lock()
+	if r.running[job.ID] {
+		r.mu.Unlock()
+		return nil, ErrBusy
+	}
+	r.running[job.ID] = true
+	r.mu.Unlock()
+
+	run := &models.JobRun{
+		ID:        uuid.New().String(),
+		JobID:     job.ID,
+		ProjectID: job.ProjectID,
+		Trigger:   trigger,
+		Status:    models.JobRunning,
+		StartedAt: time.Now(),
+	}
+	if err := r.jobRepo.CreateRun(run); err != nil {
+		r.done(job.ID)
+		return nil, err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer r.done(job.ID)
+		r.execute(job, run)
+	}()
+
+	return run, nil
+}
+
+// done clears a job's running flag
+func (r *Runner) done(jobID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running, jobID)
+}
+
+// execute runs a job and records the outcome of the run
+func (r *Runner) execute(job *models.Job, run *models.JobRun) {
+	exitCode, output, err := r.runContainer(job)
+
+	run.ExitCode = exitCode
+	run.Output = output
+	run.FinishedAt = time.Now()
+	switch {
+	case err != nil:
+		run.Status = models.JobFailed
+		run.Error = err.Error()
+		log.Printf("Job %s failed: %v", job.Name, err)
+	case exitCode != 0:
+		run.Status = models.JobFailed
+		log.Printf("Job %s exited with code %d", job.Name, exitCode)
+	default:
+		run.Status = models.JobSuccess
+	}
+
+	if err := r.jobRepo.FinishRun(run); err != nil {
+		log.Printf("Failed to record run of job %s: %v", job.Name, err)
+	}
+}
+
+// runContainer looks up the job's project and runs the job's container
+func (r *Runner) runContainer(job *models.Job) (int, string, error) {
+	project, err := r.projectRepo.GetByID(job.ProjectID)
+	if err != nil {
+		return 0, "", err
+	}
+	if project == nil {
+		return 0, "", fmt.Errorf("project not found")
+	}
+
+	return r.dockerClient.RunJob(r.ctx, project, job)
+}
+
+// Stop kills running jobs and waits for their runs to be recorded
+func (r *Runner) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/cron"
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// CheckInterval is how often the scheduler looks for due jobs. It is well
+// under a minute so that no minute is skipped.
+const CheckInterval = 15 * time.Second
+
+// Scheduler starts jobs when their cron schedule fires
+type Scheduler struct {
+	runner   *Runner
+	jobRepo  *db.JobRepository
+	interval time.Duration
+	// last is the last minute that was checked
+	last    time.Time
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running bool
+	mu      sync.Mutex
+}
+
+// NewScheduler creates a new job scheduler
+func NewScheduler(runner *Runner, jobRepo *db.JobRepository, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		runner:   runner,
+		jobRepo:  jobRepo,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start starts the scheduler. Runs interrupted by a previous shutdown are
+// marked as failed; runs missed while SlimDeploy was down are not made up.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopCh = make(chan struct{})
+	s.last = time.Now().Truncate(time.Minute)
+	s.mu.Unlock()
+
+	if err := s.jobRepo.FailUnfinishedRuns(); err != nil {
+		log.Printf("Jobs: %v", err)
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	log.Printf("Job scheduler started with interval %v", s.interval)
+}
+
+// Stop stops the scheduler. Running jobs are stopped by Runner.Stop.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopCh)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	log.Println("Job scheduler stopped")
+}
+
+// run is the main scheduler loop
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.checkAll()
+		case <-s.stopCh:
+			return
+		}
+	}
+}
+
+// checkAll starts every job whose schedule fires in a minute that began
+// since the last check
+func (s *Scheduler) checkAll() {
+	now := time.Now().Truncate(time.Minute)
+	if !now.After(s.last) {
+		return
+	}
+	from := s.last.Add(time.Minute)
+	s.last = now
+	// After a long pause, such as a suspended host, only the current minute
+	// counts
+	if now.Sub(from) > 5*time.Minute {
+		from = now
+	}
+
+	jobs, err := s.jobRepo.List()
+	if err != nil {
+		log.Printf("Jobs: failed to list jobs: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		schedule, err := cron.Parse(job.Schedule)
+		if err != nil {
+			log.Printf("Jobs: invalid schedule of %s: %v", job.Name, err)
+			continue
+		}
+		if !firesBetween(schedule, from, now) {
+			continue
+		}
+
+		if _, err := s.runner.Start(job, models.TriggerSchedule); err == ErrBusy {
+			log.Printf("Jobs: skipping %s, the previous run has not finished", job.Name)
+		} else if err != nil {
+			log.Printf("Jobs: failed to start %s: %v", job.Name, err)
+		}
+	}
+}
+
+// firesBetween reports whether a schedule fires in any minute from from to
+// to, inclusive
+func firesBetween(schedule *cron.Schedule, from, to time.Time) bool {
+	for t := from; !t.After(to); t = t.Add(time.Minute) {
+		if schedule.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,332 @@
+// Package logs collects the output of project containers into the database
+// so it outlives the containers, which are removed on every redeploy.
+package logs
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+const (
+	// DefaultInterval is how often new containers are looked for
+	DefaultInterval = 10 * time.Second
+
+	// pruneInterval is how often retention limits are applied
+	pruneInterval = time.Hour
+	// batchSize is the number of lines written in one transaction
+	batchSize = 500
+	// flushInterval is how long lines wait before being written
+	flushInterval = time.Second
+)
+
+// Collector tails the logs of all project containers and stores them. Each
+// container resumes after its last stored line, so lines are neither lost
+// nor stored twice when SlimDeploy restarts.
+type Collector struct {
+	dockerClient *docker.Client
+	projectRepo  *db.ProjectRepository
+	logRepo      *db.LogRepository
+	interval     time.Duration
+
+	// ctx is cancelled by Stop to end all tails
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// records passes lines from the tails to the writer
+	records    chan models.LogRecord
+	writerDone chan struct{}
+
+	mu      sync.Mutex
+	running bool
+	// tails holds the containers currently being read
+	tails map[string]bool
+	// drained holds the stopped containers whose logs are fully stored
+	drained map[string]bool
+	lastErr string
+}
+
+// NewCollector creates a new log collector
+func NewCollector(dockerClient *docker.Client, projectRepo *db.ProjectRepository, logRepo *db.LogRepository, interval time.Duration) *Collector {
+	return &Collector{
+		dockerClient: dockerClient,
+		projectRepo:  projectRepo,
+		logRepo:      logRepo,
+		interval:     interval,
+		tails:        make(map[string]bool),
+		drained:      make(map[string]bool),
+	}
+}
+
+// Start starts collecting in the background
+func (c *Collector) Start() {
+	c.mu.Lock()
+	if c.running {
+		c.mu.Unlock()
+		return
+	}
+	c.running = true
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	c.records = make(chan models.LogRecord, batchSize*2)
+	c.writerDone = make(chan struct{})
+	c.mu.Unlock()
+
+	go c.write()
+
+	c.wg.Add(2)
+	go c.run()
+	go c.prune()
+
+	log.Printf("Log collector started with interval %v", c.interval)
+}
+
+// Stop stops collecting and stores the lines already read
+func (c *Collector) Stop() {
+	c.mu.Lock()
+	if !c.running {
+		c.mu.Unlock()
+		return
+	}
+	c.running = false
+	c.cancel()
+	c.mu.Unlock()
+
+	c.wg.Wait()
+	close(c.records)
+	<-c.writerDone
+	log.Println("Log collector stopped")
+}
+
+// run periodically starts tailing containers that aren't being read yet
+func (c *Collector) run() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.sync()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.sync()
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// sync starts a tail for every container with unread output. Running
+// containers are followed; stopped ones are read once to the end.
+func (c *Collector) sync() {
+	ctx, cancel := context.WithTimeout(c.ctx, c.interval)
+	sources, err := c.dockerClient.ListLogSources(ctx)
+	cancel()
+	if c.logError(err) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ctx.Err() != nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, src := range sources {
+		seen[src.ContainerID] = true
+		if c.tails[src.ContainerID] {
+			continue
+		}
+		if src.Running {
+			delete(c.drained, src.ContainerID)
+		} else if c.drained[src.ContainerID] {
+			continue
+		}
+
+		c.tails[src.ContainerID] = true
+		c.wg.Add(1)
+		go c.tail(src)
+	}
+
+	// Forget removed containers
+	for id := range c.drained {
+		if !seen[id] {
+			delete(c.drained, id)
+		}
+	}
+}
+
+// tail reads a container's logs from its last stored line until the stream
+// ends
+func (c *Collector) tail(src docker.LogSource) {
+	defer c.wg.Done()
+
+	drained := false
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.tails, src.ContainerID)
+		if drained {
+			c.drained[src.ContainerID] = true
+		}
+	}()
+
+	last, err := c.logRepo.LastTime(src.ContainerID)
+	if err != nil {
+		log.Printf("Logs: %v", err)
+		return
+	}
+
+	// A container seen for the first time is read from as far back as the
+	// default retention keeps
+	opts := docker.LogOptions{Follow: src.Running}
+	if last.IsZero() {
+		opts.Since = time.Now().Add(-models.LogRetention{}.MaxAge())
+	} else {
+		opts.Since = last
+	}
+
+	reader, err := c.dockerClient.GetContainerLogs(c.ctx, src.ContainerID, opts)
+	if err != nil {
+		if c.ctx.Err() == nil {
+			log.Printf("Logs: failed to read logs of %s: %v", src.ContainerID[:12], err)
+		}
+		return
+	}
+	defer reader.Close()
+
+	for {
+		entry, err := reader.Next()
+		if err != nil {
+			// The stream of a running container ends when it stops, which
+			// the next sync picks up
+			drained = !src.Running && err == io.EOF
+			return
+		}
+
+		// Since has second precision in older Docker versions, so lines
+		// already stored may be sent again
+		if !last.IsZero() && !entry.Time.After(last) {
+			continue
+		}
+		if entry.Time.IsZero() {
+			entry.Time = time.Now()
+		}
+
+		select {
+		case c.records <- models.LogRecord{
+			ProjectID:   src.ProjectID,
+			Service:     src.Service,
+			ContainerID: src.ContainerID,
+			Stream:      string(entry.Stream),
+			Time:        entry.Time,
+			Text:        entry.Text,
+		}:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// write stores lines in batches until the records channel is closed
+func (c *Collector) write() {
+	defer close(c.writerDone)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []models.LogRecord
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := c.logRepo.Insert(batch); err != nil {
+			log.Printf("Logs: %v", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case record, ok := <-c.records:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, record)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// prune periodically applies the retention limits of each project
+func (c *Collector) prune() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		c.pruneOnce()
+
+		select {
+		case <-ticker.C:
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// pruneOnce deletes the lines beyond each project's retention limits and
+// the lines of deleted projects
+func (c *Collector) pruneOnce() {
+	projects, err := c.projectRepo.List()
+	if err != nil {
+		log.Printf("Logs: %v", err)
+		return
+	}
+
+	for _, project := range projects {
+		before := time.Now().Add(-project.LogRetention.MaxAge())
+		n, err := c.logRepo.Prune(project.ID, before, project.LogRetention.Lines())
+		if err != nil {
+			log.Printf("Logs: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Logs: pruned %d lines of project %s", n, project.Name)
+		}
+	}
+
+	if _, err := c.logRepo.DeleteOrphaned(); err != nil {
+		log.Printf("Logs: %v", err)
+	}
+}
+
+// logError logs err when it differs from the last one, so an unreachable
+// daemon doesn't flood the log, and reports whether it is set
+func (c *Collector) logError(err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err != nil && err.Error() != c.lastErr && c.ctx.Err() == nil {
+		log.Printf("Logs: failed to list containers: %v", err)
+	}
+	c.lastErr = ""
+	if err != nil {
+		c.lastErr = err.Error()
+	}
+	return err != nil
+}
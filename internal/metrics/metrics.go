@@ -0,0 +1,79 @@
+// Package metrics exposes SlimDeploy's own metrics in the Prometheus text
+// exposition format.
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// Buckets used by the SlimDeploy histograms, in seconds
+var (
+	DeployBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+	PollBuckets   = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60}
+	HTTPBuckets   = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+var (
+	// DeploysTotal counts finished deployments
+	DeploysTotal = Default.NewCounterVec(
+		"slimdeploy_deploys_total",
+		"Total deployments by project, outcome and trigger.",
+		"project", "outcome", "trigger",
+	)
+
+	// DeployDuration measures how long deployments take
+	DeployDuration = Default.NewHistogramVec(
+		"slimdeploy_deploy_duration_seconds",
+		"Deployment duration in seconds by project and outcome.",
+		DeployBuckets,
+		"project", "outcome",
+	)
+
+	// WatcherPollDuration measures one pass of the git watcher over all projects
+	WatcherPollDuration = Default.NewHistogramVec(
+		"slimdeploy_watcher_poll_duration_seconds",
+		"Duration of a git watcher poll over all auto-deploy projects in seconds.",
+		PollBuckets,
+	)
+
+	// GitFetchErrors counts failed fetches and pulls in the watcher
+	GitFetchErrors = Default.NewCounterVec(
+		"slimdeploy_git_fetch_errors_total",
+		"Total git fetch and pull errors in the watcher by project.",
+		"project",
+	)
+
+	// HTTPRequestDuration measures HTTP request latency
+	HTTPRequestDuration = Default.NewHistogramVec(
+		"slimdeploy_http_request_duration_seconds",
+		"HTTP request latency in seconds by method, route and status code.",
+		HTTPBuckets,
+		"method", "route", "code",
+	)
+
+	// Projects is the number of projects in each status, set on every scrape
+	Projects = Default.NewGaugeVec(
+		"slimdeploy_projects",
+		"Number of projects by status.",
+		"status",
+	)
+)
+
+// ObserveDeploy records a finished deployment
+func ObserveDeploy(project, trigger string, err error, duration time.Duration) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	DeploysTotal.Inc(project, outcome, trigger)
+	DeployDuration.Observe(duration.Seconds(), project, outcome)
+}
+
+// ObserveHTTPRequest records a served HTTP request
+func ObserveHTTPRequest(method, route string, code int, duration time.Duration) {
+	HTTPRequestDuration.Observe(duration.Seconds(), method, route, strconv.Itoa(code))
+}
@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+// output writes a registry and returns the result
+func output(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestRegistryWrite(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *Registry)
+		want  string
+	}{
+		{
+			name: "counter",
+			setup: func(r *Registry) {
+				c := r.NewCounterVec("deploys_total", "Deploys.", "project", "status")
+				c.Inc("web", "success")
+				c.Add(2.5, "api", "failure")
+				c.Inc("web", "success")
+			},
+			want: `# HELP deploys_total Deploys.
+# TYPE deploys_total counter
+deploys_total{project="api",status="failure"} 2.5
+deploys_total{project="web",status="success"} 2
+`,
+		},
+		{
+			name: "gauge without labels",
+			setup: func(r *Registry) {
+				g := r.NewGaugeVec("up", "Whether it is up.")
+				g.Set(1)
+			},
+			want: `# HELP up Whether it is up.
+# TYPE up gauge
+up 1
+`,
+		},
+		{
+			name: "gauge reset",
+			setup: func(r *Registry) {
+				g := r.NewGaugeVec("projects", "Projects.", "status")
+				g.Set(3, "running")
+				g.Reset()
+				g.Set(1, "stopped")
+			},
+			want: `# HELP projects Projects.
+# TYPE projects gauge
+projects{status="stopped"} 1
+`,
+		},
+		{
+			name: "histogram",
+			setup: func(r *Registry) {
+				h := r.NewHistogramVec("deploy_seconds", "Deploy duration.", []float64{1, 10}, "project")
+				h.Observe(0.5, "web")
+				h.Observe(1, "web")
+				h.Observe(5, "web")
+				h.Observe(60, "web")
+			},
+			want: `# HELP deploy_seconds Deploy duration.
+# TYPE deploy_seconds histogram
+deploy_seconds_bucket{project="web",le="1"} 2
+deploy_seconds_bucket{project="web",le="10"} 3
+deploy_seconds_bucket{project="web",le="+Inf"} 4
+deploy_seconds_sum{project="web"} 66.5
+deploy_seconds_count{project="web"} 4
+`,
+		},
+		{
+			name: "escaping",
+			setup: func(r *Registry) {
+				c := r.NewCounterVec("events_total", "Events with a \\ and\na newline.", "name")
+				c.Inc("a \"quoted\" \\ value\nwith a newline")
+			},
+			want: `# HELP events_total Events with a \\ and\na newline.
+# TYPE events_total counter
+events_total{name="a \"quoted\" \\ value\nwith a newline"} 1
+`,
+		},
+		{
+			name: "families in registration order, series sorted",
+			setup: func(r *Registry) {
+				b := r.NewGaugeVec("b", "B.", "x")
+				a := r.NewGaugeVec("a", "A.")
+				b.Set(1, "z")
+				b.Set(2, "m")
+				b.Set(3, "a")
+				a.Set(4)
+			},
+			want: `# HELP b B.
+# TYPE b gauge
+b{x="a"} 3
+b{x="m"} 2
+b{x="z"} 1
+# HELP a A.
+# TYPE a gauge
+a 4
+`,
+		},
+		{
+			name: "special values",
+			setup: func(r *Registry) {
+				g := r.NewGaugeVec("g", "G.", "v")
+				g.Set(1e21, "big")
+				g.Set(0.000001, "small")
+			},
+			want: `# HELP g G.
+# TYPE g gauge
+g{v="big"} 1e+21
+g{v="small"} 1e-06
+`,
+		},
+	}
+	for _, tt := range tests {
+		r := NewRegistry()
+		tt.setup(r)
+		if got := output(t, r); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRegistryWriteIsStable(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("c", "C.", "a", "b")
+	for _, v := range []string{"q", "w", "e", "r", "t", "y"} {
+		c.Inc(v, v)
+	}
+	first := output(t, r)
+	for i := 0; i < 10; i++ {
+		if got := output(t, r); got != first {
+			t.Fatalf("output changed between writes:\n%s\nthen\n%s", first, got)
+		}
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc with the wrong number of label values didn't panic")
+		}
+	}()
+	NewRegistry().NewCounterVec("c", "C.", "a").Inc()
+}
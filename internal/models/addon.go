@@ -0,0 +1,175 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// AddonType is the kind of service an add-on runs
+type AddonType string
+
+const (
+	AddonPostgres AddonType = "postgres"
+	AddonMySQL    AddonType = "mysql"
+	AddonRedis    AddonType = "redis"
+)
+
+// AddonSpec describes how to run an add-on type
+type AddonSpec struct {
+	Type           AddonType
+	Label          string
+	Image          string
+	DefaultVersion string
+	Port           int
+	DataPath       string
+	// EnvVar is the variable linked projects get the connection URL in
+	EnvVar string
+}
+
+// AddonSpecs lists every supported add-on type
+var AddonSpecs = []AddonSpec{
+	{AddonPostgres, "PostgreSQL", "postgres", "16", 5432, "/var/lib/postgresql/data", "DATABASE_URL"},
+	{AddonMySQL, "MySQL", "mysql", "8.4", 3306, "/var/lib/mysql", "DATABASE_URL"},
+	{AddonRedis, "Redis", "redis", "7", 6379, "/data", "REDIS_URL"},
+}
+
+// GetAddonSpec returns the spec of an add-on type
+func GetAddonSpec(t AddonType) (AddonSpec, bool) {
+	for _, spec := range AddonSpecs {
+		if spec.Type == t {
+			return spec, true
+		}
+	}
+	return AddonSpec{}, false
+}
+
+var (
+	// addonNamePattern keeps add-on names usable as container and host names
+	addonNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,39}$`)
+	// imageTagPattern matches valid Docker image tags
+	imageTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+	// envVarPattern matches valid environment variable names
+	envVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Addon is a managed service container, such as a database, that projects
+// can be linked to
+type Addon struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Type        AddonType     `json:"type"`
+	Version     string        `json:"version"`
+	Username    string        `json:"username"`
+	Password    string        `json:"-"`
+	Database    string        `json:"database"`
+	Status      ProjectStatus `json:"status"`
+	StatusMsg   string        `json:"status_msg"`
+	ContainerID string        `json:"container_id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+}
+
+// Validate checks the add-on's name, type and version
+func (a *Addon) Validate() error {
+	if !addonNamePattern.MatchString(a.Name) {
+		return fmt.Errorf("add-on name must be 1-40 lowercase letters, digits or dashes")
+	}
+	if _, ok := GetAddonSpec(a.Type); !ok {
+		return fmt.Errorf("unknown add-on type %q", a.Type)
+	}
+	if !imageTagPattern.MatchString(a.Version) {
+		return fmt.Errorf("invalid version %q", a.Version)
+	}
+	return nil
+}
+
+// Spec returns the spec of the add-on's type
+func (a *Addon) Spec() AddonSpec {
+	spec, _ := GetAddonSpec(a.Type)
+	return spec
+}
+
+// Image returns the Docker image the add-on runs
+func (a *Addon) Image() string {
+	return a.Spec().Image + ":" + a.Version
+}
+
+// Host returns the add-on's host name on the SlimDeploy network
+func (a *Addon) Host() string {
+	return "slimdeploy-addon-" + a.Name
+}
+
+// VolumeName returns the name of the add-on's data volume
+func (a *Addon) VolumeName() string {
+	return a.Host() + "-data"
+}
+
+// ConnectionURL returns the URL linked projects use to connect
+func (a *Addon) ConnectionURL() string {
+	host := fmt.Sprintf("%s:%d", a.Host(), a.Spec().Port)
+	switch a.Type {
+	case AddonPostgres:
+		u := url.URL{Scheme: "postgres", User: url.UserPassword(a.Username, a.Password), Host: host, Path: "/" + a.Database, RawQuery: "sslmode=disable"}
+		return u.String()
+	case AddonMySQL:
+		u := url.URL{Scheme: "mysql", User: url.UserPassword(a.Username, a.Password), Host: host, Path: "/" + a.Database}
+		return u.String()
+	case AddonRedis:
+		u := url.URL{Scheme: "redis", User: url.UserPassword("", a.Password), Host: host, Path: "/0"}
+		return u.String()
+	}
+	return ""
+}
+
+// ContainerEnv returns the environment that provisions the add-on's credentials
+func (a *Addon) ContainerEnv() []string {
+	switch a.Type {
+	case AddonPostgres:
+		return []string{
+			"POSTGRES_USER=" + a.Username,
+			"POSTGRES_PASSWORD=" + a.Password,
+			"POSTGRES_DB=" + a.Database,
+		}
+	case AddonMySQL:
+		return []string{
+			"MYSQL_USER=" + a.Username,
+			"MYSQL_PASSWORD=" + a.Password,
+			"MYSQL_DATABASE=" + a.Database,
+			"MYSQL_ROOT_PASSWORD=" + a.Password,
+		}
+	}
+	return nil
+}
+
+// ContainerCmd returns the command override for the add-on's container, if any
+func (a *Addon) ContainerCmd() []string {
+	if a.Type == AddonRedis {
+		return []string{"redis-server", "--appendonly", "yes", "--requirepass", a.Password}
+	}
+	return nil
+}
+
+// AddonLink connects a project to an add-on. The add-on's connection URL is
+// injected into the project's environment as EnvVar at deploy time.
+type AddonLink struct {
+	ProjectID string `json:"project_id"`
+	AddonID   string `json:"addon_id"`
+	EnvVar    string `json:"env_var"`
+}
+
+// ValidateAddonLinks checks env var names and rejects duplicates
+func ValidateAddonLinks(links []AddonLink) error {
+	seen := make(map[string]bool)
+	for _, link := range links {
+		if !envVarPattern.MatchString(link.EnvVar) {
+			return fmt.Errorf("invalid environment variable name %q", link.EnvVar)
+		}
+		if seen[link.EnvVar] {
+			return fmt.Errorf("environment variable %s is used by more than one add-on", link.EnvVar)
+		}
+		seen[link.EnvVar] = true
+	}
+	return nil
+}
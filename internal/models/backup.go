@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// BackupPolicy schedules backups of a project's volumes
+type BackupPolicy struct {
+	Enabled       bool `json:"enabled"`
+	IntervalHours int  `json:"interval_hours,omitempty"`
+	Keep          int  `json:"keep,omitempty"`
+}
+
+// Interval returns the time between scheduled backups
+func (p BackupPolicy) Interval() time.Duration {
+	return time.Duration(p.IntervalHours) * time.Hour
+}
+
+// Validate checks that an enabled policy has a schedule and retention
+func (p BackupPolicy) Validate() error {
+	if !p.Enabled {
+		return nil
+	}
+	if p.IntervalHours < 1 {
+		return fmt.Errorf("backup interval must be at least 1 hour")
+	}
+	if p.Keep < 1 {
+		return fmt.Errorf("at least 1 backup must be kept")
+	}
+	return nil
+}
+
+// BackupStatus is the outcome of a volume backup
+type BackupStatus string
+
+const (
+	BackupSuccess BackupStatus = "success"
+	BackupFailed  BackupStatus = "failed"
+)
+
+// Backup records one archive of a project volume in object storage
+type Backup struct {
+	ID        string       `json:"id"`
+	ProjectID string       `json:"project_id"`
+	Volume    string       `json:"volume"`
+	ObjectKey string       `json:"object_key"`
+	Size      uint64       `json:"size"`
+	Status    BackupStatus `json:"status"`
+	Error     string       `json:"error,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// BackupPolicyJSON returns the backup policy as JSON string for database storage
+func (p *Project) BackupPolicyJSON() string {
+	data, err := json.Marshal(p.Backup)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ParseBackupPolicy parses a JSON string into the Backup field
+func (p *Project) ParseBackupPolicy(data string) error {
+	p.Backup = BackupPolicy{}
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.Backup)
+}
+
+// VolumeNames returns the names of the project's named volumes
+func (p *Project) VolumeNames() []string {
+	var names []string
+	for _, m := range p.Mounts {
+		if m.Type == MountTypeVolume {
+			names = append(names, m.Source)
+		}
+	}
+	return names
+}
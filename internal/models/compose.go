@@ -0,0 +1,203 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ComposeConfig controls where a compose project's files live in its
+// repository and how docker compose runs them
+type ComposeConfig struct {
+	// Dir is the subdirectory of the repository compose runs in
+	Dir string `json:"dir,omitempty"`
+	// File is the compose file relative to Dir; empty looks for one of the
+	// usual names. Relative paths in the compose files resolve against the
+	// directory of this file.
+	File string `json:"file,omitempty"`
+	// Overrides are files merged over File in order, such as
+	// compose.prod.yml
+	Overrides []string `json:"overrides,omitempty"`
+	// Profiles are the compose profiles to enable
+	Profiles []string `json:"profiles,omitempty"`
+	// Services are the services Traefik routes traffic to. The first is the
+	// main service; without any, one is guessed from the service names and
+	// served on the project's domain.
+	Services []RoutedService `json:"services,omitempty"`
+	// MergeTraefikLabels keeps the Traefik labels of the compose file, such
+	// as middlewares and TCP routers, instead of replacing them with
+	// SlimDeploy's
+	MergeTraefikLabels bool `json:"merge_traefik_labels,omitempty"`
+}
+
+// RoutedService is a compose service exposed through Traefik
+type RoutedService struct {
+	Name string `json:"name"`
+	// Domain is a custom domain, and Subdomain a name under the base
+	// domain; with neither the service is served on the project's domain
+	Domain    string `json:"domain,omitempty"`
+	Subdomain string `json:"subdomain,omitempty"`
+	// Port is the container port; 0 uses the project's port
+	Port int `json:"port,omitempty"`
+	// PathPrefix limits the service to requests under a path, which is
+	// passed on unchanged
+	PathPrefix string `json:"path_prefix,omitempty"`
+}
+
+// ParseRoutedService parses a routed service as "name [host][:port][/path]",
+// where a host without a dot is a subdomain of the base domain, e.g.
+// "api api.example.com:8080" or "admin :9000/admin"
+func ParseRoutedService(spec string) (RoutedService, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 2 {
+		return RoutedService{}, fmt.Errorf("invalid service %q, expected name [host][:port][/path]", spec)
+	}
+
+	s := RoutedService{Name: fields[0]}
+	if len(fields) == 2 {
+		target := fields[1]
+		if i := strings.Index(target, "/"); i >= 0 {
+			s.PathPrefix = target[i:]
+			target = target[:i]
+		}
+		if i := strings.LastIndex(target, ":"); i >= 0 {
+			port, err := strconv.Atoi(target[i+1:])
+			if err != nil {
+				return RoutedService{}, fmt.Errorf("invalid port in service %q", spec)
+			}
+			s.Port = port
+			target = target[:i]
+		}
+		if strings.Contains(target, ".") {
+			s.Domain = target
+		} else {
+			s.Subdomain = target
+		}
+	}
+
+	return s, s.Validate()
+}
+
+// String formats the service in the syntax ParseRoutedService reads
+func (s RoutedService) String() string {
+	target := s.Domain
+	if target == "" {
+		target = s.Subdomain
+	}
+	if s.Port > 0 {
+		target += ":" + strconv.Itoa(s.Port)
+	}
+	target += s.PathPrefix
+	if target == "" {
+		return s.Name
+	}
+	return s.Name + " " + target
+}
+
+// Validate checks the service's name, port and path prefix
+func (s RoutedService) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("routed service needs a name")
+	}
+	if s.Domain != "" && s.Subdomain != "" {
+		return fmt.Errorf("service %s can't have both a domain and a subdomain", s.Name)
+	}
+	if strings.ContainsAny(s.Domain+s.Subdomain, " /:`") {
+		return fmt.Errorf("invalid domain for service %s", s.Name)
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return fmt.Errorf("invalid port %d for service %s", s.Port, s.Name)
+	}
+	if s.PathPrefix != "" && (!strings.HasPrefix(s.PathPrefix, "/") || strings.Contains(s.PathPrefix, "`")) {
+		return fmt.Errorf("invalid path prefix %q for service %s", s.PathPrefix, s.Name)
+	}
+	return nil
+}
+
+// EffectiveDomain returns the domain the service is served on, or "" if it
+// has none
+func (s RoutedService) EffectiveDomain(project *Project, baseDomain string) string {
+	if s.Domain != "" {
+		return s.Domain
+	}
+	if s.Subdomain != "" {
+		if baseDomain == "" {
+			return ""
+		}
+		return s.Subdomain + "." + baseDomain
+	}
+	return project.GetEffectiveDomain(baseDomain)
+}
+
+// EffectivePort returns the container port traffic is sent to
+func (s RoutedService) EffectivePort(project *Project) int {
+	if s.Port > 0 {
+		return s.Port
+	}
+	if project.Port > 0 {
+		return project.Port
+	}
+	return 80
+}
+
+// Validate checks that every path stays inside the repository and the
+// routed services
+func (c ComposeConfig) Validate() error {
+	paths := append([]string{c.Dir, c.File}, c.Overrides...)
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if path.IsAbs(p) {
+			return fmt.Errorf("compose path %q must be relative to the repository", p)
+		}
+		if clean := path.Clean(p); clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("compose path %q must stay inside the repository", p)
+		}
+	}
+	for _, profile := range c.Profiles {
+		if strings.ContainsAny(profile, " \t,") {
+			return fmt.Errorf("invalid compose profile %q", profile)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range c.Services {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+		if seen[s.Name] {
+			return fmt.Errorf("service %s is routed twice", s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return nil
+}
+
+// MainService returns the first routed service, or "" if none are declared
+func (c ComposeConfig) MainService() string {
+	if len(c.Services) == 0 {
+		return ""
+	}
+	return c.Services[0].Name
+}
+
+// ComposeJSON returns the compose config as JSON string for database storage
+func (p *Project) ComposeJSON() string {
+	data, err := json.Marshal(p.Compose)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ParseCompose parses a JSON string into the Compose field
+func (p *Project) ParseCompose(data string) error {
+	p.Compose = ComposeConfig{}
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.Compose)
+}
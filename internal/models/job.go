@@ -0,0 +1,101 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/cron"
+)
+
+const (
+	// DefaultJobTimeout is the timeout of jobs that don't set one
+	DefaultJobTimeout = time.Hour
+	// JobRunHistory is how many runs are kept per job
+	JobRunHistory = 50
+	// JobOutputLimit is how much output is kept per run, from the end
+	JobOutputLimit = 64 * 1024
+)
+
+// Job is a command run on a cron schedule in a one-off container from a
+// project's current image
+type Job struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	Name      string `json:"name"`
+	Schedule  string `json:"schedule"`
+	Command   string `json:"command"`
+	// Service selects the compose service whose image and environment the
+	// job uses; empty uses the first one
+	Service        string    `json:"service,omitempty"`
+	TimeoutSeconds int       `json:"timeout_seconds"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Validate checks the job's name, schedule, command and timeout
+func (j *Job) Validate() error {
+	if j.Name == "" || len(j.Name) > 64 {
+		return fmt.Errorf("job name must be 1-64 characters")
+	}
+	if _, err := cron.Parse(j.Schedule); err != nil {
+		return fmt.Errorf("invalid schedule: %w", err)
+	}
+	if j.Command == "" {
+		return fmt.Errorf("job command is required")
+	}
+	if j.TimeoutSeconds < 0 {
+		return fmt.Errorf("job timeout cannot be negative")
+	}
+	return nil
+}
+
+// Timeout returns how long a run may take before it is killed
+func (j *Job) Timeout() time.Duration {
+	if j.TimeoutSeconds <= 0 {
+		return DefaultJobTimeout
+	}
+	return time.Duration(j.TimeoutSeconds) * time.Second
+}
+
+// NextRun returns when the job is next scheduled after t, or the zero time
+// if its schedule is invalid or never fires
+func (j *Job) NextRun(t time.Time) time.Time {
+	s, err := cron.Parse(j.Schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	return s.Next(t)
+}
+
+// JobRunStatus is the state of a job run
+type JobRunStatus string
+
+const (
+	JobRunning JobRunStatus = "running"
+	JobSuccess JobRunStatus = "success"
+	JobFailed  JobRunStatus = "failed"
+)
+
+// JobRun records one execution of a job
+type JobRun struct {
+	ID        string        `json:"id"`
+	JobID     string        `json:"job_id"`
+	ProjectID string        `json:"project_id"`
+	Trigger   DeployTrigger `json:"trigger"`
+	Status    JobRunStatus  `json:"status"`
+	ExitCode  int           `json:"exit_code"`
+	Output    string        `json:"output"`
+	// Error explains runs that failed without an exit code, such as a
+	// timeout or a missing image
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Duration returns how long the run took, or has been running
+func (r *JobRun) Duration() time.Duration {
+	if r.FinishedAt.IsZero() {
+		return time.Since(r.StartedAt).Round(time.Second)
+	}
+	return r.FinishedAt.Sub(r.StartedAt).Round(time.Second)
+}
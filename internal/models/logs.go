@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Log retention used when a project doesn't set its own
+const (
+	DefaultLogRetentionDays = 7
+	DefaultLogMaxLines      = 100000
+)
+
+// LogRetention limits how much collected log history a project keeps
+type LogRetention struct {
+	// Days is how long lines are kept; 0 uses DefaultLogRetentionDays
+	Days int `json:"days,omitempty"`
+	// MaxLines caps the number of lines kept; 0 uses DefaultLogMaxLines
+	MaxLines int `json:"max_lines,omitempty"`
+}
+
+// Validate checks that the limits are not negative
+func (r LogRetention) Validate() error {
+	if r.Days < 0 {
+		return fmt.Errorf("log retention days cannot be negative")
+	}
+	if r.MaxLines < 0 {
+		return fmt.Errorf("log retention lines cannot be negative")
+	}
+	return nil
+}
+
+// KeepDays returns the number of days lines are kept
+func (r LogRetention) KeepDays() int {
+	if r.Days == 0 {
+		return DefaultLogRetentionDays
+	}
+	return r.Days
+}
+
+// MaxAge returns how long lines are kept
+func (r LogRetention) MaxAge() time.Duration {
+	return time.Duration(r.KeepDays()) * 24 * time.Hour
+}
+
+// Lines returns the maximum number of lines kept
+func (r LogRetention) Lines() int {
+	if r.MaxLines == 0 {
+		return DefaultLogMaxLines
+	}
+	return r.MaxLines
+}
+
+// LogRetentionJSON returns the log retention as JSON string for database
+// storage
+func (p *Project) LogRetentionJSON() string {
+	data, err := json.Marshal(p.LogRetention)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ParseLogRetention parses a JSON string into the LogRetention field
+func (p *Project) ParseLogRetention(data string) error {
+	p.LogRetention = LogRetention{}
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.LogRetention)
+}
+
+// LogRecord is a collected line of a project container's output
+type LogRecord struct {
+	ID          int64     `json:"id"`
+	ProjectID   string    `json:"project_id"`
+	Service     string    `json:"service"`
+	ContainerID string    `json:"container_id"`
+	Stream      string    `json:"stream"`
+	Time        time.Time `json:"time"`
+	Text        string    `json:"text"`
+}
+
+// LogQuery selects collected log lines of a project
+type LogQuery struct {
+	ProjectID string
+	// Text is a full-text query; words must all appear, "quoted phrases"
+	// must appear as written, and OR and NOT combine terms
+	Text     string
+	Services []string
+	// Stream is stdout or stderr; empty means both
+	Stream string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
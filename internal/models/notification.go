@@ -0,0 +1,199 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ChannelType is the kind of service a notification channel delivers to
+type ChannelType string
+
+const (
+	ChannelSlack   ChannelType = "slack"
+	ChannelDiscord ChannelType = "discord"
+	ChannelWebhook ChannelType = "webhook"
+	ChannelEmail   ChannelType = "email"
+)
+
+// ChannelTypeOption is a channel type with its label
+type ChannelTypeOption struct {
+	Type  ChannelType
+	Label string
+}
+
+// ChannelTypes lists every channel type, in form order
+var ChannelTypes = []ChannelTypeOption{
+	{ChannelSlack, "Slack"},
+	{ChannelDiscord, "Discord"},
+	{ChannelWebhook, "Webhook"},
+	{ChannelEmail, "Email"},
+}
+
+// EventType is something that happened to a project that channels can be
+// notified about
+type EventType string
+
+const (
+	EventDeployStarted    EventType = "deploy.started"
+	EventDeploySucceeded  EventType = "deploy.succeeded"
+	EventDeployFailed     EventType = "deploy.failed"
+	EventContainerCrashed EventType = "container.crashed"
+
+	// EventTest is sent by the test button of a channel
+	EventTest EventType = "test"
+)
+
+// EventTypeOption is an event type with its label
+type EventTypeOption struct {
+	Type  EventType
+	Label string
+}
+
+// EventTypes lists every event type projects can subscribe to, in form order
+var EventTypes = []EventTypeOption{
+	{EventDeployStarted, "Deploy started"},
+	{EventDeploySucceeded, "Deploy succeeded"},
+	{EventDeployFailed, "Deploy failed"},
+	{EventContainerCrashed, "Container crashed"},
+}
+
+// ValidEventType reports whether t is a known event type
+func ValidEventType(t EventType) bool {
+	for _, e := range EventTypes {
+		if e.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationChannel is a destination for notifications
+type NotificationChannel struct {
+	ID   string      `json:"id"`
+	Name string      `json:"name"`
+	Type ChannelType `json:"type"`
+	// URL is the incoming webhook URL of Slack, Discord and webhook channels
+	URL string `json:"url"`
+	// Secret signs webhook payloads with HMAC-SHA256
+	Secret string `json:"-"`
+	// EmailTo is a comma-separated list of recipients of email channels
+	EmailTo   string    `json:"email_to"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate checks the channel's name and the destination of its type
+func (c *NotificationChannel) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return fmt.Errorf("channel name is required")
+	}
+
+	switch c.Type {
+	case ChannelSlack, ChannelDiscord, ChannelWebhook:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook URL must be an http(s) URL")
+		}
+	case ChannelEmail:
+		if len(c.Recipients()) == 0 {
+			return fmt.Errorf("at least one email recipient is required")
+		}
+		for _, to := range c.Recipients() {
+			if _, err := mail.ParseAddress(to); err != nil {
+				return fmt.Errorf("invalid email address %q", to)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown channel type %q", c.Type)
+	}
+	return nil
+}
+
+// Recipients returns the email addresses of an email channel
+func (c *NotificationChannel) Recipients() []string {
+	var recipients []string
+	for _, to := range strings.Split(c.EmailTo, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			recipients = append(recipients, to)
+		}
+	}
+	return recipients
+}
+
+// Destination returns where the channel delivers to, for display. Webhook
+// URLs are cut to their host since the path usually holds a token.
+func (c *NotificationChannel) Destination() string {
+	if c.Type == ChannelEmail {
+		return strings.Join(c.Recipients(), ", ")
+	}
+	if u, err := url.Parse(c.URL); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// NotificationSubscription subscribes a channel to events of a project
+type NotificationSubscription struct {
+	ProjectID string      `json:"project_id"`
+	ChannelID string      `json:"channel_id"`
+	Events    []EventType `json:"events"`
+}
+
+// Has reports whether the subscription includes an event type
+func (s NotificationSubscription) Has(t EventType) bool {
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is a notification about a project
+type Event struct {
+	Type        EventType     `json:"event"`
+	ProjectID   string        `json:"project_id"`
+	ProjectName string        `json:"project"`
+	Message     string        `json:"message"`
+	Trigger     DeployTrigger `json:"trigger,omitempty"`
+	Commit      string        `json:"commit,omitempty"`
+	Time        time.Time     `json:"time"`
+}
+
+// Title returns a one-line summary of the event
+func (e Event) Title() string {
+	switch e.Type {
+	case EventTest:
+		return "Test notification from SlimDeploy"
+	case EventDeployStarted:
+		return fmt.Sprintf("Deploying %s", e.ProjectName)
+	case EventDeploySucceeded:
+		return fmt.Sprintf("Deployed %s", e.ProjectName)
+	case EventDeployFailed:
+		return fmt.Sprintf("Deploy of %s failed", e.ProjectName)
+	case EventContainerCrashed:
+		return fmt.Sprintf("Container of %s crashed", e.ProjectName)
+	}
+	return fmt.Sprintf("%s: %s", e.ProjectName, e.Type)
+}
+
+// Text returns the event as plain text, its title followed by the details
+func (e Event) Text() string {
+	text := e.Title()
+	if e.Trigger != "" {
+		text += fmt.Sprintf(" (%s)", e.Trigger)
+	}
+	if e.Commit != "" {
+		commit := e.Commit
+		if len(commit) > 7 {
+			commit = commit[:7]
+		}
+		text += " at " + commit
+	}
+	if e.Message != "" {
+		text += "\n" + e.Message
+	}
+	return text
+}
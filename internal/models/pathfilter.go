@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// SkipDeployMarker in a commit message keeps the watcher and push webhooks
+// from deploying that commit
+const SkipDeployMarker = "[skip deploy]"
+
+// PathFilter limits automatic deploys to commits touching certain paths of
+// the repository, for projects living in a monorepo. Patterns are globs
+// relative to the repository root where ** matches any number of
+// directories; a pattern also matches everything below a directory it names.
+type PathFilter struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Enabled reports whether the filter restricts deploys at all
+func (f PathFilter) Enabled() bool {
+	return len(f.Include) > 0 || len(f.Exclude) > 0
+}
+
+// Validate checks that every pattern is a valid glob
+func (f PathFilter) Validate() error {
+	for _, pattern := range append(append([]string{}, f.Include...), f.Exclude...) {
+		if strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("path pattern %q must be relative to the repository root", pattern)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a change to files should be deployed: at least
+// one file must match an include pattern (or any file, without them) and
+// not match an exclude pattern
+func (f PathFilter) Matches(files []string) bool {
+	for _, file := range files {
+		if len(f.Include) > 0 && !matchAny(f.Include, file) {
+			continue
+		}
+		if matchAny(f.Exclude, file) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// SkipsDeploy reports whether a commit message asks not to be deployed
+func SkipsDeploy(message string) bool {
+	return strings.Contains(strings.ToLower(message), SkipDeployMarker)
+}
+
+// matchAny reports whether file or one of its parent directories matches
+// any of the patterns
+func matchAny(patterns []string, file string) bool {
+	parts := strings.Split(file, "/")
+	for _, pattern := range patterns {
+		glob := strings.Split(strings.Trim(pattern, "/"), "/")
+		for n := len(parts); n > 0; n-- {
+			if matchParts(glob, parts[:n]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchParts matches path components against glob components, where a **
+// component matches zero or more path components
+func matchParts(glob, parts []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := 0; i <= len(parts); i++ {
+				if matchParts(glob[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], parts[0]); !ok {
+			return false
+		}
+		glob, parts = glob[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// PathFilterJSON returns the path filter as JSON string for database storage
+func (p *Project) PathFilterJSON() string {
+	data, err := json.Marshal(p.PathFilter)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ParsePathFilter parses a JSON string into the PathFilter field
+func (p *Project) ParsePathFilter(data string) error {
+	p.PathFilter = PathFilter{}
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.PathFilter)
+}
@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestPathFilterMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter PathFilter
+		files  []string
+		want   bool
+	}{
+		{"no patterns", PathFilter{}, []string{"README.md"}, true},
+		{"directory", PathFilter{Include: []string{"services/api"}}, []string{"services/api/main.go"}, true},
+		{"directory with slash", PathFilter{Include: []string{"services/api/"}}, []string{"services/api/cmd/main.go"}, true},
+		{"other directory", PathFilter{Include: []string{"services/api"}}, []string{"services/web/main.go", "services/apiv2/main.go"}, false},
+		{"glob", PathFilter{Include: []string{"*.go"}}, []string{"main.go"}, true},
+		{"glob is one level", PathFilter{Include: []string{"*.go"}}, []string{"cmd/main.go"}, false},
+		{"double star", PathFilter{Include: []string{"libs/**/*.go"}}, []string{"libs/a/b/c.go"}, true},
+		{"double star matches no directories", PathFilter{Include: []string{"libs/**/*.go"}}, []string{"libs/c.go"}, true},
+		{"leading double star", PathFilter{Include: []string{"**/Dockerfile"}}, []string{"deploy/api/Dockerfile"}, true},
+		{"exclude", PathFilter{Exclude: []string{"**/*.md"}}, []string{"docs/intro.md", "README.md"}, false},
+		{"exclude some", PathFilter{Exclude: []string{"**/*.md"}}, []string{"README.md", "main.go"}, true},
+		{"include and exclude", PathFilter{Include: []string{"services/api"}, Exclude: []string{"**/*_test.go"}}, []string{"services/api/main_test.go"}, false},
+		{"no files", PathFilter{Include: []string{"services/api"}}, nil, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Matches(tt.files); got != tt.want {
+			t.Errorf("%s: Matches(%v) = %v, want %v", tt.name, tt.files, got, tt.want)
+		}
+	}
+}
+
+func TestPathFilterValidate(t *testing.T) {
+	tests := []struct {
+		filter  PathFilter
+		wantErr bool
+	}{
+		{PathFilter{Include: []string{"services/**", "*.go"}}, false},
+		{PathFilter{Include: []string{"/services"}}, true},
+		{PathFilter{Exclude: []string{"[a-"}}, true},
+	}
+	for _, tt := range tests {
+		err := tt.filter.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() = %v, want error %v", tt.filter, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSkipsDeploy(t *testing.T) {
+	tests := []struct {
+		message string
+		want    bool
+	}{
+		{"Fix typo [skip deploy]", true},
+		{"Fix typo\n\n[Skip Deploy]", true},
+		{"Fix typo [skip ci]", false},
+	}
+	for _, tt := range tests {
+		if got := SkipsDeploy(tt.message); got != tt.want {
+			t.Errorf("SkipsDeploy(%q) = %v, want %v", tt.message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// previewLabelMax is the longest branch label used in preview domains, the
+// DNS limit for a single label
+const previewLabelMax = 63
+
+// previewHashLen is the length of the branch hash PreviewLabel appends
+const previewHashLen = 6
+
+// PreviewConfig controls throwaway deployments of a project's other branches
+type PreviewConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// TTLHours tears a preview down this long after its last push; 0 keeps
+	// it until the branch is deleted
+	TTLHours int `json:"ttl_hours,omitempty"`
+	// KnownBranches are the branch heads when previews were enabled, keyed
+	// by branch. These branches only get a preview once they move on.
+	KnownBranches map[string]string `json:"known_branches,omitempty"`
+}
+
+// Validate checks the preview TTL
+func (c PreviewConfig) Validate() error {
+	if c.TTLHours < 0 {
+		return fmt.Errorf("preview TTL cannot be negative")
+	}
+	return nil
+}
+
+// TTL returns how long a preview lives after its last push, or 0 if it
+// doesn't expire
+func (c PreviewConfig) TTL() time.Duration {
+	return time.Duration(c.TTLHours) * time.Hour
+}
+
+// IsPreview reports whether the project is a preview of another project's
+// branch
+func (p *Project) IsPreview() bool {
+	return p.PreviewOf != ""
+}
+
+// PreviewLabel turns a branch name into a DNS label, e.g. "feature/Login"
+// becomes "feature-login-" followed by a short hash of the branch. Branches
+// that are valid labels as they are keep their name; any other is given the
+// hash so that, say, "feature/a" and "feature-a" don't share a preview.
+func PreviewLabel(branch string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(branch) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+
+	label := b.String()
+	for strings.Contains(label, "--") {
+		label = strings.ReplaceAll(label, "--", "-")
+	}
+	label = strings.Trim(label, "-")
+	if label == branch && len(label) <= previewLabelMax {
+		return label
+	}
+
+	sum := sha256.Sum256([]byte(branch))
+	suffix := hex.EncodeToString(sum[:])[:previewHashLen]
+	if limit := previewLabelMax - len(suffix) - 1; len(label) > limit {
+		label = strings.TrimRight(label[:limit], "-")
+	}
+	if label == "" {
+		return suffix
+	}
+	return label + "-" + suffix
+}
+
+// PreviewName returns the name of the preview of branch, which also names
+// its checkout, containers and compose project
+func (p *Project) PreviewName(branch string) string {
+	return p.Name + "-preview-" + PreviewLabel(branch)
+}
+
+// PreviewDomain returns the domain of the preview of branch, or "" without
+// a base domain
+func (p *Project) PreviewDomain(branch, baseDomain string) string {
+	if baseDomain == "" {
+		return ""
+	}
+	return PreviewLabel(branch) + "." + p.Name + "." + baseDomain
+}
+
+// PreviewServices returns the routed services of the preview of branch.
+// Services with a domain of their own get a subdomain of the preview's
+// domain instead, so they don't take over the project's domains.
+func (p *Project) PreviewServices(branch, baseDomain string) []RoutedService {
+	if len(p.Compose.Services) == 0 {
+		return nil
+	}
+
+	domain := p.PreviewDomain(branch, baseDomain)
+	services := make([]RoutedService, len(p.Compose.Services))
+	for i, s := range p.Compose.Services {
+		if s.Domain != "" || s.Subdomain != "" {
+			s.Domain, s.Subdomain = "", ""
+			if domain != "" {
+				s.Domain = PreviewLabel(s.Name) + "." + domain
+			}
+		}
+		services[i] = s
+	}
+	return services
+}
+
+// PreviewsJSON returns the preview config as JSON string for database storage
+func (p *Project) PreviewsJSON() string {
+	data, err := json.Marshal(p.Previews)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ParsePreviews parses a JSON string into the Previews field
+func (p *Project) ParsePreviews(data string) error {
+	p.Previews = PreviewConfig{}
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.Previews)
+}
@@ -2,6 +2,10 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
 	"time"
 )
 
@@ -22,6 +26,31 @@ const (
 	StatusError     ProjectStatus = "error"
 	StatusDeploying ProjectStatus = "deploying"
 	StatusPending   ProjectStatus = "pending"
+	// StatusCrashLoop means the project's containers keep exiting and being
+	// restarted by Docker
+	StatusCrashLoop ProjectStatus = "crashloop"
+)
+
+// ProjectStatuses lists every project status
+var ProjectStatuses = []ProjectStatus{
+	StatusRunning,
+	StatusStopped,
+	StatusError,
+	StatusDeploying,
+	StatusPending,
+	StatusCrashLoop,
+}
+
+// DeployTrigger records what started a deployment
+type DeployTrigger string
+
+const (
+	TriggerManual   DeployTrigger = "manual"
+	TriggerAPI      DeployTrigger = "api"
+	TriggerCLI      DeployTrigger = "cli"
+	TriggerWatcher  DeployTrigger = "watcher"
+	TriggerSchedule DeployTrigger = "schedule"
+	TriggerWebhook  DeployTrigger = "webhook"
 )
 
 // Project represents a deployment project
@@ -41,8 +70,156 @@ type Project struct {
 	Status       ProjectStatus     `json:"status"`
 	StatusMsg    string            `json:"status_msg"`
 	ContainerIDs []string          `json:"container_ids"`
-	CreatedAt    time.Time         `json:"created_at"`
-	UpdatedAt    time.Time         `json:"updated_at"`
+	Resources    ResourceLimits    `json:"resources"`
+	Mounts       []Mount           `json:"mounts"`
+	Backup       BackupPolicy      `json:"backup"`
+	Release      ReleaseConfig     `json:"release"`
+	LogRetention LogRetention      `json:"log_retention"`
+	Previews     PreviewConfig     `json:"previews"`
+	PathFilter   PathFilter        `json:"path_filter"`
+	Compose      ComposeConfig     `json:"compose"`
+	// PreviewOf is the ID of the project this is a branch preview of, and
+	// PreviewExpiresAt when it is torn down (zero for never)
+	PreviewOf        string    `json:"preview_of,omitempty"`
+	PreviewExpiresAt time.Time `json:"preview_expires_at"`
+	// TagRule deploys tags instead of the branch head: an exact tag or a
+	// semver constraint such as v1.x. DeployedTag is the tag checked out.
+	TagRule     string `json:"tag_rule,omitempty"`
+	DeployedTag string `json:"deployed_tag,omitempty"`
+	// PinnedCommit holds the project at a commit of its branch, ignoring
+	// newer commits until it is cleared. CommitsAhead is how many commits
+	// the branch had on top of it when the watcher last looked, -1 if unknown.
+	PinnedCommit string `json:"pinned_commit,omitempty"`
+	CommitsAhead int    `json:"commits_ahead"`
+	// LastExitCode, OOMKills and LastCrashAt record unexpected container
+	// exits since the last deploy
+	LastExitCode int       `json:"last_exit_code"`
+	OOMKills     int       `json:"oom_kills"`
+	LastCrashAt  time.Time `json:"last_crash_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ResourceLimits caps the resources a project's containers may use.
+// Zero values mean no limit.
+type ResourceLimits struct {
+	MemoryMB            int64   `json:"memory_mb,omitempty"`
+	MemoryReservationMB int64   `json:"memory_reservation_mb,omitempty"`
+	CPUs                float64 `json:"cpus,omitempty"`
+	CPUShares           int64   `json:"cpu_shares,omitempty"`
+	PidsLimit           int64   `json:"pids_limit,omitempty"`
+}
+
+// IsZero reports whether no limit is set
+func (l ResourceLimits) IsZero() bool {
+	return l == ResourceLimits{}
+}
+
+// Validate checks that the limits are consistent
+func (l ResourceLimits) Validate() error {
+	if l.MemoryMB < 0 || l.MemoryReservationMB < 0 || l.CPUs < 0 || l.CPUShares < 0 || l.PidsLimit < 0 {
+		return fmt.Errorf("resource limits must not be negative")
+	}
+	if l.MemoryMB > 0 && l.MemoryMB < 6 {
+		return fmt.Errorf("memory limit must be at least 6 MB")
+	}
+	if l.MemoryMB > 0 && l.MemoryReservationMB > l.MemoryMB {
+		return fmt.Errorf("memory reservation must not exceed the memory limit")
+	}
+	if l.CPUShares > 0 && (l.CPUShares < 2 || l.CPUShares > 262144) {
+		return fmt.Errorf("CPU shares must be between 2 and 262144")
+	}
+	return nil
+}
+
+// MountType is the kind of storage mounted into a container
+type MountType string
+
+const (
+	MountTypeVolume MountType = "volume"
+	MountTypeBind   MountType = "bind"
+)
+
+// Mount attaches a named volume or a host directory to an image project's
+// container. For volumes, Source is the volume name within the project.
+type Mount struct {
+	Type     MountType `json:"type"`
+	Source   string    `json:"source"`
+	Target   string    `json:"target"`
+	ReadOnly bool      `json:"read_only,omitempty"`
+}
+
+// volumeNamePattern matches the volume names Docker accepts
+var volumeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// String formats the mount like docker run -v, e.g. "data:/var/lib/data:ro"
+func (m Mount) String() string {
+	s := m.Source + ":" + m.Target
+	if m.ReadOnly {
+		s += ":ro"
+	}
+	return s
+}
+
+// ParseMount parses a mount in docker run -v syntax. Sources starting with
+// "/" are bind mounts, anything else is a named volume.
+func ParseMount(spec string) (Mount, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Mount{}, fmt.Errorf("invalid mount %q, expected source:target[:ro]", spec)
+	}
+
+	m := Mount{Type: MountTypeVolume, Source: parts[0], Target: parts[1]}
+	if strings.HasPrefix(m.Source, "/") {
+		m.Type = MountTypeBind
+	}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			m.ReadOnly = true
+		case "rw":
+		default:
+			return Mount{}, fmt.Errorf("invalid mount option %q in %q, expected ro or rw", parts[2], spec)
+		}
+	}
+
+	return m, m.Validate()
+}
+
+// Validate checks the mount's source and target
+func (m Mount) Validate() error {
+	if !path.IsAbs(m.Target) {
+		return fmt.Errorf("mount target %q must be an absolute path", m.Target)
+	}
+	switch m.Type {
+	case MountTypeVolume:
+		if !volumeNamePattern.MatchString(m.Source) {
+			return fmt.Errorf("invalid volume name %q", m.Source)
+		}
+	case MountTypeBind:
+		if !path.IsAbs(m.Source) {
+			return fmt.Errorf("bind mount source %q must be an absolute path", m.Source)
+		}
+	default:
+		return fmt.Errorf("invalid mount type %q", m.Type)
+	}
+	return nil
+}
+
+// ValidateMounts checks every mount and rejects duplicate targets
+func ValidateMounts(mounts []Mount) error {
+	targets := make(map[string]bool)
+	for _, m := range mounts {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		target := path.Clean(m.Target)
+		if targets[target] {
+			return fmt.Errorf("mount target %s is used more than once", target)
+		}
+		targets[target] = true
+	}
+	return nil
 }
 
 // EnvVarsJSON returns the env vars as JSON string for database storage
@@ -69,6 +246,27 @@ func (p *Project) ContainerIDsJSON() string {
 	return string(data)
 }
 
+// ResourceLimitsJSON returns the resource limits as JSON string for database storage
+func (p *Project) ResourceLimitsJSON() string {
+	data, err := json.Marshal(p.Resources)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// MountsJSON returns the mounts as JSON string for database storage
+func (p *Project) MountsJSON() string {
+	if p.Mounts == nil {
+		return "[]"
+	}
+	data, err := json.Marshal(p.Mounts)
+	if err != nil {
+		return "[]"
+	}
+	return string(data)
+}
+
 // ParseEnvVars parses a JSON string into the EnvVars map
 func (p *Project) ParseEnvVars(data string) error {
 	if data == "" {
@@ -87,6 +285,24 @@ func (p *Project) ParseContainerIDs(data string) error {
 	return json.Unmarshal([]byte(data), &p.ContainerIDs)
 }
 
+// ParseResourceLimits parses a JSON string into the Resources field
+func (p *Project) ParseResourceLimits(data string) error {
+	p.Resources = ResourceLimits{}
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.Resources)
+}
+
+// ParseMounts parses a JSON string into the Mounts slice
+func (p *Project) ParseMounts(data string) error {
+	if data == "" {
+		p.Mounts = []Mount{}
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.Mounts)
+}
+
 // GetEffectiveDomain returns the domain to use for this project
 func (p *Project) GetEffectiveDomain(baseDomain string) string {
 	if p.Domain != "" {
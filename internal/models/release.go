@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultReleaseTimeout is the timeout of release commands that don't set one
+const DefaultReleaseTimeout = 10 * time.Minute
+
+// ReleaseConfig holds commands run around a deploy, each in a one-off
+// container from the new image
+type ReleaseConfig struct {
+	// Command runs before the new containers start, such as database
+	// migrations; the deploy is aborted if it fails
+	Command string `json:"command,omitempty"`
+	// PostDeployCommand runs once the new containers are up
+	PostDeployCommand string `json:"post_deploy_command,omitempty"`
+	// Service is the compose service the commands run in; empty uses the
+	// service that receives traffic
+	Service        string `json:"service,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+// Validate checks the release timeout
+func (c ReleaseConfig) Validate() error {
+	if c.TimeoutSeconds < 0 {
+		return fmt.Errorf("release command timeout cannot be negative")
+	}
+	return nil
+}
+
+// Timeout returns how long each release command may take
+func (c ReleaseConfig) Timeout() time.Duration {
+	if c.TimeoutSeconds <= 0 {
+		return DefaultReleaseTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+// ReleaseJSON returns the release config as JSON string for database storage
+func (p *Project) ReleaseJSON() string {
+	data, err := json.Marshal(p.Release)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ParseRelease parses a JSON string into the Release field
+func (p *Project) ParseRelease(data string) error {
+	p.Release = ReleaseConfig{}
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.Release)
+}
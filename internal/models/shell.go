@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ShellSessionHistory is how many shell sessions are kept per project
+const ShellSessionHistory = 100
+
+// ShellSession records a terminal opened into a project container
+type ShellSession struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	// Username is who opened the shell; empty for the shared password login
+	Username    string    `json:"username"`
+	RemoteAddr  string    `json:"remote_addr"`
+	Service     string    `json:"service"`
+	ContainerID string    `json:"container_id"`
+	StartedAt   time.Time `json:"started_at"`
+	EndedAt     time.Time `json:"ended_at"`
+}
+
+// Duration returns how long the session lasted, or has been open
+func (s *ShellSession) Duration() time.Duration {
+	if s.EndedAt.IsZero() {
+		return time.Since(s.StartedAt).Round(time.Second)
+	}
+	return s.EndedAt.Sub(s.StartedAt).Round(time.Second)
+}
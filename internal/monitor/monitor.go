@@ -0,0 +1,275 @@
+// Package monitor detects crashing project containers from Docker events.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+	"github.com/mhenrichsen/slimdeploy/internal/notify"
+)
+
+const (
+	// CrashWindow is how long a crash counts towards a crash loop
+	CrashWindow = 5 * time.Minute
+	// CrashLoopThreshold is how many crashes within CrashWindow put a project
+	// in StatusCrashLoop
+	CrashLoopThreshold = 3
+
+	// checkInterval is how often crash loops are checked for recovery
+	checkInterval = 30 * time.Second
+	// reconnectDelay is how long to wait before resubscribing to the Docker
+	// event stream after it fails
+	reconnectDelay = 10 * time.Second
+	// killTimeout is how long after a kill event the die event of the same
+	// container is taken as an intentional stop
+	killTimeout = time.Minute
+)
+
+// Monitor watches the Docker event stream for project containers that exit
+// unexpectedly. Restart policies bring crashed containers back, so without
+// it a crash-looping project would still show as running.
+type Monitor struct {
+	dockerClient *docker.Client
+	projectRepo  *db.ProjectRepository
+	notifier     *notify.Notifier
+
+	// ctx is cancelled by Stop to close the event stream
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running bool
+	// crashes holds the recent crash times of each project
+	crashes map[string][]time.Time
+	// killed holds the time of the last kill event of each container
+	killed map[string]time.Time
+	// oom holds the containers with an OOM event awaiting their die event
+	oom map[string]bool
+}
+
+// New creates a new crash monitor
+func New(dockerClient *docker.Client, projectRepo *db.ProjectRepository, notifier *notify.Notifier) *Monitor {
+	return &Monitor{
+		dockerClient: dockerClient,
+		projectRepo:  projectRepo,
+		notifier:     notifier,
+		crashes:      make(map[string][]time.Time),
+		killed:       make(map[string]time.Time),
+		oom:          make(map[string]bool),
+	}
+}
+
+// Start starts watching container events
+func (m *Monitor) Start() {
+	m.mu.Lock()
+	if m.running {
+		m.mu.Unlock()
+		return
+	}
+	m.running = true
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.mu.Unlock()
+
+	m.wg.Add(2)
+	go m.watch()
+	go m.run()
+
+	log.Println("Crash monitor started")
+}
+
+// Stop stops watching container events
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return
+	}
+	m.running = false
+	m.cancel()
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	log.Println("Crash monitor stopped")
+}
+
+// watch subscribes to the Docker event stream, resubscribing when it fails.
+// Events missed while disconnected are not replayed.
+func (m *Monitor) watch() {
+	defer m.wg.Done()
+
+	for {
+		err := m.dockerClient.WatchContainerEvents(m.ctx, m.handle)
+		if m.ctx.Err() != nil {
+			return
+		}
+		log.Printf("Monitor: %v, retrying in %s", err, reconnectDelay)
+
+		select {
+		case <-time.After(reconnectDelay):
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// run periodically clears crash loops that have recovered
+func (m *Monitor) run() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.checkRecovered()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// handle processes a container event. A die event counts as a crash unless
+// the container was killed through Docker just before, which is how stops,
+// restarts and redeploys end containers, or it exited cleanly, as one-shot
+// compose services such as migrations do.
+func (m *Monitor) handle(event docker.ContainerEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch event.Action {
+	case docker.EventKill:
+		m.killed[event.ContainerID] = event.Time
+	case docker.EventOOM:
+		m.oom[event.ContainerID] = true
+	case docker.EventDie:
+		killedAt, killed := m.killed[event.ContainerID]
+		oomKilled := m.oom[event.ContainerID]
+		delete(m.killed, event.ContainerID)
+		delete(m.oom, event.ContainerID)
+		if killed && event.Time.Sub(killedAt) < killTimeout && !oomKilled {
+			return
+		}
+		if event.ExitCode == 0 && !oomKilled {
+			return
+		}
+		m.recordCrash(event, oomKilled)
+	}
+}
+
+// recordCrash records a crash of a running project's container, puts the
+// project in a crash loop once it crashes often enough and notifies
+// subscribed channels. Callers must hold m.mu.
+func (m *Monitor) recordCrash(event docker.ContainerEvent, oomKilled bool) {
+	project, err := m.projectRepo.GetByID(event.ProjectID)
+	if err != nil {
+		log.Printf("Monitor: %v", err)
+		return
+	}
+	// Containers of stopped projects and of projects being deployed are
+	// expected to exit
+	if project == nil || (project.Status != models.StatusRunning && project.Status != models.StatusCrashLoop) {
+		return
+	}
+
+	if err := m.projectRepo.RecordCrash(project.ID, event.ExitCode, oomKilled, event.Time); err != nil {
+		log.Printf("Monitor: %v", err)
+	}
+
+	// A deploy resets the crash record, and with it the crash loop count
+	crashes := m.crashes[project.ID]
+	if project.LastCrashAt.IsZero() {
+		crashes = nil
+	}
+	crashes = append(recent(crashes, event.Time), event.Time)
+	m.crashes[project.ID] = crashes
+
+	reason := describeExit(event.ExitCode, oomKilled)
+	log.Printf("Monitor: container %s of %s %s", event.ContainerName, project.Name, reason)
+
+	if len(crashes) < CrashLoopThreshold {
+		m.notify(project, fmt.Sprintf("Container %s %s.", event.ContainerName, reason))
+		return
+	}
+
+	statusMsg := fmt.Sprintf("Crashed %d times in the last %d minutes. Container %s %s.",
+		len(crashes), int(CrashWindow.Minutes()), event.ContainerName, reason)
+	if err := m.projectRepo.UpdateStatus(project.ID, models.StatusCrashLoop, statusMsg); err != nil {
+		log.Printf("Monitor: %v", err)
+	}
+	// Only the start of a crash loop is notified
+	if project.Status == models.StatusRunning {
+		log.Printf("Monitor: %s is crash looping", project.Name)
+		m.notify(project, statusMsg)
+	}
+}
+
+// checkRecovered marks crash-looping projects without a crash in the last
+// CrashWindow as running again and forgets old kill events
+func (m *Monitor) checkRecovered() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for containerID, killedAt := range m.killed {
+		if now.Sub(killedAt) > killTimeout {
+			delete(m.killed, containerID)
+		}
+	}
+
+	for projectID, crashes := range m.crashes {
+		if crashes = recent(crashes, now); len(crashes) > 0 {
+			m.crashes[projectID] = crashes
+			continue
+		}
+		delete(m.crashes, projectID)
+
+		project, err := m.projectRepo.GetByID(projectID)
+		if err != nil {
+			log.Printf("Monitor: %v", err)
+			continue
+		}
+		if project != nil && project.Status == models.StatusCrashLoop {
+			log.Printf("Monitor: %s has recovered from its crash loop", project.Name)
+			m.projectRepo.UpdateStatus(project.ID, models.StatusRunning, "")
+		}
+	}
+}
+
+// notify sends a container.crashed event for a project
+func (m *Monitor) notify(project *models.Project, message string) {
+	m.notifier.Notify(models.Event{
+		Type:        models.EventContainerCrashed,
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		Message:     message,
+		Commit:      project.LastCommit,
+		Time:        time.Now(),
+	})
+}
+
+// recent returns the crash times within CrashWindow before now
+func recent(crashes []time.Time, now time.Time) []time.Time {
+	var kept []time.Time
+	for _, t := range crashes {
+		if now.Sub(t) < CrashWindow {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// describeExit describes how a container exited
+func describeExit(exitCode int, oomKilled bool) string {
+	if oomKilled {
+		return fmt.Sprintf("ran out of memory and was killed (exit code %d)", exitCode)
+	}
+	return fmt.Sprintf("exited with code %d", exitCode)
+}
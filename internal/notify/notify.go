@@ -0,0 +1,164 @@
+// Package notify delivers project events to Slack, Discord, webhook and
+// email channels.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/models"
+)
+
+// SendTimeout bounds the delivery of one notification to one channel
+const SendTimeout = 10 * time.Second
+
+// discordContentLimit is the maximum length of a Discord message in
+// characters
+const discordContentLimit = 2000
+
+// Notifier sends events to the channels subscribed to them
+type Notifier struct {
+	repo *db.NotificationRepository
+	smtp SMTPConfig
+	http *http.Client
+
+	// wg tracks notifications being delivered in the background
+	wg sync.WaitGroup
+}
+
+// NewNotifier creates a new notifier. Email channels fail to send unless
+// smtp is enabled.
+func NewNotifier(repo *db.NotificationRepository, smtp SMTPConfig) *Notifier {
+	return &Notifier{
+		repo: repo,
+		smtp: smtp,
+		http: &http.Client{Timeout: SendTimeout},
+	}
+}
+
+// EmailEnabled reports whether an SMTP server is configured
+func (n *Notifier) EmailEnabled() bool {
+	return n.smtp.Enabled()
+}
+
+// Notify sends an event to every channel subscribed to it for the event's
+// project, in the background. Delivery failures are logged.
+func (n *Notifier) Notify(event models.Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	subs, err := n.repo.ListSubscriptions(event.ProjectID)
+	if err != nil {
+		log.Printf("Notify: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if !sub.Has(event.Type) {
+			continue
+		}
+		channel, err := n.repo.GetChannel(sub.ChannelID)
+		if err != nil {
+			log.Printf("Notify: %v", err)
+			continue
+		}
+		if channel == nil {
+			continue
+		}
+
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), SendTimeout)
+			defer cancel()
+			if err := n.Send(ctx, channel, event); err != nil {
+				log.Printf("Notify: failed to send %s of %s to %s: %v", event.Type, event.ProjectName, channel.Name, err)
+			}
+		}()
+	}
+}
+
+// Wait waits for notifications being delivered in the background
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// Send delivers an event to a channel
+func (n *Notifier) Send(ctx context.Context, channel *models.NotificationChannel, event models.Event) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	switch channel.Type {
+	case models.ChannelSlack:
+		return n.postJSON(ctx, channel.URL, map[string]string{"text": event.Text()}, nil)
+	case models.ChannelDiscord:
+		content := event.Text()
+		if utf8.RuneCountInString(content) > discordContentLimit {
+			content = string([]rune(content)[:discordContentLimit-3]) + "..."
+		}
+		return n.postJSON(ctx, channel.URL, map[string]string{"content": content}, nil)
+	case models.ChannelWebhook:
+		return n.postJSON(ctx, channel.URL, event, func(req *http.Request, body []byte) {
+			req.Header.Set("X-SlimDeploy-Event", string(event.Type))
+			if channel.Secret != "" {
+				req.Header.Set("X-SlimDeploy-Signature", Sign(channel.Secret, body))
+			}
+		})
+	case models.ChannelEmail:
+		return sendEmail(ctx, n.smtp, channel.Recipients(), event)
+	}
+	return fmt.Errorf("unknown channel type %q", channel.Type)
+}
+
+// Sign returns the X-SlimDeploy-Signature header of a webhook body, its
+// HMAC-SHA256 with the channel's secret as sha256=<hex>
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// postJSON posts payload as JSON to url. prepare, if set, can add headers
+// to the request based on the encoded body.
+func (n *Notifier) postJSON(ctx context.Context, url string, payload interface{}, prepare func(req *http.Request, body []byte)) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "SlimDeploy")
+	if prepare != nil {
+		prepare(req, body)
+	}
+
+	resp, err := n.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mhenrichsen/slimdeploy/internal/docker"
+)
+
+const (
+	// DefaultInterval is how often container stats are sampled
+	DefaultInterval = 15 * time.Second
+
+	// HistorySize is the number of samples kept per series (1 hour at 15s)
+	HistorySize = 240
+)
+
+// Sample is the combined resource usage of a group of containers at one
+// point in time. Network and block I/O values are cumulative counters.
+type Sample struct {
+	Time        time.Time `json:"time"`
+	Containers  int       `json:"containers"`
+	CPUPercent  float64   `json:"cpu_percent"`
+	MemoryUsage uint64    `json:"memory_usage"`
+	MemoryLimit uint64    `json:"memory_limit"`
+	NetworkRx   uint64    `json:"network_rx"`
+	NetworkTx   uint64    `json:"network_tx"`
+	BlockRead   uint64    `json:"block_read"`
+	BlockWrite  uint64    `json:"block_write"`
+}
+
+// add folds a container sample into the group sample
+func (s *Sample) add(c docker.ContainerStats) {
+	s.Containers++
+	s.CPUPercent += c.CPUPercent
+	s.MemoryUsage += c.MemoryUsage
+	s.MemoryLimit += c.MemoryLimit
+	s.NetworkRx += c.NetworkRx
+	s.NetworkTx += c.NetworkTx
+	s.BlockRead += c.BlockRead
+	s.BlockWrite += c.BlockWrite
+}
+
+// ring is a fixed-size buffer of samples, oldest first
+type ring struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+func newRing(size int) *ring {
+	return &ring{samples: make([]Sample, size)}
+}
+
+func (r *ring) push(s Sample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the samples in chronological order
+func (r *ring) list() []Sample {
+	if !r.full {
+		return append([]Sample(nil), r.samples[:r.next]...)
+	}
+	return append(append([]Sample(nil), r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+// Collector periodically samples the resource usage of all managed containers
+// and keeps a rolling in-memory history per project and for the whole host
+type Collector struct {
+	dockerClient *docker.Client
+	interval     time.Duration
+
+	mu         sync.RWMutex
+	host       *ring
+	projects   map[string]*ring
+	containers map[string][]docker.ContainerStats
+	hostInfo   *docker.HostInfo
+	lastErr    string
+
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running bool
+	runMu   sync.Mutex
+}
+
+// NewCollector creates a new stats collector
+func NewCollector(dockerClient *docker.Client, interval time.Duration) *Collector {
+	return &Collector{
+		dockerClient: dockerClient,
+		interval:     interval,
+		host:         newRing(HistorySize),
+		projects:     make(map[string]*ring),
+		containers:   make(map[string][]docker.ContainerStats),
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// Start starts collecting in the background
+func (c *Collector) Start() {
+	c.runMu.Lock()
+	if c.running {
+		c.runMu.Unlock()
+		return
+	}
+	c.running = true
+	c.stopCh = make(chan struct{})
+	c.runMu.Unlock()
+
+	c.wg.Add(1)
+	go c.run()
+
+	log.Printf("Stats collector started with interval %v", c.interval)
+}
+
+// Stop stops collecting
+func (c *Collector) Stop() {
+	c.runMu.Lock()
+	if !c.running {
+		c.runMu.Unlock()
+		return
+	}
+	c.running = false
+	close(c.stopCh)
+	c.runMu.Unlock()
+
+	c.wg.Wait()
+	log.Println("Stats collector stopped")
+}
+
+// run is the main collector loop
+func (c *Collector) run() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.collect()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.collect()
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+// collect takes one sample of every managed container
+func (c *Collector) collect() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.interval)
+	defer cancel()
+
+	hostInfo, err := c.dockerClient.GetHostInfo(ctx)
+	if err == nil {
+		var containers []docker.ContainerStats
+		containers, err = c.dockerClient.GetManagedContainerStats(ctx)
+		if err == nil {
+			c.record(time.Now(), hostInfo, containers)
+		}
+	}
+
+	// Only log when the error changes so an unreachable daemon doesn't flood the log
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && err.Error() != c.lastErr {
+		log.Printf("Stats: failed to collect container stats: %v", err)
+	}
+	c.lastErr = ""
+	if err != nil {
+		c.lastErr = err.Error()
+	}
+}
+
+// record stores one round of container samples
+func (c *Collector) record(now time.Time, hostInfo *docker.HostInfo, containers []docker.ContainerStats) {
+	host := Sample{Time: now}
+	projects := make(map[string]*Sample)
+	byProject := make(map[string][]docker.ContainerStats)
+
+	for _, cs := range containers {
+		host.add(cs)
+		if cs.ProjectID == "" {
+			continue
+		}
+		if projects[cs.ProjectID] == nil {
+			projects[cs.ProjectID] = &Sample{Time: now}
+		}
+		projects[cs.ProjectID].add(cs)
+		byProject[cs.ProjectID] = append(byProject[cs.ProjectID], cs)
+	}
+
+	for _, list := range byProject {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hostInfo = hostInfo
+	c.host.push(host)
+	c.containers = byProject
+
+	// Projects without running containers get an empty sample so gaps show
+	for id, r := range c.projects {
+		if projects[id] == nil {
+			r.push(Sample{Time: now})
+		}
+	}
+	for id, sample := range projects {
+		r, ok := c.projects[id]
+		if !ok {
+			r = newRing(HistorySize)
+			c.projects[id] = r
+		}
+		r.push(*sample)
+	}
+}
+
+// Host returns the Docker host info and the history of all managed containers
+func (c *Collector) Host() (*docker.HostInfo, []Sample) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hostInfo, c.host.list()
+}
+
+// Project returns the history of a project and the latest per-container stats
+func (c *Collector) Project(projectID string) ([]Sample, []docker.ContainerStats) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var samples []Sample
+	if r, ok := c.projects[projectID]; ok {
+		samples = r.list()
+	}
+	return samples, append([]docker.ContainerStats(nil), c.containers[projectID]...)
+}
+
+// Forget drops the history of a deleted project
+func (c *Collector) Forget(projectID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.projects, projectID)
+	delete(c.containers, projectID)
+}
+
+// Err returns the last collection error, if any
+func (c *Collector) Err() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastErr
+}
+
+// FormatBytes formats a byte count with a binary unit, e.g. "1.5 GiB"
+func FormatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
@@ -7,18 +7,26 @@ import (
 	"time"
 
 	"github.com/mhenrichsen/slimdeploy/internal/db"
+	"github.com/mhenrichsen/slimdeploy/internal/events"
 	gitpkg "github.com/mhenrichsen/slimdeploy/internal/git"
+	"github.com/mhenrichsen/slimdeploy/internal/metrics"
 	"github.com/mhenrichsen/slimdeploy/internal/models"
 )
 
 // DeployFunc is a function that deploys a project
-type DeployFunc func(ctx context.Context, project *models.Project) error
+type DeployFunc func(ctx context.Context, project *models.Project, trigger models.DeployTrigger) error
+
+// PreviewFunc is a function that deploys and tears down the branch previews
+// of a project
+type PreviewFunc func(ctx context.Context, project *models.Project) error
 
 // Watcher watches git repositories for changes
 type Watcher struct {
 	projectRepo *db.ProjectRepository
 	gitManager  *gitpkg.Manager
 	deployFunc  DeployFunc
+	previewFunc PreviewFunc
+	events      *events.Bus
 	interval    time.Duration
 	stopCh      chan struct{}
 	wg          sync.WaitGroup
@@ -26,12 +34,15 @@ type Watcher struct {
 	mu          sync.Mutex
 }
 
-// New creates a new Watcher
-func New(projectRepo *db.ProjectRepository, gitManager *gitpkg.Manager, deployFunc DeployFunc, interval time.Duration) *Watcher {
+// New creates a new Watcher. New commits are published to bus, which may be
+// nil.
+func New(projectRepo *db.ProjectRepository, gitManager *gitpkg.Manager, deployFunc DeployFunc, previewFunc PreviewFunc, bus *events.Bus, interval time.Duration) *Watcher {
 	return &Watcher{
 		projectRepo: projectRepo,
 		gitManager:  gitManager,
 		deployFunc:  deployFunc,
+		previewFunc: previewFunc,
+		events:      bus,
 		interval:    interval,
 		stopCh:      make(chan struct{}),
 	}
@@ -91,6 +102,11 @@ func (w *Watcher) run() {
 
 // checkAll checks all projects with auto-deploy enabled
 func (w *Watcher) checkAll() {
+	start := time.Now()
+	defer func() {
+		metrics.WatcherPollDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	projects, err := w.projectRepo.ListAutoDeployEnabled()
 	if err != nil {
 		log.Printf("Watcher: failed to list projects: %v", err)
@@ -100,6 +116,64 @@ func (w *Watcher) checkAll() {
 	for _, project := range projects {
 		w.checkProject(project)
 	}
+
+	w.syncPreviews()
+	w.countPinned()
+}
+
+// syncPreviews updates the branch previews of every project that has them
+// enabled
+func (w *Watcher) syncPreviews() {
+	projects, err := w.projectRepo.List()
+	if err != nil {
+		log.Printf("Watcher: failed to list projects: %v", err)
+		return
+	}
+
+	for _, project := range projects {
+		if !project.Previews.Enabled || project.IsPreview() || project.GitURL == "" {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		if err := w.previewFunc(ctx, project); err != nil {
+			log.Printf("Watcher: failed to update previews of %s: %v", project.Name, err)
+			metrics.GitFetchErrors.Inc(project.Name)
+		}
+		cancel()
+	}
+}
+
+// countPinned records how many commits the branch of every pinned project
+// has on top of its pin, for the project page
+func (w *Watcher) countPinned() {
+	projects, err := w.projectRepo.List()
+	if err != nil {
+		log.Printf("Watcher: failed to list projects: %v", err)
+		return
+	}
+
+	for _, project := range projects {
+		if project.PinnedCommit == "" || project.GitURL == "" || project.Status == models.StatusDeploying {
+			continue
+		}
+		if !w.gitManager.Exists(project.Name) {
+			continue
+		}
+
+		ahead, err := w.gitManager.CommitsAhead(project.GitURL, project.Branch, project.Name)
+		if err != nil {
+			log.Printf("Watcher: failed to count commits ahead of %s: %v", project.Name, err)
+			metrics.GitFetchErrors.Inc(project.Name)
+			ahead = -1
+		}
+		if ahead == project.CommitsAhead {
+			continue
+		}
+		if err := w.projectRepo.UpdateCommitsAhead(project.ID, ahead); err != nil {
+			log.Printf("Watcher: failed to update commits ahead of %s: %v", project.Name, err)
+		}
+	}
 }
 
 // checkProject checks a single project for updates
@@ -114,16 +188,28 @@ func (w *Watcher) checkProject(project *models.Project) {
 		return
 	}
 
+	// Pinned projects stay at their commit
+	if project.PinnedCommit != "" {
+		return
+	}
+
 	// Check if repo exists locally
 	if !w.gitManager.Exists(project.Name) {
 		log.Printf("Watcher: repository not found for %s, skipping", project.Name)
 		return
 	}
 
+	// Projects tracking tags deploy when a newer matching tag appears
+	if project.TagRule != "" {
+		w.checkTag(project)
+		return
+	}
+
 	// Check for updates
 	hasUpdates, newCommit, err := w.gitManager.CheckForUpdates(project.GitURL, project.Branch, project.Name)
 	if err != nil {
 		log.Printf("Watcher: failed to check for updates on %s: %v", project.Name, err)
+		metrics.GitFetchErrors.Inc(project.Name)
 		return
 	}
 
@@ -133,9 +219,28 @@ func (w *Watcher) checkProject(project *models.Project) {
 
 	log.Printf("Watcher: detected new commit on %s: %s", project.Name, newCommit[:8])
 
+	if reason := w.skipReason(project, newCommit); reason != "" {
+		log.Printf("Watcher: not deploying %s at %s: %s", project.Name, newCommit[:8], reason)
+		// Move the checkout along so the commit isn't found again; the last
+		// commit stays at the deployed one so later diffs include this one
+		if err := w.gitManager.Pull(project.GitURL, project.Branch, project.Name); err != nil {
+			log.Printf("Watcher: failed to pull updates for %s: %v", project.Name, err)
+			metrics.GitFetchErrors.Inc(project.Name)
+			return
+		}
+		w.events.Publish(events.Event{
+			Type:      events.TypeCommit,
+			ProjectID: project.ID,
+			Message:   "Skipped deploy: " + reason,
+			Commit:    newCommit,
+		})
+		return
+	}
+
 	// Pull the updates
 	if err := w.gitManager.Pull(project.GitURL, project.Branch, project.Name); err != nil {
 		log.Printf("Watcher: failed to pull updates for %s: %v", project.Name, err)
+		metrics.GitFetchErrors.Inc(project.Name)
 		return
 	}
 
@@ -143,12 +248,18 @@ func (w *Watcher) checkProject(project *models.Project) {
 	if err := w.projectRepo.UpdateLastCommit(project.ID, newCommit); err != nil {
 		log.Printf("Watcher: failed to update last commit for %s: %v", project.Name, err)
 	}
+	w.events.Publish(events.Event{
+		Type:      events.TypeCommit,
+		ProjectID: project.ID,
+		Message:   "Pulled new commit",
+		Commit:    newCommit,
+	})
 
 	// Trigger deployment
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	if err := w.deployFunc(ctx, project); err != nil {
+	if err := w.deployFunc(ctx, project, models.TriggerWatcher); err != nil {
 		log.Printf("Watcher: failed to deploy %s: %v", project.Name, err)
 		w.projectRepo.UpdateStatus(project.ID, models.StatusError, err.Error())
 		return
@@ -157,6 +268,61 @@ func (w *Watcher) checkProject(project *models.Project) {
 	log.Printf("Watcher: successfully deployed %s", project.Name)
 }
 
+// skipReason returns why a new commit of a project shouldn't be deployed,
+// or "" to deploy it. Path filters compare the commit with the deployed one.
+func (w *Watcher) skipReason(project *models.Project, commit string) string {
+	if message, err := w.gitManager.CommitMessage(project.Name, commit); err == nil && models.SkipsDeploy(message) {
+		return "commit message contains " + models.SkipDeployMarker
+	}
+	if !project.PathFilter.Enabled() || project.LastCommit == "" {
+		return ""
+	}
+
+	files, err := w.gitManager.ChangedFiles(project.Name, project.LastCommit, commit)
+	if err != nil {
+		log.Printf("Watcher: failed to diff %s, deploying anyway: %v", project.Name, err)
+		return ""
+	}
+	if !project.PathFilter.Matches(files) {
+		return "no changes in watched paths"
+	}
+	return ""
+}
+
+// checkTag deploys a project when the newest tag matching its tag rule
+// differs from the deployed one
+func (w *Watcher) checkTag(project *models.Project) {
+	tags, err := w.gitManager.ListRemoteTags(project.GitURL)
+	if err != nil {
+		log.Printf("Watcher: failed to list tags of %s: %v", project.Name, err)
+		metrics.GitFetchErrors.Inc(project.Name)
+		return
+	}
+
+	tag, ok := gitpkg.LatestTag(project.TagRule, tags)
+	if !ok || tag == project.DeployedTag {
+		return
+	}
+
+	log.Printf("Watcher: detected new tag on %s: %s", project.Name, tag)
+	w.events.Publish(events.Event{
+		Type:      events.TypeCommit,
+		ProjectID: project.ID,
+		Message:   "Found tag " + tag,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if err := w.deployFunc(ctx, project, models.TriggerWatcher); err != nil {
+		log.Printf("Watcher: failed to deploy %s: %v", project.Name, err)
+		w.projectRepo.UpdateStatus(project.ID, models.StatusError, err.Error())
+		return
+	}
+
+	log.Printf("Watcher: successfully deployed %s at %s", project.Name, tag)
+}
+
 // CheckProject manually triggers a check for a specific project
 func (w *Watcher) CheckProject(projectID string) error {
 	project, err := w.projectRepo.GetByID(projectID)
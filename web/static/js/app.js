@@ -25,24 +25,3 @@ document.body.addEventListener('htmx:afterRequest', function(evt) {
         target.disabled = false;
     }
 });
-
-// Auto-refresh for deploying status
-function setupStatusPolling() {
-    const deployingCards = document.querySelectorAll('[data-status="deploying"]');
-    deployingCards.forEach(card => {
-        const projectId = card.id.replace('project-', '');
-        setInterval(() => {
-            htmx.trigger(card, 'refresh');
-        }, 3000);
-    });
-}
-
-// Initialize on DOM ready
-document.addEventListener('DOMContentLoaded', function() {
-    setupStatusPolling();
-});
-
-// Reinitialize after HTMX swaps
-document.body.addEventListener('htmx:afterSwap', function() {
-    setupStatusPolling();
-});